@@ -0,0 +1,121 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// getOptionalUserID returns the ID of the authenticated user, or uuid.Nil
+// for anonymous requests. A token that is present but invalid is an error.
+func (cfg *apiConfig) getOptionalUserID(req *http.Request) (uuid.UUID, error) {
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if errors.Is(err, auth.ErrNoAuthHeaderIncluded) {
+		return uuid.Nil, nil
+	} else if err != nil {
+		return uuid.Nil, err
+	}
+
+	return auth.ValidateJWT(tokenString, cfg.tokenSecret)
+}
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// requireRole authenticates the request and checks the user has one of the
+// given roles. When they don't, it writes the error response and returns
+// false.
+func (cfg *apiConfig) requireRole(w http.ResponseWriter, req *http.Request, roles ...string) (database.User, bool) {
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return database.User{}, false
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return database.User{}, false
+	}
+
+	dbUser, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusUnauthorized, "User not found", err)
+		return database.User{}, false
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return database.User{}, false
+	}
+
+	if !slices.Contains(roles, dbUser.Role) {
+		respondWithError(w, http.StatusForbidden, "You do not have permission to access this resource", nil)
+		return database.User{}, false
+	}
+
+	return dbUser, true
+}
+
+// isModerator reports whether the user can see and act on hidden content.
+// Anonymous viewers are never moderators.
+func (cfg *apiConfig) isModerator(ctx context.Context, userID uuid.UUID) (bool, error) {
+	if userID == uuid.Nil {
+		return false, nil
+	}
+
+	dbUser, err := cfg.dbQueries.GetUser(ctx, userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return dbUser.Role == roleModerator || dbUser.Role == roleAdmin, nil
+}
+
+// middlewareRejectSuspended refuses writes from suspended and banned users.
+// Their refresh tokens are revoked on suspension, but an access token they
+// already hold stays valid until it expires.
+func (cfg *apiConfig) middlewareRejectSuspended(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Requests without an access token are left to the handler, which
+		// either doesn't need one or will reject the request itself
+		tokenString, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		dbUser, err := cfg.dbQueries.GetUser(r.Context(), userID)
+		if err == sql.ErrNoRows {
+			next.ServeHTTP(w, r)
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+			return
+		}
+		if isSuspended(dbUser) {
+			respondWithError(w, http.StatusForbidden, "Account suspended", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
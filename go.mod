@@ -4,8 +4,10 @@ go 1.24.3
 
 require (
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	golang.org/x/crypto v0.40.0
+	golang.org/x/text v0.27.0
 	github.com/golang-jwt/jwt/v5 v5.3.0
 )
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"chirpy/internal/activitypub"
+	"chirpy/internal/database"
+	"chirpy/internal/events"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	federationDeliveryInterval = 5 * time.Second
+	// outboxPageSize is how many activities are on each outbox page
+	outboxPageSize = 20
+)
+
+func respondWithActivity(w http.ResponseWriter, code int, payload any) {
+	respondWithJSONType(w, code, activitypub.ContentType, payload)
+}
+
+func (cfg *apiConfig) handlerWebFinger(w http.ResponseWriter, req *http.Request) {
+	username, host, err := activitypub.ParseResource(req.URL.Query().Get("resource"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid resource", err)
+		return
+	}
+	if host != cfg.federation.Host() {
+		respondWithError(w, http.StatusNotFound, "", nil)
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByHandle(req.Context(), username)
+	if err == sql.ErrNoRows || (err == nil && dbUser.BannedAt.Valid) {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+
+	respondWithJSONType(w, http.StatusOK, activitypub.WebFingerContentType, cfg.federation.WebFinger(dbUser.ID, dbUser.Handle.String))
+}
+
+func (cfg *apiConfig) handlerGetActor(w http.ResponseWriter, req *http.Request) {
+	dbUser, ok := cfg.getFederatedUser(w, req)
+	if !ok {
+		return
+	}
+
+	actor, err := cfg.federation.Actor(req.Context(), dbUser.ID, dbUser.Handle.String)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting actor", err)
+		return
+	}
+
+	respondWithActivity(w, http.StatusOK, actor)
+}
+
+// handlerGetOutbox lists a user's public chirps as Create activities, newest
+// first. Without a page parameter it returns the collection, which links to
+// the first page.
+func (cfg *apiConfig) handlerGetOutbox(w http.ResponseWriter, req *http.Request) {
+	dbUser, ok := cfg.getFederatedUser(w, req)
+	if !ok {
+		return
+	}
+
+	page := 0
+	if pageString := req.URL.Query().Get("page"); len(pageString) > 0 {
+		value, err := strconv.Atoi(pageString)
+		if err != nil || value < 1 || value > math.MaxInt32/outboxPageSize {
+			respondWithError(w, http.StatusBadRequest, "Invalid page", err)
+			return
+		}
+		page = value
+	}
+
+	outboxURL := cfg.federation.OutboxURL(dbUser.ID)
+	if page == 0 {
+		count, err := cfg.dbQueries.CountPublicChirpsByUser(req.Context(), dbUser.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error counting chirps", err)
+			return
+		}
+
+		respondWithActivity(w, http.StatusOK, activitypub.OrderedCollection{
+			Context:    activitypub.ActivityStreamsContext,
+			ID:         outboxURL,
+			Type:       "OrderedCollection",
+			TotalItems: count,
+			First:      outboxURL + "?page=1",
+		})
+		return
+	}
+
+	// One more than a page is read to see whether there's a next page
+	dbChirps, err := cfg.dbQueries.GetPublicChirpsByUser(req.Context(), database.GetPublicChirpsByUserParams{
+		UserID: dbUser.ID,
+		Limit:  outboxPageSize + 1,
+		Offset: int32((page - 1) * outboxPageSize),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
+		return
+	}
+
+	collectionPage := activitypub.OrderedCollectionPage{
+		Context:      activitypub.ActivityStreamsContext,
+		ID:           outboxURL + "?page=" + strconv.Itoa(page),
+		Type:         "OrderedCollectionPage",
+		PartOf:       outboxURL,
+		OrderedItems: make([]any, 0, outboxPageSize),
+	}
+	if len(dbChirps) > outboxPageSize {
+		collectionPage.Next = outboxURL + "?page=" + strconv.Itoa(page+1)
+		dbChirps = dbChirps[:outboxPageSize]
+	}
+	for _, dbChirp := range dbChirps {
+		collectionPage.OrderedItems = append(collectionPage.OrderedItems, cfg.federation.Create(federatedChirp(dbChirp)))
+	}
+
+	respondWithActivity(w, http.StatusOK, collectionPage)
+}
+
+// handlerGetFollowersCollection only gives the number of remote followers;
+// who they are isn't shared.
+func (cfg *apiConfig) handlerGetFollowersCollection(w http.ResponseWriter, req *http.Request) {
+	dbUser, ok := cfg.getFederatedUser(w, req)
+	if !ok {
+		return
+	}
+
+	count, err := cfg.dbQueries.CountRemoteFollowers(req.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting followers", err)
+		return
+	}
+
+	respondWithActivity(w, http.StatusOK, activitypub.OrderedCollection{
+		Context:    activitypub.ActivityStreamsContext,
+		ID:         cfg.federation.FollowersURL(dbUser.ID),
+		Type:       "OrderedCollection",
+		TotalItems: count,
+	})
+}
+
+func (cfg *apiConfig) handlerGetNote(w http.ResponseWriter, req *http.Request) {
+	dbUser, ok := cfg.getFederatedUser(w, req)
+	if !ok {
+		return
+	}
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirpID", err)
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirp(req.Context(), chirpID)
+	if err == sql.ErrNoRows || (err == nil && !isFederated(dbChirp)) || (err == nil && dbChirp.UserID != dbUser.ID) {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp", err)
+		return
+	}
+
+	note := cfg.federation.Note(federatedChirp(dbChirp))
+	note.Context = activitypub.ActivityStreamsContext
+	respondWithActivity(w, http.StatusOK, note)
+}
+
+func (cfg *apiConfig) handlerInbox(w http.ResponseWriter, req *http.Request) {
+	dbUser, ok := cfg.getFederatedUser(w, req)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, activitypub.MaxBodySize))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Activity is too large", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Error reading activity", err)
+		return
+	}
+
+	err = cfg.federation.HandleInbox(req.Context(), dbUser.ID, req, body)
+	if errors.Is(err, activitypub.ErrInvalidSignature) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid signature", err)
+		return
+	} else if errors.Is(err, activitypub.ErrInvalidActivity) {
+		respondWithError(w, http.StatusBadRequest, "Invalid activity", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error handling activity", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// getFederatedUser loads the user in the path. Only users with a handle are
+// published as actors, since other servers address them by it.
+func (cfg *apiConfig) getFederatedUser(w http.ResponseWriter, req *http.Request) (database.User, bool) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return database.User{}, false
+	}
+
+	dbUser, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err == sql.ErrNoRows || (err == nil && (!dbUser.Handle.Valid || dbUser.BannedAt.Valid)) {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return database.User{}, false
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return database.User{}, false
+	}
+	return dbUser, true
+}
+
+// federateChirpCreated sends a new public chirp to the author's remote
+// followers. It's an outbox subscriber; repeats are only queued once.
+func (cfg *apiConfig) federateChirpCreated(ctx context.Context, event events.ChirpCreated) error {
+	dbChirp, err := cfg.dbQueries.GetChirp(ctx, event.ChirpID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !isFederated(dbChirp) {
+		return nil
+	}
+
+	return cfg.federation.SendToFollowers(ctx, dbChirp.UserID, cfg.federation.Create(federatedChirp(dbChirp)))
+}
+
+// federateChirpDeleted tells remote followers to delete a public chirp.
+func (cfg *apiConfig) federateChirpDeleted(ctx context.Context, event events.ChirpDeleted) error {
+	dbChirp, err := cfg.dbQueries.GetChirpIncludingDeleted(ctx, event.ChirpID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if dbChirp.Visibility != visibilityPublic {
+		return nil
+	}
+
+	return cfg.federation.SendToFollowers(ctx, dbChirp.UserID, cfg.federation.Delete(dbChirp.UserID, dbChirp.ID))
+}
+
+// federateChirpUpdated sends an edited public chirp to remote followers.
+func (cfg *apiConfig) federateChirpUpdated(ctx context.Context, event events.ChirpUpdated) error {
+	dbChirp, err := cfg.dbQueries.GetChirp(ctx, event.ChirpID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !isFederated(dbChirp) {
+		return nil
+	}
+
+	return cfg.federation.SendToFollowers(ctx, dbChirp.UserID, cfg.federation.Update(federatedChirp(dbChirp)))
+}
+
+// federateChirpHidden tells remote followers to delete a public chirp that
+// a moderator has hidden.
+func (cfg *apiConfig) federateChirpHidden(ctx context.Context, event events.ChirpHidden) error {
+	dbChirp, err := cfg.dbQueries.GetChirpIncludingDeleted(ctx, event.ChirpID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if dbChirp.Visibility != visibilityPublic {
+		return nil
+	}
+
+	return cfg.federation.SendToFollowers(ctx, dbChirp.UserID, cfg.federation.Delete(dbChirp.UserID, dbChirp.ID))
+}
+
+// federateChirpRestored sends a restored public chirp to remote followers
+// again, as a new Create. Servers that keep a tombstone for deleted notes,
+// as Mastodon does, ignore it, so remote copies may stay deleted.
+func (cfg *apiConfig) federateChirpRestored(ctx context.Context, event events.ChirpRestored) error {
+	dbChirp, err := cfg.dbQueries.GetChirp(ctx, event.ChirpID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !isFederated(dbChirp) {
+		return nil
+	}
+
+	// Deliveries are unique per activity, and the original Create has been
+	// sent already. Restoring sets updated_at, so each restore gets its own.
+	activity := cfg.federation.Create(federatedChirp(dbChirp))
+	activity.ID += "#restored-" + strconv.FormatInt(dbChirp.UpdatedAt.Unix(), 10)
+	return cfg.federation.SendToFollowers(ctx, dbChirp.UserID, activity)
+}
+
+// isFederated reports whether a chirp can be shared with other servers.
+func isFederated(dbChirp database.Chirp) bool {
+	return dbChirp.Visibility == visibilityPublic && !dbChirp.HiddenAt.Valid
+}
+
+func federatedChirp(dbChirp database.Chirp) activitypub.Chirp {
+	return activitypub.Chirp{
+		ID:        dbChirp.ID,
+		AuthorID:  dbChirp.UserID,
+		Body:      dbChirp.Body,
+		CreatedAt: dbChirp.CreatedAt,
+		UpdatedAt: dbChirp.UpdatedAt,
+	}
+}
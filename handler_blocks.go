@@ -0,0 +1,194 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"database/sql"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerBlock(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get user to block
+	blockedID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(blockedID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	} else if blockedID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't block yourself", nil)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetUser(req.Context(), blockedID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+
+	// Blocking also removes any follows between the two users
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error blocking user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	err = qtx.CreateBlock(req.Context(), database.CreateBlockParams{
+		BlockerID: userID,
+		BlockedID: blockedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error blocking user", err)
+		return
+	}
+
+	err = qtx.DeleteFollowsBetween(req.Context(), database.DeleteFollowsBetweenParams{
+		UserA: userID,
+		UserB: blockedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error removing follows", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error blocking user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnblock(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	blockedID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(blockedID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteBlock(req.Context(), database.DeleteBlockParams{
+		BlockerID: userID,
+		BlockedID: blockedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error unblocking user", err)
+		return
+	} else if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMute(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get user to mute
+	mutedID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(mutedID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	} else if mutedID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't mute yourself", nil)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetUser(req.Context(), mutedID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+
+	err = cfg.dbQueries.CreateMute(req.Context(), database.CreateMuteParams{
+		MuterID: userID,
+		MutedID: mutedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error muting user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnmute(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	mutedID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(mutedID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteMute(req.Context(), database.DeleteMuteParams{
+		MuterID: userID,
+		MutedID: mutedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error unmuting user", err)
+		return
+	} else if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
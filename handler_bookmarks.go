@@ -0,0 +1,547 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxCollectionNameLength = 50
+
+type Bookmark struct {
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ChirpID      uuid.UUID  `json:"chirp_id"`
+	CollectionID *uuid.UUID `json:"collection_id"`
+	// Chirp is null when the chirp has been deleted or hidden, or the user
+	// can no longer see it
+	Chirp *Chirp `json:"chirp"`
+}
+
+type BookmarkList struct {
+	Count     int64      `json:"count"`
+	Bookmarks []Bookmark `json:"bookmarks"`
+}
+
+type Collection struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Name          string    `json:"name"`
+	BookmarkCount int64     `json:"bookmark_count"`
+}
+
+var errCollectionNotFound = errors.New("collection not found")
+
+// bookmarkParameters is the request body for bookmarking a chirp. A null
+// collection_id leaves the bookmark out of every collection.
+type bookmarkParameters struct {
+	CollectionID *uuid.UUID `json:"collection_id"`
+}
+
+func (cfg *apiConfig) handlerAddBookmark(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get chirp
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil || len(chirpID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirpID", err)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetChirpForViewer(req.Context(), database.GetChirpForViewerParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp", err)
+		return
+	}
+
+	// Decode request. The body is optional.
+	decoder := json.NewDecoder(req.Body)
+	params := bookmarkParameters{}
+	err = decoder.Decode(&params)
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	collectionID, err := cfg.ownCollectionID(req.Context(), userID, params.CollectionID)
+	if err == errCollectionNotFound {
+		respondWithError(w, http.StatusBadRequest, "Collection not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting collection", err)
+		return
+	}
+
+	// Write to database
+	dbBookmark, err := cfg.dbQueries.CreateBookmark(req.Context(), database.CreateBookmarkParams{
+		UserID:       userID,
+		ChirpID:      chirpID,
+		CollectionID: collectionID,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Chirp already bookmarked", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating bookmark", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, mapBookmark(dbBookmark, nil))
+}
+
+// handlerUpdateBookmark moves a bookmark into another collection, or out of
+// its collection.
+func (cfg *apiConfig) handlerUpdateBookmark(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil || len(chirpID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirpID", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := bookmarkParameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	collectionID, err := cfg.ownCollectionID(req.Context(), userID, params.CollectionID)
+	if err == errCollectionNotFound {
+		respondWithError(w, http.StatusBadRequest, "Collection not found", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting collection", err)
+		return
+	}
+
+	// Write to database
+	dbBookmark, err := cfg.dbQueries.UpdateBookmarkCollection(req.Context(), database.UpdateBookmarkCollectionParams{
+		UserID:       userID,
+		ChirpID:      chirpID,
+		CollectionID: collectionID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating bookmark", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mapBookmark(dbBookmark, nil))
+}
+
+func (cfg *apiConfig) handlerDeleteBookmark(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil || len(chirpID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirpID", err)
+		return
+	}
+
+	// Bookmarks of deleted chirps can still be removed
+	deleted, err := cfg.dbQueries.DeleteBookmark(req.Context(), database.DeleteBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting bookmark", err)
+		return
+	} else if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetBookmarks(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	limit, offset, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	collectionID := uuid.NullUUID{}
+	if collectionIDString := req.URL.Query().Get("collection_id"); len(collectionIDString) > 0 {
+		id, err := uuid.Parse(collectionIDString)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid collection_id", err)
+			return
+		}
+		collectionID, err = cfg.ownCollectionID(req.Context(), userID, &id)
+		if err == errCollectionNotFound {
+			respondWithError(w, http.StatusNotFound, "", err)
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting collection", err)
+			return
+		}
+	}
+
+	count, err := cfg.dbQueries.CountBookmarks(req.Context(), database.CountBookmarksParams{
+		UserID:       userID,
+		CollectionID: collectionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting bookmarks", err)
+		return
+	}
+
+	dbBookmarks, err := cfg.dbQueries.GetBookmarks(req.Context(), database.GetBookmarksParams{
+		UserID:       userID,
+		CollectionID: collectionID,
+		Limit:        limit,
+		Offset:       offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting bookmarks", err)
+		return
+	}
+
+	// Chirps the user can't see any more are left out of the lookup, so
+	// their bookmarks are listed without a chirp
+	chirpIDs := []uuid.UUID{}
+	for _, dbBookmark := range dbBookmarks {
+		chirpIDs = append(chirpIDs, dbBookmark.ChirpID)
+	}
+	dbChirps := []database.Chirp{}
+	if len(chirpIDs) > 0 {
+		dbChirps, err = cfg.dbQueries.GetChirpsByIDs(req.Context(), database.GetChirpsByIDsParams{
+			Ids:      chirpIDs,
+			ViewerID: userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
+			return
+		}
+	}
+	chirps, err := cfg.mapChirps(req.Context(), userID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting quoted chirps", err)
+		return
+	}
+	visible := map[uuid.UUID]*Chirp{}
+	for i := range chirps {
+		visible[chirps[i].ID] = &chirps[i]
+	}
+
+	bookmarks := []Bookmark{}
+	for _, dbBookmark := range dbBookmarks {
+		bookmarks = append(bookmarks, mapBookmark(dbBookmark, visible[dbBookmark.ChirpID]))
+	}
+	respondWithJSON(w, http.StatusOK, BookmarkList{
+		Count:     count,
+		Bookmarks: bookmarks,
+	})
+}
+
+func (cfg *apiConfig) handlerAddCollection(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	name, ok := parseCollectionName(params.Name)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid collection name", nil)
+		return
+	}
+
+	// Write to database
+	dbCollection, err := cfg.dbQueries.CreateCollection(req.Context(), database.CreateCollectionParams{
+		UserID: userID,
+		Name:   name,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Collection name already in use", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating collection", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, mapCollection(dbCollection, 0))
+}
+
+func (cfg *apiConfig) handlerGetCollections(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	rows, err := cfg.dbQueries.GetCollectionsByUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting collections", err)
+		return
+	}
+
+	collections := []Collection{}
+	for _, row := range rows {
+		collections = append(collections, mapCollection(database.Collection{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			UserID:    row.UserID,
+			Name:      row.Name,
+		}, row.BookmarkCount))
+	}
+	respondWithJSON(w, http.StatusOK, collections)
+}
+
+func (cfg *apiConfig) handlerUpdateCollection(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get collection
+	collectionID, err := uuid.Parse(req.PathValue("collectionID"))
+	if err != nil || len(collectionID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid collectionID", err)
+		return
+	}
+
+	_, err = cfg.ownCollectionID(req.Context(), userID, &collectionID)
+	if err == errCollectionNotFound {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting collection", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	name, ok := parseCollectionName(params.Name)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid collection name", nil)
+		return
+	}
+
+	// Write to database
+	dbCollection, err := cfg.dbQueries.RenameCollection(req.Context(), database.RenameCollectionParams{
+		ID:   collectionID,
+		Name: name,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Collection name already in use", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating collection", err)
+		return
+	}
+
+	count, err := cfg.dbQueries.CountBookmarks(req.Context(), database.CountBookmarksParams{
+		UserID:       userID,
+		CollectionID: uuid.NullUUID{UUID: collectionID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting bookmarks", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mapCollection(dbCollection, count))
+}
+
+// handlerDeleteCollection deletes a collection. Its bookmarks are kept.
+func (cfg *apiConfig) handlerDeleteCollection(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	collectionID, err := uuid.Parse(req.PathValue("collectionID"))
+	if err != nil || len(collectionID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid collectionID", err)
+		return
+	}
+
+	_, err = cfg.ownCollectionID(req.Context(), userID, &collectionID)
+	if err == errCollectionNotFound {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting collection", err)
+		return
+	}
+
+	err = cfg.dbQueries.DeleteCollection(req.Context(), collectionID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting collection", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownCollectionID checks that an optional collection belongs to userID.
+// Other users' collections are reported as errCollectionNotFound.
+func (cfg *apiConfig) ownCollectionID(ctx context.Context, userID uuid.UUID, collectionID *uuid.UUID) (uuid.NullUUID, error) {
+	if collectionID == nil {
+		return uuid.NullUUID{}, nil
+	}
+
+	dbCollection, err := cfg.dbQueries.GetCollection(ctx, *collectionID)
+	if err == sql.ErrNoRows || (err == nil && dbCollection.UserID != userID) {
+		return uuid.NullUUID{}, errCollectionNotFound
+	} else if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: dbCollection.ID, Valid: true}, nil
+}
+
+func parseCollectionName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || len(name) > maxCollectionNameLength {
+		return "", false
+	}
+	return name, true
+}
+
+func mapBookmark(dbBookmark database.Bookmark, chirp *Chirp) Bookmark {
+	return Bookmark{
+		ID:           dbBookmark.ID,
+		CreatedAt:    dbBookmark.CreatedAt,
+		ChirpID:      dbBookmark.ChirpID,
+		CollectionID: nullUUIDPtr(dbBookmark.CollectionID),
+		Chirp:        chirp,
+	}
+}
+
+func mapCollection(dbCollection database.Collection, bookmarkCount int64) Collection {
+	return Collection{
+		ID:            dbCollection.ID,
+		CreatedAt:     dbCollection.CreatedAt,
+		UpdatedAt:     dbCollection.UpdatedAt,
+		Name:          dbCollection.Name,
+		BookmarkCount: bookmarkCount,
+	}
+}
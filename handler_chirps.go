@@ -2,28 +2,75 @@ package main
 
 import (
 	"chirpy/internal/auth"
+	"chirpy/internal/contentpolicy"
 	"chirpy/internal/database"
+	"chirpy/internal/entities"
+	"chirpy/internal/events"
+	"chirpy/internal/polls"
+	"chirpy/internal/stream"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"slices"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const maxChirpLength = 140
+
+// Authors can restore their deleted chirps for this long. Deleted chirps are
+// kept for moderation until the retention period ends, then purged.
+const (
+	chirpRestoreWindow    = 7 * 24 * time.Hour
+	defaultChirpRetention = 30 * 24 * time.Hour
+	chirpPurgeInterval    = time.Hour
+)
+
+// Followers-only chirps are visible to the author's followers, private
+// chirps only to the author. Anyone else gets a 404.
+const (
+	visibilityPublic    = "public"
+	visibilityFollowers = "followers"
+	visibilityPrivate   = "private"
+)
+
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserId    uuid.UUID `json:"user_id"`
+	ID          uuid.UUID         `json:"id"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Body        string            `json:"body"`
+	UserId      uuid.UUID         `json:"user_id"`
+	Author      *PublicUser       `json:"author"`
+	Entities    []entities.Entity `json:"entities"`
+	Media       []Media           `json:"media"`
+	QuoteOf     *uuid.UUID        `json:"quote_of"`
+	QuotedChirp *Chirp            `json:"quoted_chirp"`
+	Hidden      bool              `json:"hidden"`
+	Visibility  string            `json:"visibility"`
+	Poll        *Poll             `json:"poll"`
+	// RechirpCount counts everyone's rechirps; Rechirped is whether the
+	// viewer is one of them
+	RechirpCount int64 `json:"rechirp_count"`
+	Rechirped    bool  `json:"rechirped"`
+	// RechirpedBy and RechirpedAt are set on timeline entries that are
+	// rechirps
+	RechirpedBy *PublicUser `json:"rechirped_by,omitempty"`
+	RechirpedAt *time.Time  `json:"rechirped_at,omitempty"`
 }
 
 func (cfg *apiConfig) handlerAddChirp(w http.ResponseWriter, req *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		Body     string      `json:"body"`
+		QuoteOf  *uuid.UUID  `json:"quote_of"`
+		MediaIDs []uuid.UUID `json:"media_ids"`
+		// Visibility defaults to the author's default visibility
+		Visibility *string         `json:"visibility"`
+		Poll       *pollParameters `json:"poll"`
+		// PublishAt schedules the chirp instead of publishing it now
+		PublishAt *time.Time `json:"publish_at"`
 	}
 
 	// Authorization
@@ -49,27 +96,266 @@ func (cfg *apiConfig) handlerAddChirp(w http.ResponseWriter, req *http.Request)
 	}
 
 	// Validation
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	if params.Visibility != nil && !isValidVisibility(*params.Visibility) {
+		respondWithError(w, http.StatusBadRequest, "Invalid visibility", nil)
+		return
+	}
+	// Drafts have nowhere to keep a poll
+	if params.Poll != nil && params.PublishAt != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirps with polls can't be scheduled", nil)
+		return
+	}
+
+	chirp := newChirp{
+		UserID:   userID,
+		Body:     params.Body,
+		MediaIDs: params.MediaIDs,
+	}
+	if params.QuoteOf != nil {
+		chirp.QuoteOf = uuid.NullUUID{UUID: *params.QuoteOf, Valid: true}
+	}
+	if params.Poll != nil {
+		chirp.Poll = &newPoll{
+			Options:  params.Poll.Options,
+			ClosesAt: params.Poll.ClosesAt.UTC(),
+		}
+	}
+	checked, err := cfg.validateChirp(req.Context(), chirp)
+	if err != nil {
+		respondWithChirpValidationError(w, err)
 		return
 	}
-	cleanedBody := getCleanedBody(params.Body)
+
+	// Scheduled chirps pick up the author's default when they're published
+	if params.PublishAt != nil {
+		cfg.scheduleChirp(w, req, chirp, params.Visibility, *params.PublishAt)
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+	chirp.Visibility = chirpVisibility(params.Visibility, dbUser)
 
 	// Write to database
-	dbChirp, err := cfg.dbQueries.CreateChirp(req.Context(), database.CreateChirpParams{
-		Body:   cleanedBody,
-		UserID: userID,
-	})
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbChirp, err := createChirp(req.Context(), qtx, chirp, checked)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		return
+	}
+	cfg.publishChirpEvent(req.Context(), stream.ChirpCreated, dbChirp)
+
+	chirps, err := cfg.mapChirps(req.Context(), userID, []database.Chirp{dbChirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting quoted chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, chirps[0])
+}
+
+// newChirp is a chirp waiting to be validated and created, either from a
+// request or from a scheduled draft. Edits are validated as one too.
+type newChirp struct {
+	// ID is the chirp being edited, or uuid.Nil for a new chirp
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Body       string
+	QuoteOf    uuid.NullUUID
+	MediaIDs   []uuid.UUID
+	Visibility string
+	Poll       *newPoll
+}
+
+func isValidVisibility(visibility string) bool {
+	switch visibility {
+	case visibilityPublic, visibilityFollowers, visibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// parseVisibility validates an optional visibility from a request body.
+func parseVisibility(visibility *string) (sql.NullString, bool) {
+	if visibility == nil {
+		return sql.NullString{}, true
+	}
+	if !isValidVisibility(*visibility) {
+		return sql.NullString{}, false
+	}
+	return sql.NullString{String: *visibility, Valid: true}, true
+}
+
+// chirpVisibility returns the requested visibility, falling back to the
+// author's default.
+func chirpVisibility(requested *string, author database.User) string {
+	if requested != nil {
+		return *requested
+	}
+	return author.DefaultVisibility
+}
+
+// chirpRejectedError is a chirp that failed validation. Reason is only set
+// when the content policy rejected it.
+type chirpRejectedError struct {
+	Message string
+	Reason  contentpolicy.Reason
+}
 
-	respondWithJSON(w, http.StatusCreated, mapChirp(dbChirp))
+func (e *chirpRejectedError) Error() string {
+	if len(e.Reason) > 0 {
+		return e.Message + ": " + string(e.Reason)
+	}
+	return e.Message
+}
+
+// validateChirp runs every check a chirp must pass before it's published
+// and returns the content policy's result. Failed checks are reported as a
+// *chirpRejectedError.
+func (cfg *apiConfig) validateChirp(ctx context.Context, chirp newChirp) (contentpolicy.Result, error) {
+	if len(chirp.Body) > maxChirpLength {
+		return contentpolicy.Result{}, &chirpRejectedError{Message: "Chirp is too long"}
+	}
+
+	if chirp.Poll != nil {
+		options, err := polls.Validate(chirp.Poll.Options, chirp.Poll.ClosesAt, time.Now())
+		if err != nil {
+			return contentpolicy.Result{}, &chirpRejectedError{Message: err.Error()}
+		}
+		chirp.Poll.Options = options
+	}
+
+	checked, err := cfg.contentPolicy.Check(ctx, contentpolicy.Submission{
+		AuthorID: chirp.UserID,
+		ChirpID:  chirp.ID,
+		Body:     chirp.Body,
+	})
+	if err != nil {
+		return contentpolicy.Result{}, err
+	} else if checked.Rejected {
+		return contentpolicy.Result{}, &chirpRejectedError{
+			Message: "Chirp was rejected by the content policy",
+			Reason:  checked.Reason,
+		}
+	}
+
+	if chirp.QuoteOf.Valid {
+		// Users can't quote chirps by someone who has blocked them
+		_, err = cfg.dbQueries.GetChirpForViewer(ctx, database.GetChirpForViewerParams{
+			ID:       chirp.QuoteOf.UUID,
+			ViewerID: chirp.UserID,
+		})
+		if err == sql.ErrNoRows {
+			return contentpolicy.Result{}, &chirpRejectedError{Message: "Quoted chirp not found"}
+		} else if err != nil {
+			return contentpolicy.Result{}, err
+		}
+	}
+
+	err = cfg.validateChirpMedia(ctx, chirp.UserID, chirp.MediaIDs)
+	if errors.Is(err, errInvalidMedia) {
+		return contentpolicy.Result{}, &chirpRejectedError{Message: err.Error()}
+	} else if err != nil {
+		return contentpolicy.Result{}, err
+	}
+
+	return checked, nil
+}
+
+func respondWithChirpValidationError(w http.ResponseWriter, err error) {
+	rejected := &chirpRejectedError{}
+	if !errors.As(err, &rejected) {
+		respondWithError(w, http.StatusInternalServerError, "Error validating chirp", err)
+		return
+	}
+	if len(rejected.Reason) > 0 {
+		respondWithRejection(w, rejected.Reason)
+		return
+	}
+	respondWithError(w, http.StatusBadRequest, rejected.Message, err)
+}
+
+// createChirp saves a validated chirp along with its hashtags, mentions,
+// content policy flags, attachments and outbox event. Callers should pass
+// queries bound to a transaction.
+func createChirp(ctx context.Context, q *database.Queries, chirp newChirp, checked contentpolicy.Result) (database.Chirp, error) {
+	dbChirp, err := q.CreateChirp(ctx, database.CreateChirpParams{
+		Body:       checked.Body,
+		UserID:     chirp.UserID,
+		QuoteOf:    chirp.QuoteOf,
+		Visibility: chirp.Visibility,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = saveChirpEntities(ctx, q, dbChirp)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = events.Write(ctx, q, events.ChirpCreated{
+		ChirpID:  dbChirp.ID,
+		AuthorID: dbChirp.UserID,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = saveChirpFlags(ctx, q, dbChirp.ID, checked.Flags)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if chirp.Poll != nil {
+		err = createPoll(ctx, q, dbChirp.ID, *chirp.Poll)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	for i, mediaID := range chirp.MediaIDs {
+		err = q.CreateChirpAttachment(ctx, database.CreateChirpAttachmentParams{
+			ChirpID:  dbChirp.ID,
+			MediaID:  mediaID,
+			Position: int32(i),
+		})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	return dbChirp, nil
 }
 
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, req *http.Request) {
+	viewerID, err := cfg.getOptionalUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+	viewerIsModerator, err := cfg.isModerator(req.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+
 	sortString := req.URL.Query().Get("sort")
 	authorIDString := req.URL.Query().Get("author_id")
 
@@ -92,13 +378,20 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, req *http.Request)
 			return
 		}
 
-		dbChirps, err = cfg.dbQueries.GetChirpsByUser(req.Context(), authorID)
+		dbChirps, err = cfg.dbQueries.GetChirpsByUser(req.Context(), database.GetChirpsByUserParams{
+			UserID:            authorID,
+			ViewerID:          viewerID,
+			ViewerIsModerator: viewerIsModerator,
+		})
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
 			return
 		}
 	} else {
-		dbChirps2, err := cfg.dbQueries.GetChirps(req.Context())
+		dbChirps2, err := cfg.dbQueries.GetChirps(req.Context(), database.GetChirpsParams{
+			ViewerID:          viewerID,
+			ViewerIsModerator: viewerIsModerator,
+		})
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
 			return
@@ -114,15 +407,74 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, req *http.Request)
 		}
 	})
 
-	chirps := make([]Chirp, 0, len(dbChirps))
-	for _, dbChirp := range dbChirps {
-		chirps = append(chirps, mapChirp(dbChirp))
+	chirps, err := cfg.mapChirps(req.Context(), viewerID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting quoted chirps", err)
+		return
 	}
 
 	respondWithJSON(w, http.StatusOK, chirps)
 }
 
 func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, req *http.Request) {
+	viewerID, err := cfg.getOptionalUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+	viewerIsModerator, err := cfg.isModerator(req.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil || len(chirpID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirpID", err)
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirpForViewer(req.Context(), database.GetChirpForViewerParams{
+		ID:                chirpID,
+		ViewerID:          viewerID,
+		ViewerIsModerator: viewerIsModerator,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp", err)
+		return
+	}
+
+	chirps, err := cfg.mapChirps(req.Context(), viewerID, []database.Chirp{dbChirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting quoted chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps[0])
+}
+
+func (cfg *apiConfig) handlerUpdateChirp(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get chirp
 	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
 	if err != nil || len(chirpID) == 0 {
 		respondWithError(w, http.StatusBadRequest, "Invalid chirpID", err)
@@ -136,9 +488,95 @@ func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, req *http.Request)
 	} else if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error getting chirp", err)
 		return
+	} else if dbChirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil || len(params.Body) == 0 {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	checked, err := cfg.validateChirp(req.Context(), newChirp{
+		ID:     chirpID,
+		UserID: userID,
+		Body:   params.Body,
+	})
+	if err != nil {
+		respondWithChirpValidationError(w, err)
+		return
+	}
+
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbChirp, err = qtx.UpdateChirpBody(req.Context(), database.UpdateChirpBodyParams{
+		ID:   chirpID,
+		Body: checked.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp", err)
+		return
+	}
+
+	// Hashtags and mentions are re-extracted from the new body
+	err = qtx.DeleteChirpTags(req.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving hashtags and mentions", err)
+		return
+	}
+	err = qtx.DeleteChirpMentions(req.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving hashtags and mentions", err)
+		return
+	}
+	err = saveChirpEntities(req.Context(), qtx, dbChirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving hashtags and mentions", err)
+		return
+	}
+
+	err = saveChirpFlags(req.Context(), qtx, chirpID, checked.Flags)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error flagging chirp", err)
+		return
+	}
+
+	err = events.Write(req.Context(), qtx, events.ChirpUpdated{
+		ChirpID:  dbChirp.ID,
+		AuthorID: dbChirp.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp", err)
+		return
+	}
+	cfg.publishChirpEvent(req.Context(), stream.ChirpUpdated, dbChirp)
+
+	chirps, err := cfg.mapChirps(req.Context(), userID, []database.Chirp{dbChirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting quoted chirp", err)
+		return
 	}
 
-	respondWithJSON(w, http.StatusOK, mapChirp(dbChirp))
+	respondWithJSON(w, http.StatusOK, chirps[0])
 }
 
 func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Request) {
@@ -174,36 +612,294 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	err = cfg.dbQueries.DeleteChirp(req.Context(), chirpID)
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	err = qtx.DeleteChirp(req.Context(), chirpID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error deleting chirp", err)
 		return
 	}
 
+	err = events.Write(req.Context(), qtx, events.ChirpDeleted{
+		ChirpID:  dbChirp.ID,
+		AuthorID: dbChirp.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting chirp", err)
+		return
+	}
+	cfg.publishChirpEvent(req.Context(), stream.ChirpDeleted, dbChirp)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func mapChirp(dbChirp database.Chirp) Chirp {
-	return Chirp{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		UserId:    dbChirp.UserID,
+// mapChirps converts database chirps to API chirps, embedding their media
+// and the original chirp of any quotes. Quotes whose original has been
+// deleted or can't be seen by the viewer keep their quote_of ID but have no
+// quoted_chirp.
+func (cfg *apiConfig) mapChirps(ctx context.Context, viewerID uuid.UUID, dbChirps []database.Chirp) ([]Chirp, error) {
+	chirpIDs := []uuid.UUID{}
+	quotedIDs := []uuid.UUID{}
+	for _, dbChirp := range dbChirps {
+		chirpIDs = append(chirpIDs, dbChirp.ID)
+		if dbChirp.QuoteOf.Valid {
+			quotedIDs = append(quotedIDs, dbChirp.QuoteOf.UUID)
+		}
+	}
+
+	quoted := map[uuid.UUID]database.Chirp{}
+	if len(quotedIDs) > 0 {
+		dbQuoted, err := cfg.dbQueries.GetChirpsByIDs(ctx, database.GetChirpsByIDsParams{
+			Ids:      quotedIDs,
+			ViewerID: viewerID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, dbChirp := range dbQuoted {
+			quoted[dbChirp.ID] = dbChirp
+			chirpIDs = append(chirpIDs, dbChirp.ID)
+		}
+	}
+
+	attachments := map[uuid.UUID][]Media{}
+	if len(chirpIDs) > 0 {
+		dbAttachments, err := cfg.dbQueries.GetChirpAttachments(ctx, chirpIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range dbAttachments {
+			attachments[row.ChirpID] = append(attachments[row.ChirpID], mapMedia(database.MediaFile{
+				ID:           row.ID,
+				CreatedAt:    row.CreatedAt,
+				UserID:       row.UserID,
+				ContentType:  row.ContentType,
+				SizeBytes:    row.SizeBytes,
+				Width:        row.Width,
+				Height:       row.Height,
+				StorageKey:   row.StorageKey,
+				ThumbnailKey: row.ThumbnailKey,
+			}))
+		}
+	}
+
+	// Authors are embedded so clients don't have to look each one up
+	authorIDs := []uuid.UUID{}
+	for _, dbChirp := range dbChirps {
+		authorIDs = append(authorIDs, dbChirp.UserID)
+	}
+	for _, dbChirp := range quoted {
+		authorIDs = append(authorIDs, dbChirp.UserID)
+	}
+	authors := map[uuid.UUID]PublicUser{}
+	if len(authorIDs) > 0 {
+		dbAuthors, err := cfg.dbQueries.GetUsersByIDs(ctx, authorIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, dbAuthor := range dbAuthors {
+			authors[dbAuthor.ID] = mapPublicUser(dbAuthor)
+		}
 	}
+
+	chirpPolls := map[uuid.UUID]*Poll{}
+	if len(chirpIDs) > 0 {
+		var err error
+		chirpPolls, err = cfg.getPolls(ctx, viewerID, chirpIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rechirps := map[uuid.UUID]database.GetRechirpCountsRow{}
+	if len(chirpIDs) > 0 {
+		dbRechirps, err := cfg.dbQueries.GetRechirpCounts(ctx, database.GetRechirpCountsParams{
+			ViewerID: viewerID,
+			ChirpIds: chirpIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range dbRechirps {
+			rechirps[row.ChirpID] = row
+		}
+	}
+
+	chirps := make([]Chirp, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		chirp := mapChirp(dbChirp, attachments[dbChirp.ID])
+		chirp.Poll = chirpPolls[dbChirp.ID]
+		chirp.RechirpCount = rechirps[dbChirp.ID].RechirpCount
+		chirp.Rechirped = rechirps[dbChirp.ID].Rechirped
+		if author, ok := authors[dbChirp.UserID]; ok {
+			chirp.Author = &author
+		}
+		if original, ok := quoted[dbChirp.QuoteOf.UUID]; ok && dbChirp.QuoteOf.Valid {
+			// Only one level of quotes is embedded
+			quotedChirp := mapChirp(original, attachments[original.ID])
+			quotedChirp.Poll = chirpPolls[original.ID]
+			quotedChirp.RechirpCount = rechirps[original.ID].RechirpCount
+			quotedChirp.Rechirped = rechirps[original.ID].Rechirped
+			if author, ok := authors[original.UserID]; ok {
+				quotedChirp.Author = &author
+			}
+			chirp.QuotedChirp = &quotedChirp
+		}
+		chirps = append(chirps, chirp)
+	}
+	return chirps, nil
 }
 
-func getCleanedBody(body string) string {
-	profanities := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
+// handlerRestoreChirp undoes the deletion of one of the user's chirps, as
+// long as it was deleted within the restore window.
+func (cfg *apiConfig) handlerRestoreChirp(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
 	}
-	words := strings.Split(body, " ")
-	for i, word := range words {
-		if _, ok := profanities[strings.ToLower(word)]; ok {
-			words[i] = "****"
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get chirp
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil || len(chirpID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirpID", err)
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirpIncludingDeleted(req.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp", err)
+		return
+	} else if dbChirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "", err)
+		return
+	} else if !dbChirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Chirp is not deleted", nil)
+		return
+	}
+
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error restoring chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbChirp, err = qtx.RestoreChirp(req.Context(), database.RestoreChirpParams{
+		ID:           chirpID,
+		DeletedAfter: time.Now().UTC().Add(-chirpRestoreWindow),
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusGone, "Chirp can no longer be restored", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error restoring chirp", err)
+		return
+	}
+
+	err = events.Write(req.Context(), qtx, events.ChirpRestored{
+		ChirpID:  dbChirp.ID,
+		AuthorID: dbChirp.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error restoring chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error restoring chirp", err)
+		return
+	}
+	// Stream clients saw the chirp deleted, so it comes back as new
+	cfg.publishChirpEvent(req.Context(), stream.ChirpCreated, dbChirp)
+
+	chirps, err := cfg.mapChirps(req.Context(), userID, []database.Chirp{dbChirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting quoted chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps[0])
+}
+
+func mapChirp(dbChirp database.Chirp, media []Media) Chirp {
+	chirp := Chirp{
+		ID:         dbChirp.ID,
+		CreatedAt:  dbChirp.CreatedAt,
+		UpdatedAt:  dbChirp.UpdatedAt,
+		Body:       dbChirp.Body,
+		UserId:     dbChirp.UserID,
+		Entities:   entities.Parse(dbChirp.Body),
+		Media:      media,
+		Hidden:     dbChirp.HiddenAt.Valid,
+		Visibility: dbChirp.Visibility,
+	}
+	if chirp.Media == nil {
+		chirp.Media = []Media{}
+	}
+	if dbChirp.QuoteOf.Valid {
+		quoteOf := dbChirp.QuoteOf.UUID
+		chirp.QuoteOf = &quoteOf
+	}
+	return chirp
+}
+
+// respondWithRejection reports a chirp refused by the content policy, with
+// the policy's reason code so clients can explain it.
+func respondWithRejection(w http.ResponseWriter, reason contentpolicy.Reason) {
+	type response struct {
+		Error  string               `json:"error"`
+		Reason contentpolicy.Reason `json:"reason"`
+	}
+
+	respondWithJSON(w, http.StatusBadRequest, response{
+		Error:  "Chirp was rejected by the content policy",
+		Reason: reason,
+	})
+}
+
+// saveChirpFlags puts a chirp in the moderation queue once per flag the
+// content policy raised.
+func saveChirpFlags(ctx context.Context, q *database.Queries, chirpID uuid.UUID, flags []contentpolicy.Reason) error {
+	for _, reason := range flags {
+		reportReason := reportReasonOther
+		if reason == contentpolicy.ReasonSpam {
+			reportReason = reportReasonSpam
+		}
+		_, err := q.CreateReport(ctx, database.CreateReportParams{
+			ChirpID: chirpID,
+			Reason:  reportReason,
+			Details: "Flagged by content policy: " + string(reason),
+		})
+		if err != nil {
+			return err
 		}
 	}
-	return strings.Join(words, " ")
+	return nil
 }
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/stream"
+	"chirpy/internal/worker"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	draftStatusDraft     = "draft"
+	draftStatusScheduled = "scheduled"
+)
+
+const (
+	chirpSchedulerInterval = 15 * time.Second
+	// maxDraftAttempts is how many times a scheduled chirp is tried before
+	// it's marked failed
+	maxDraftAttempts  = 5
+	draftRetryBackoff = time.Minute
+	draftMaxBackoff   = time.Hour
+)
+
+type Draft struct {
+	ID        uuid.UUID   `json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Body      string      `json:"body"`
+	QuoteOf   *uuid.UUID  `json:"quote_of"`
+	MediaIDs  []uuid.UUID `json:"media_ids"`
+	PublishAt *time.Time  `json:"publish_at"`
+	Status    string      `json:"status"`
+	// Visibility is null when the chirp will use the author's default
+	Visibility *string `json:"visibility"`
+	// Error explains why a scheduled chirp failed to publish
+	Error string `json:"error"`
+}
+
+// draftParameters is the request body for creating and updating drafts.
+// Setting PublishAt schedules the draft.
+type draftParameters struct {
+	Body      string      `json:"body"`
+	QuoteOf   *uuid.UUID  `json:"quote_of"`
+	MediaIDs  []uuid.UUID `json:"media_ids"`
+	PublishAt *time.Time  `json:"publish_at"`
+	// Visibility defaults to the author's default when the draft is
+	// published
+	Visibility *string `json:"visibility"`
+}
+
+func (params draftParameters) chirp(userID uuid.UUID) newChirp {
+	chirp := newChirp{
+		UserID:   userID,
+		Body:     params.Body,
+		MediaIDs: params.MediaIDs,
+	}
+	if params.QuoteOf != nil {
+		chirp.QuoteOf = uuid.NullUUID{UUID: *params.QuoteOf, Valid: true}
+	}
+	if chirp.MediaIDs == nil {
+		chirp.MediaIDs = []uuid.UUID{}
+	}
+	return chirp
+}
+
+// validateDraft checks a draft and returns its status. Unscheduled drafts
+// only need to fit in a chirp. Scheduled ones get the full validation now,
+// so the author hears about problems straight away, and again when they're
+// published.
+func (cfg *apiConfig) validateDraft(ctx context.Context, chirp newChirp, visibility *string, publishAt *time.Time) (string, error) {
+	if _, ok := parseVisibility(visibility); !ok {
+		return "", &chirpRejectedError{Message: "Invalid visibility"}
+	}
+
+	if publishAt == nil {
+		if len(chirp.Body) > maxChirpLength {
+			return "", &chirpRejectedError{Message: "Chirp is too long"}
+		}
+		return draftStatusDraft, nil
+	}
+
+	if !publishAt.After(time.Now()) {
+		return "", &chirpRejectedError{Message: "publish_at must be in the future"}
+	}
+	_, err := cfg.validateChirp(ctx, chirp)
+	if err != nil {
+		return "", err
+	}
+	return draftStatusScheduled, nil
+}
+
+// scheduleChirp saves an already validated chirp to be published at
+// publishAt.
+func (cfg *apiConfig) scheduleChirp(w http.ResponseWriter, req *http.Request, chirp newChirp, visibility *string, publishAt time.Time) {
+	if !publishAt.After(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "publish_at must be in the future", nil)
+		return
+	}
+
+	if chirp.MediaIDs == nil {
+		chirp.MediaIDs = []uuid.UUID{}
+	}
+	dbDraft, err := cfg.dbQueries.CreateDraft(req.Context(), database.CreateDraftParams{
+		UserID:     chirp.UserID,
+		Body:       chirp.Body,
+		QuoteOf:    chirp.QuoteOf,
+		MediaIds:   chirp.MediaIDs,
+		PublishAt:  sql.NullTime{Time: publishAt.UTC(), Valid: true},
+		Status:     draftStatusScheduled,
+		Visibility: nullVisibility(visibility),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error scheduling chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, mapDraft(dbDraft))
+}
+
+func (cfg *apiConfig) handlerAddDraft(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := draftParameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	chirp := params.chirp(userID)
+	status, err := cfg.validateDraft(req.Context(), chirp, params.Visibility, params.PublishAt)
+	if err != nil {
+		respondWithChirpValidationError(w, err)
+		return
+	}
+
+	// Write to database
+	dbDraft, err := cfg.dbQueries.CreateDraft(req.Context(), database.CreateDraftParams{
+		UserID:     userID,
+		Body:       chirp.Body,
+		QuoteOf:    chirp.QuoteOf,
+		MediaIds:   chirp.MediaIDs,
+		PublishAt:  nullTime(params.PublishAt),
+		Status:     status,
+		Visibility: nullVisibility(params.Visibility),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating draft", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, mapDraft(dbDraft))
+}
+
+func (cfg *apiConfig) handlerGetDrafts(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	dbDrafts, err := cfg.dbQueries.GetDraftsByUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting drafts", err)
+		return
+	}
+
+	drafts := []Draft{}
+	for _, dbDraft := range dbDrafts {
+		drafts = append(drafts, mapDraft(dbDraft))
+	}
+	respondWithJSON(w, http.StatusOK, drafts)
+}
+
+func (cfg *apiConfig) handlerUpdateDraft(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get draft
+	draftID, err := uuid.Parse(req.PathValue("draftID"))
+	if err != nil || len(draftID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid draftID", err)
+		return
+	}
+
+	// Drafts are private, so other users' drafts are reported as missing
+	dbDraft, err := cfg.dbQueries.GetDraft(req.Context(), draftID)
+	if err == sql.ErrNoRows || (err == nil && dbDraft.UserID != userID) {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting draft", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := draftParameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	chirp := params.chirp(userID)
+	status, err := cfg.validateDraft(req.Context(), chirp, params.Visibility, params.PublishAt)
+	if err != nil {
+		respondWithChirpValidationError(w, err)
+		return
+	}
+
+	// Write to database
+	dbDraft, err = cfg.dbQueries.UpdateDraft(req.Context(), database.UpdateDraftParams{
+		ID:         draftID,
+		Body:       chirp.Body,
+		QuoteOf:    chirp.QuoteOf,
+		MediaIds:   chirp.MediaIDs,
+		PublishAt:  nullTime(params.PublishAt),
+		Status:     status,
+		Visibility: nullVisibility(params.Visibility),
+	})
+	if err == sql.ErrNoRows {
+		// Published by the scheduler since we read it
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating draft", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mapDraft(dbDraft))
+}
+
+func (cfg *apiConfig) handlerDeleteDraft(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get draft
+	draftID, err := uuid.Parse(req.PathValue("draftID"))
+	if err != nil || len(draftID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid draftID", err)
+		return
+	}
+
+	dbDraft, err := cfg.dbQueries.GetDraft(req.Context(), draftID)
+	if err == sql.ErrNoRows || (err == nil && dbDraft.UserID != userID) {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting draft", err)
+		return
+	}
+
+	err = cfg.dbQueries.DeleteDraft(req.Context(), draftID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting draft", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// draftPublisher publishes scheduled drafts for the scheduler.
+type draftPublisher struct {
+	cfg *apiConfig
+}
+
+// PublishNext publishes the next due draft. The draft stays locked until
+// its transaction ends, and it's deleted in the same transaction that
+// creates its chirp, so each draft is published exactly once however many
+// instances are running.
+func (p *draftPublisher) PublishNext(ctx context.Context, now time.Time) (bool, error) {
+	tx, err := p.cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := p.cfg.dbQueries.WithTx(tx)
+
+	dbDraft, err := qtx.ClaimDueDraft(ctx, now)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	dbChirp, err := p.publish(ctx, qtx, dbDraft)
+	rejected := &chirpRejectedError{}
+	if errors.As(err, &rejected) {
+		log.Printf("Scheduled chirp %s failed validation: %v", dbDraft.ID, rejected)
+		err = qtx.FailDraft(ctx, database.FailDraftParams{
+			ID:    dbDraft.ID,
+			Error: rejected.Error(),
+		})
+		if err != nil {
+			return false, err
+		}
+		return true, tx.Commit()
+	} else if err != nil {
+		// The problem may be with this draft, such as an attachment deleted
+		// since it was checked, so it's put aside rather than holding up the
+		// drafts due after it
+		tx.Rollback()
+		return true, p.retryLater(ctx, dbDraft, now, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	p.cfg.publishChirpEvent(ctx, stream.ChirpCreated, dbChirp)
+	return true, nil
+}
+
+// publish creates a draft's chirp and deletes the draft.
+func (p *draftPublisher) publish(ctx context.Context, qtx *database.Queries, dbDraft database.Draft) (database.Chirp, error) {
+	dbUser, err := qtx.GetUser(ctx, dbDraft.UserID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if isSuspended(dbUser) {
+		return database.Chirp{}, &chirpRejectedError{Message: "Account suspended"}
+	}
+
+	chirp := newChirp{
+		UserID:     dbDraft.UserID,
+		Body:       dbDraft.Body,
+		QuoteOf:    dbDraft.QuoteOf,
+		MediaIDs:   dbDraft.MediaIds,
+		Visibility: dbUser.DefaultVisibility,
+	}
+	if dbDraft.Visibility.Valid {
+		chirp.Visibility = dbDraft.Visibility.String
+	}
+	checked, err := p.cfg.validateChirp(ctx, chirp)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	dbChirp, err := createChirp(ctx, qtx, chirp, checked)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	err = qtx.DeleteDraft(ctx, dbDraft.ID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return dbChirp, nil
+}
+
+// retryLater puts off a draft that failed to publish, backing off with
+// each attempt, and marks it failed once it runs out of attempts.
+func (p *draftPublisher) retryLater(ctx context.Context, dbDraft database.Draft, now time.Time, cause error) error {
+	attempts := int(dbDraft.Attempts) + 1
+	if attempts >= maxDraftAttempts {
+		log.Printf("Giving up on scheduled chirp %s: %v", dbDraft.ID, cause)
+		return p.cfg.dbQueries.FailDraft(ctx, database.FailDraftParams{
+			ID:    dbDraft.ID,
+			Error: "Chirp couldn't be published",
+		})
+	}
+
+	log.Printf("Error publishing scheduled chirp %s, retrying: %v", dbDraft.ID, cause)
+	// Attempts must still match, in case another instance got to it first
+	return p.cfg.dbQueries.RetryDraft(ctx, database.RetryDraftParams{
+		ID:       dbDraft.ID,
+		Attempts: dbDraft.Attempts,
+		RetryAt: sql.NullTime{
+			Time:  now.Add(worker.Backoff(attempts, draftRetryBackoff, draftMaxBackoff)),
+			Valid: true,
+		},
+	})
+}
+
+func mapDraft(dbDraft database.Draft) Draft {
+	draft := Draft{
+		ID:        dbDraft.ID,
+		CreatedAt: dbDraft.CreatedAt,
+		UpdatedAt: dbDraft.UpdatedAt,
+		Body:      dbDraft.Body,
+		QuoteOf:   nullUUIDPtr(dbDraft.QuoteOf),
+		MediaIDs:  dbDraft.MediaIds,
+		Status:    dbDraft.Status,
+		Error:     dbDraft.Error,
+	}
+	if draft.MediaIDs == nil {
+		draft.MediaIDs = []uuid.UUID{}
+	}
+	if dbDraft.Visibility.Valid {
+		visibility := dbDraft.Visibility.String
+		draft.Visibility = &visibility
+	}
+	if dbDraft.PublishAt.Valid {
+		publishAt := dbDraft.PublishAt.Time
+		draft.PublishAt = &publishAt
+	}
+	return draft
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func nullVisibility(visibility *string) sql.NullString {
+	if visibility == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *visibility, Valid: true}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"chirpy/internal/clock"
+	"chirpy/internal/contentpolicy"
+	"chirpy/internal/database"
+	"chirpy/internal/scheduler"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// unavailablePolicy fails to check one body, like a policy whose backing
+// service is down.
+type unavailablePolicy struct {
+	body string
+}
+
+func (p unavailablePolicy) Check(ctx context.Context, sub contentpolicy.Submission) (contentpolicy.Decision, error) {
+	if sub.Body == p.body {
+		return contentpolicy.Decision{}, errors.New("policy unavailable")
+	}
+	return contentpolicy.Decision{Action: contentpolicy.Allow}, nil
+}
+
+func TestPublishDueDraftsSetsAsideFailures(t *testing.T) {
+	cfg := newTestAPIConfig(t)
+	cfg.contentPolicy = contentpolicy.NewPipeline(unavailablePolicy{body: "Tread lightly"})
+	ctx := context.Background()
+	now := time.Now().UTC()
+	walt, _ := createTestUser(t, cfg)
+
+	createDraft := func(body string, publishAt time.Time) database.Draft {
+		t.Helper()
+		dbDraft, err := cfg.dbQueries.CreateDraft(ctx, database.CreateDraftParams{
+			UserID:    walt.ID,
+			Body:      body,
+			MediaIds:  []uuid.UUID{},
+			PublishAt: sql.NullTime{Time: publishAt, Valid: true},
+			Status:    draftStatusScheduled,
+		})
+		if err != nil {
+			t.Fatalf("CreateDraft() error = %v", err)
+		}
+		return dbDraft
+	}
+	head := createDraft("Tread lightly", now.Add(-2*time.Hour))
+	next := createDraft("I am the one who knocks", now.Add(-time.Hour))
+
+	s := scheduler.New(&draftPublisher{cfg: cfg}, time.Minute).WithClock(clock.NewFake(now))
+	_, err := s.PublishDue(ctx)
+	if err != nil {
+		t.Fatalf("PublishDue() error = %v", err)
+	}
+
+	_, err = cfg.dbQueries.GetDraft(ctx, next.ID)
+	if err != sql.ErrNoRows {
+		t.Errorf("GetDraft(next) error = %v, want it published and deleted", err)
+	}
+	dbDraft, err := cfg.dbQueries.GetDraft(ctx, head.ID)
+	if err != nil {
+		t.Fatalf("GetDraft(head) error = %v", err)
+	}
+	if dbDraft.Status != draftStatusScheduled || dbDraft.Attempts != 1 || !dbDraft.RetryAt.Time.After(now) {
+		t.Errorf("head draft = %s with %d attempts, retry at %v, want scheduled for a retry", dbDraft.Status, dbDraft.Attempts, dbDraft.RetryAt.Time)
+	}
+}
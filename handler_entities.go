@@ -0,0 +1,109 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/entities"
+	"context"
+	"net/http"
+)
+
+func (cfg *apiConfig) handlerGetTagChirps(w http.ResponseWriter, req *http.Request) {
+	viewerID, err := cfg.getOptionalUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	tag := entities.Normalize(req.PathValue("tag"))
+	if len(tag) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid tag", nil)
+		return
+	}
+
+	dbChirps, err := cfg.dbQueries.GetChirpsByTag(req.Context(), database.GetChirpsByTagParams{
+		Tag:      tag,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
+		return
+	}
+
+	chirps, err := cfg.mapChirps(req.Context(), viewerID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting quoted chirps", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps)
+}
+
+func (cfg *apiConfig) handlerGetMentions(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	dbChirps, err := cfg.dbQueries.GetChirpsMentioningUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
+		return
+	}
+
+	chirps, err := cfg.mapChirps(req.Context(), userID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting quoted chirps", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps)
+}
+
+// saveChirpEntities indexes the hashtags and mentions in a chirp's body.
+// Mentions of handles that don't belong to any user, or of users who have
+// blocked the author, are ignored.
+func saveChirpEntities(ctx context.Context, q *database.Queries, dbChirp database.Chirp) error {
+	parsed := entities.Parse(dbChirp.Body)
+
+	for _, tag := range entities.Hashtags(parsed) {
+		err := q.CreateChirpTag(ctx, database.CreateChirpTagParams{
+			ChirpID: dbChirp.ID,
+			Tag:     tag,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	handles := entities.Mentions(parsed)
+	if len(handles) == 0 {
+		return nil
+	}
+	mentioned, err := q.GetMentionableUsers(ctx, database.GetMentionableUsersParams{
+		Handles:  handles,
+		AuthorID: dbChirp.UserID,
+	})
+	if err != nil {
+		return err
+	}
+	for _, dbUser := range mentioned {
+		err = q.CreateChirpMention(ctx, database.CreateChirpMentionParams{
+			ChirpID: dbChirp.ID,
+			UserID:  dbUser.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
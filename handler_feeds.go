@@ -0,0 +1,187 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"chirpy/internal/entities"
+	"chirpy/internal/feeds"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// feedSize is how many of the newest chirps are in a feed
+	feedSize         = 50
+	feedCacheControl = "public, max-age=300"
+)
+
+func (cfg *apiConfig) handlerGetFeedRSS(w http.ResponseWriter, req *http.Request) {
+	cfg.serveGlobalFeed(w, req, feeds.RSS)
+}
+
+func (cfg *apiConfig) handlerGetFeedAtom(w http.ResponseWriter, req *http.Request) {
+	cfg.serveGlobalFeed(w, req, feeds.Atom)
+}
+
+func (cfg *apiConfig) handlerGetUserFeedRSS(w http.ResponseWriter, req *http.Request) {
+	cfg.serveUserFeed(w, req, feeds.RSS)
+}
+
+func (cfg *apiConfig) handlerGetUserFeedAtom(w http.ResponseWriter, req *http.Request) {
+	cfg.serveUserFeed(w, req, feeds.Atom)
+}
+
+func (cfg *apiConfig) handlerGetTagFeedRSS(w http.ResponseWriter, req *http.Request) {
+	cfg.serveTagFeed(w, req, feeds.RSS)
+}
+
+func (cfg *apiConfig) handlerGetTagFeedAtom(w http.ResponseWriter, req *http.Request) {
+	cfg.serveTagFeed(w, req, feeds.Atom)
+}
+
+// Feeds only have the newest public chirps that haven't been hidden by
+// moderators, as anyone would see them without logging in.
+
+func (cfg *apiConfig) serveGlobalFeed(w http.ResponseWriter, req *http.Request, format feeds.Format) {
+	dbChirps, err := cfg.dbQueries.GetPublicChirps(req.Context(), feedSize)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
+		return
+	}
+
+	cfg.serveFeed(w, req, format, feeds.Feed{
+		Title:       "Chirpy",
+		Description: "The latest chirps on Chirpy",
+		Link:        cfg.baseURL + "/api/chirps",
+	}, dbChirps)
+}
+
+func (cfg *apiConfig) serveUserFeed(w http.ResponseWriter, req *http.Request, format feeds.Format) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(userID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+
+	dbChirps, err := cfg.dbQueries.GetPublicChirpsByUser(req.Context(), database.GetPublicChirpsByUserParams{
+		UserID: dbUser.ID,
+		Limit:  feedSize,
+		Offset: 0,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
+		return
+	}
+
+	name := feedAuthorName(dbUser)
+	cfg.serveFeed(w, req, format, feeds.Feed{
+		Title:       "Chirps by " + name,
+		Description: "The latest chirps by " + name + " on Chirpy",
+		Link:        cfg.baseURL + "/api/chirps?author_id=" + dbUser.ID.String(),
+	}, dbChirps)
+}
+
+func (cfg *apiConfig) serveTagFeed(w http.ResponseWriter, req *http.Request, format feeds.Format) {
+	tag := entities.Normalize(req.PathValue("tag"))
+	if len(tag) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid tag", nil)
+		return
+	}
+
+	dbChirps, err := cfg.dbQueries.GetPublicChirpsByTag(req.Context(), database.GetPublicChirpsByTagParams{
+		Tag:   tag,
+		Limit: feedSize,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps", err)
+		return
+	}
+
+	cfg.serveFeed(w, req, format, feeds.Feed{
+		Title:       "#" + tag,
+		Description: "The latest chirps tagged #" + tag + " on Chirpy",
+		Link:        cfg.baseURL + "/api/tags/" + url.PathEscape(tag) + "/chirps",
+	}, dbChirps)
+}
+
+// serveFeed adds chirps, newest first, to a feed and writes it, answering
+// conditional requests with 304 Not Modified.
+func (cfg *apiConfig) serveFeed(w http.ResponseWriter, req *http.Request, format feeds.Format, feed feeds.Feed, dbChirps []database.Chirp) {
+	authorIDs := []uuid.UUID{}
+	for _, dbChirp := range dbChirps {
+		if !slices.Contains(authorIDs, dbChirp.UserID) {
+			authorIDs = append(authorIDs, dbChirp.UserID)
+		}
+	}
+	dbAuthors, err := cfg.dbQueries.GetUsersByIDs(req.Context(), authorIDs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting authors", err)
+		return
+	}
+	authors := map[uuid.UUID]string{}
+	for _, dbAuthor := range dbAuthors {
+		authors[dbAuthor.ID] = feedAuthorName(dbAuthor)
+	}
+
+	feed.Self = cfg.baseURL + req.URL.Path
+	feed.ID = feed.Self
+	feed.Items = make([]feeds.Item, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		if dbChirp.UpdatedAt.After(feed.Updated) {
+			feed.Updated = dbChirp.UpdatedAt
+		}
+		feed.Items = append(feed.Items, feeds.Item{
+			ID:        "urn:uuid:" + dbChirp.ID.String(),
+			Title:     feeds.Title(dbChirp.Body),
+			Link:      cfg.baseURL + "/api/chirps/" + dbChirp.ID.String(),
+			Author:    authors[dbChirp.UserID],
+			Content:   dbChirp.Body,
+			Published: dbChirp.CreatedAt,
+			Updated:   dbChirp.UpdatedAt,
+		})
+	}
+
+	body, err := feeds.Render(feed, format)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error rendering feed", err)
+		return
+	}
+	etag := feeds.ETag(body)
+
+	w.Header().Set("Cache-Control", feedCacheControl)
+	w.Header().Set("ETag", etag)
+	if !feed.Updated.IsZero() {
+		w.Header().Set("Last-Modified", feed.Updated.UTC().Format(http.TimeFormat))
+	}
+	if feeds.NotModified(req.Header, etag, feed.Updated) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// feedAuthorName names a user without revealing their email address.
+func feedAuthorName(dbUser database.User) string {
+	if dbUser.Handle.Valid {
+		return "@" + dbUser.Handle.String
+	}
+	return fmt.Sprintf("user %s", dbUser.ID)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"chirpy/internal/contentfilter"
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FilterWord struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Word      string    `json:"word"`
+	Action    string    `json:"action"`
+}
+
+func (cfg *apiConfig) handlerGetFilterWords(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	_, ok := cfg.requireRole(w, req, roleAdmin)
+	if !ok {
+		return
+	}
+
+	dbWords, err := cfg.dbQueries.GetFilterWords(req.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting filter words", err)
+		return
+	}
+
+	words := []FilterWord{}
+	for _, dbWord := range dbWords {
+		words = append(words, mapFilterWord(dbWord))
+	}
+	respondWithJSON(w, http.StatusOK, words)
+}
+
+func (cfg *apiConfig) handlerAddFilterWord(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Word   string `json:"word"`
+		Action string `json:"action"`
+	}
+
+	// Authorization
+	_, ok := cfg.requireRole(w, req, roleAdmin)
+	if !ok {
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	word := strings.TrimSpace(contentfilter.Normalize(params.Word))
+	if len(word) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Word is required", nil)
+		return
+	}
+	if len(params.Action) == 0 {
+		params.Action = string(contentfilter.Mask)
+	}
+	if !contentfilter.Action(params.Action).Valid() {
+		respondWithError(w, http.StatusBadRequest, "Invalid action", nil)
+		return
+	}
+
+	// Write to database
+	dbWord, err := cfg.dbQueries.CreateFilterWord(req.Context(), database.CreateFilterWordParams{
+		Word:   word,
+		Action: params.Action,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Word is already filtered", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error adding filter word", err)
+		return
+	}
+
+	err = cfg.reloadContentFilter(req.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reloading content filter", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, mapFilterWord(dbWord))
+}
+
+func (cfg *apiConfig) handlerUpdateFilterWord(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Action string `json:"action"`
+	}
+
+	// Authorization
+	_, ok := cfg.requireRole(w, req, roleAdmin)
+	if !ok {
+		return
+	}
+
+	wordID, err := uuid.Parse(req.PathValue("wordID"))
+	if err != nil || len(wordID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid wordID", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	if !contentfilter.Action(params.Action).Valid() {
+		respondWithError(w, http.StatusBadRequest, "Invalid action", nil)
+		return
+	}
+
+	// Write to database
+	dbWord, err := cfg.dbQueries.UpdateFilterWord(req.Context(), database.UpdateFilterWordParams{
+		ID:     wordID,
+		Action: params.Action,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating filter word", err)
+		return
+	}
+
+	err = cfg.reloadContentFilter(req.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reloading content filter", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mapFilterWord(dbWord))
+}
+
+func (cfg *apiConfig) handlerDeleteFilterWord(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	_, ok := cfg.requireRole(w, req, roleAdmin)
+	if !ok {
+		return
+	}
+
+	wordID, err := uuid.Parse(req.PathValue("wordID"))
+	if err != nil || len(wordID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid wordID", err)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteFilterWord(req.Context(), wordID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting filter word", err)
+		return
+	} else if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "", nil)
+		return
+	}
+
+	err = cfg.reloadContentFilter(req.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reloading content filter", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reloadContentFilter rebuilds the content filter from the words in
+// CONTENT_FILTER_FILE and the words stored in the database.
+func (cfg *apiConfig) reloadContentFilter(ctx context.Context) error {
+	dbWords, err := cfg.dbQueries.GetFilterWords(ctx)
+	if err != nil {
+		return err
+	}
+
+	words := append([]contentfilter.Word{}, cfg.filterWords...)
+	for _, dbWord := range dbWords {
+		words = append(words, contentfilter.Word{
+			Word:   dbWord.Word,
+			Action: contentfilter.Action(dbWord.Action),
+		})
+	}
+	cfg.contentFilter.Replace(words)
+	return nil
+}
+
+func mapFilterWord(dbWord database.FilterWord) FilterWord {
+	return FilterWord{
+		ID:        dbWord.ID,
+		CreatedAt: dbWord.CreatedAt,
+		UpdatedAt: dbWord.UpdatedAt,
+		Word:      dbWord.Word,
+		Action:    dbWord.Action,
+	}
+}
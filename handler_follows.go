@@ -0,0 +1,219 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/events"
+	"database/sql"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type FollowList struct {
+	Count int64        `json:"count"`
+	Users []PublicUser `json:"users"`
+}
+
+func (cfg *apiConfig) handlerFollow(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get user to follow
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(followeeID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	} else if followeeID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't follow yourself", nil)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetUser(req.Context(), followeeID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+
+	blocked, err := cfg.dbQueries.IsBlocked(req.Context(), database.IsBlockedParams{
+		BlockerID: followeeID,
+		BlockedID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking blocks", err)
+		return
+	} else if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't follow this user", nil)
+		return
+	}
+
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error following user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// Following twice is a no-op, and the notifier doesn't repeat the
+	// notification
+	err = qtx.CreateFollow(req.Context(), database.CreateFollowParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error following user", err)
+		return
+	}
+
+	err = events.Write(req.Context(), qtx, events.UserFollowed{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error following user", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error following user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollow(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(followeeID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteFollow(req.Context(), database.DeleteFollowParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error unfollowing user", err)
+		return
+	} else if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetFollowers(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(userID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	}
+
+	limit, offset, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	count, err := cfg.dbQueries.CountFollowers(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting followers", err)
+		return
+	}
+
+	dbUsers, err := cfg.dbQueries.GetFollowers(req.Context(), database.GetFollowersParams{
+		FolloweeID: userID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting followers", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, FollowList{
+		Count: count,
+		Users: mapPublicUsers(dbUsers),
+	})
+}
+
+// handlerGetUserList serves the lists of a user's follows. ServeMux can't
+// register /api/users/{userID}/followers alongside
+// /api/users/by-handle/{handle}, since neither is more specific than the
+// other, so the lists share a more general route.
+func (cfg *apiConfig) handlerGetUserList(w http.ResponseWriter, req *http.Request) {
+	switch req.PathValue("list") {
+	case "followers":
+		cfg.handlerGetFollowers(w, req)
+	case "following":
+		cfg.handlerGetFollowing(w, req)
+	default:
+		respondWithError(w, http.StatusNotFound, "", nil)
+	}
+}
+
+func (cfg *apiConfig) handlerGetFollowing(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(userID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	}
+
+	limit, offset, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	count, err := cfg.dbQueries.CountFollowing(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting followed users", err)
+		return
+	}
+
+	dbUsers, err := cfg.dbQueries.GetFollowing(req.Context(), database.GetFollowingParams{
+		FollowerID: userID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting followed users", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, FollowList{
+		Count: count,
+		Users: mapPublicUsers(dbUsers),
+	})
+}
@@ -39,6 +39,11 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if isSuspended(dbUser) {
+		respondWithError(w, http.StatusForbidden, "Account suspended", nil)
+		return
+	}
+
 	token, err := auth.MakeJWT(dbUser.ID, cfg.tokenSecret, time.Hour)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error generating JWT", err)
@@ -57,16 +62,8 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	user := User{
-		ID:          dbUser.ID,
-		CreatedAt:   dbUser.CreatedAt,
-		UpdatedAt:   dbUser.UpdatedAt,
-		Email:       dbUser.Email,
-		IsChirpyRed: dbUser.IsChirpyRed,
-	}
-
 	respondWithJSON(w, http.StatusOK, response{
-		User:         user,
+		User:         mapUser(dbUser),
 		Token:        token,
 		RefreshToken: refreshToken,
 	})
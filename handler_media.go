@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/media"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxMediaBytes      = 5 << 20
+	maxMediaPixels     = 25_000_000
+	mediaThumbnailSize = 320
+	maxChirpMedia      = 4
+)
+
+var errInvalidMedia = errors.New("invalid media_ids")
+
+type Media struct {
+	ID           uuid.UUID `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int32     `json:"size_bytes"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
+
+func (cfg *apiConfig) handlerUploadMedia(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Read upload, leaving some room for the multipart framing
+	req.Body = http.MaxBytesReader(w, req.Body, maxMediaBytes+(64<<10))
+	file, _, err := req.FormFile("file")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "File is too large", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read file", err)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxMediaBytes+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read file", err)
+		return
+	} else if len(data) > maxMediaBytes {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "File is too large", nil)
+		return
+	}
+
+	// Validation
+	upload, err := media.Process(data, maxMediaPixels, mediaThumbnailSize)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Only JPEG, PNG and GIF images are supported", err)
+		return
+	} else if errors.Is(err, media.ErrTooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Image dimensions are too large", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid image", err)
+		return
+	}
+
+	// Store blobs
+	mediaID := uuid.New()
+	storageKey := mediaID.String()
+	thumbnailKey := mediaID.String() + "-thumbnail"
+
+	err = cfg.blobStore.Put(req.Context(), storageKey, bytes.NewReader(upload.Data))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error storing media", err)
+		return
+	}
+	err = cfg.blobStore.Put(req.Context(), thumbnailKey, bytes.NewReader(upload.Thumbnail))
+	if err != nil {
+		cfg.blobStore.Delete(req.Context(), storageKey)
+		respondWithError(w, http.StatusInternalServerError, "Error storing media", err)
+		return
+	}
+
+	// Write to database
+	dbMedia, err := cfg.dbQueries.CreateMediaFile(req.Context(), database.CreateMediaFileParams{
+		ID:           mediaID,
+		UserID:       userID,
+		ContentType:  upload.ContentType,
+		SizeBytes:    int32(len(upload.Data)),
+		Width:        int32(upload.Width),
+		Height:       int32(upload.Height),
+		StorageKey:   storageKey,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		cfg.blobStore.Delete(req.Context(), storageKey)
+		cfg.blobStore.Delete(req.Context(), thumbnailKey)
+		respondWithError(w, http.StatusInternalServerError, "Error saving media", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, mapMedia(dbMedia))
+}
+
+func (cfg *apiConfig) handlerGetMedia(w http.ResponseWriter, req *http.Request) {
+	cfg.serveMedia(w, req, false)
+}
+
+func (cfg *apiConfig) handlerGetMediaThumbnail(w http.ResponseWriter, req *http.Request) {
+	cfg.serveMedia(w, req, true)
+}
+
+// serveMedia writes a stored image. Blobs never change once uploaded, so
+// responses can be cached indefinitely.
+func (cfg *apiConfig) serveMedia(w http.ResponseWriter, req *http.Request, thumbnail bool) {
+	mediaID, err := uuid.Parse(req.PathValue("mediaID"))
+	if err != nil || len(mediaID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid mediaID", err)
+		return
+	}
+
+	dbMedia, err := cfg.dbQueries.GetMediaFile(req.Context(), mediaID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting media", err)
+		return
+	}
+
+	key, contentType := dbMedia.StorageKey, dbMedia.ContentType
+	if thumbnail {
+		key, contentType = dbMedia.ThumbnailKey, media.ThumbnailType(dbMedia.ContentType)
+	}
+	etag := `"` + key + `"`
+
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", etag)
+	if req.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	blob, err := cfg.blobStore.Get(req.Context(), key)
+	if errors.Is(err, media.ErrBlobNotFound) {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reading media", err)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, blob)
+}
+
+// validateChirpMedia checks that mediaIDs can be attached to a new chirp by
+// userID: there are few enough, none repeat, and the user uploaded them all.
+func (cfg *apiConfig) validateChirpMedia(ctx context.Context, userID uuid.UUID, mediaIDs []uuid.UUID) error {
+	if len(mediaIDs) > maxChirpMedia {
+		return fmt.Errorf("%w: at most %d attachments allowed", errInvalidMedia, maxChirpMedia)
+	}
+	if len(mediaIDs) == 0 {
+		return nil
+	}
+
+	seen := map[uuid.UUID]struct{}{}
+	for _, mediaID := range mediaIDs {
+		if _, ok := seen[mediaID]; ok {
+			return fmt.Errorf("%w: duplicate attachment", errInvalidMedia)
+		}
+		seen[mediaID] = struct{}{}
+	}
+
+	dbMedia, err := cfg.dbQueries.GetMediaFilesByIDs(ctx, mediaIDs)
+	if err != nil {
+		return err
+	}
+	if len(dbMedia) != len(mediaIDs) {
+		return fmt.Errorf("%w: media not found", errInvalidMedia)
+	}
+	for _, m := range dbMedia {
+		if m.UserID != userID {
+			return fmt.Errorf("%w: media not found", errInvalidMedia)
+		}
+	}
+	return nil
+}
+
+func mapMedia(dbMedia database.MediaFile) Media {
+	return Media{
+		ID:           dbMedia.ID,
+		CreatedAt:    dbMedia.CreatedAt,
+		ContentType:  dbMedia.ContentType,
+		SizeBytes:    dbMedia.SizeBytes,
+		Width:        dbMedia.Width,
+		Height:       dbMedia.Height,
+		URL:          "/media/" + dbMedia.ID.String(),
+		ThumbnailURL: "/media/" + dbMedia.ID.String() + "/thumbnail",
+	}
+}
@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bytes"
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/entitlements"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxMessageLength = 1000
+
+type Conversation struct {
+	ID           uuid.UUID    `json:"id"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	IsGroup      bool         `json:"is_group"`
+	Participants []PublicUser `json:"participants"`
+	UnreadCount  int64        `json:"unread_count"`
+}
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+// MessageList is a page of messages, newest first. Pass NextCursor as the
+// before parameter to get the next page; it's null on the last page.
+type MessageList struct {
+	Messages   []Message  `json:"messages"`
+	NextCursor *uuid.UUID `json:"next_cursor"`
+}
+
+type UnreadCount struct {
+	Count int64 `json:"count"`
+}
+
+func (cfg *apiConfig) handlerAddConversation(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+	}
+
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	participantIDs := []uuid.UUID{}
+	for _, participantID := range params.ParticipantIDs {
+		if !slices.Contains(participantIDs, participantID) {
+			participantIDs = append(participantIDs, participantID)
+		}
+	}
+	if len(participantIDs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one participant is required", nil)
+		return
+	} else if slices.Contains(participantIDs, userID) {
+		respondWithError(w, http.StatusBadRequest, "You can't add yourself to a conversation", nil)
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+	// Group sizes depend on the creator's plan
+	if len(participantIDs)+1 > entitlements.For(dbUser.IsChirpyRed).MaxConversationSize {
+		respondWithError(w, http.StatusForbidden, "Conversation is too large for your plan", nil)
+		return
+	}
+
+	for _, participantID := range participantIDs {
+		participant, err := cfg.dbQueries.GetUser(req.Context(), participantID)
+		if err == sql.ErrNoRows || (err == nil && participant.BannedAt.Valid) {
+			respondWithError(w, http.StatusBadRequest, "Participant not found", err)
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+			return
+		}
+
+		blocked, err := cfg.isBlockedEitherWay(req.Context(), userID, participantID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error checking blocks", err)
+			return
+		} else if blocked {
+			respondWithError(w, http.StatusForbidden, "You can't message this user", nil)
+			return
+		}
+	}
+
+	// Group members can't have blocked each other either
+	isGroup := len(participantIDs) > 1
+	if isGroup {
+		blocked, err := cfg.dbQueries.IsBlockedAmong(req.Context(), participantIDs)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error checking blocks", err)
+			return
+		} else if blocked {
+			respondWithError(w, http.StatusForbidden, "These users can't be in a conversation together", nil)
+			return
+		}
+	}
+
+	// One-to-one conversations are reused
+	var userLow, userHigh uuid.UUID
+	if !isGroup {
+		userLow, userHigh = directPair(userID, participantIDs[0])
+		dbConversation, err := cfg.dbQueries.GetDirectConversation(req.Context(), database.GetDirectConversationParams{
+			UserLow:  userLow,
+			UserHigh: userHigh,
+		})
+		if err == nil {
+			cfg.respondWithConversation(w, req, http.StatusOK, dbConversation)
+			return
+		} else if err != sql.ErrNoRows {
+			respondWithError(w, http.StatusInternalServerError, "Error getting conversation", err)
+			return
+		}
+	}
+
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating conversation", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	var dbConversation database.Conversation
+	if isGroup {
+		dbConversation, err = qtx.CreateConversation(req.Context(), database.CreateConversationParams{
+			CreatedBy: uuid.NullUUID{UUID: userID, Valid: true},
+			IsGroup:   true,
+		})
+	} else {
+		dbConversation, err = qtx.CreateDirectConversation(req.Context(), database.CreateDirectConversationParams{
+			CreatedBy: uuid.NullUUID{UUID: userID, Valid: true},
+			UserLow:   uuid.NullUUID{UUID: userLow, Valid: true},
+			UserHigh:  uuid.NullUUID{UUID: userHigh, Valid: true},
+		})
+		if err == sql.ErrNoRows {
+			// Another request created it since it was looked up above
+			tx.Rollback()
+			dbConversation, err = cfg.dbQueries.GetDirectConversation(req.Context(), database.GetDirectConversationParams{
+				UserLow:  userLow,
+				UserHigh: userHigh,
+			})
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Error getting conversation", err)
+				return
+			}
+			cfg.respondWithConversation(w, req, http.StatusOK, dbConversation)
+			return
+		}
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating conversation", err)
+		return
+	}
+
+	for _, participantID := range append([]uuid.UUID{userID}, participantIDs...) {
+		err = qtx.AddConversationParticipant(req.Context(), database.AddConversationParticipantParams{
+			ConversationID: dbConversation.ID,
+			UserID:         participantID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error creating conversation", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating conversation", err)
+		return
+	}
+
+	cfg.respondWithConversation(w, req, http.StatusCreated, dbConversation)
+}
+
+func (cfg *apiConfig) handlerGetConversations(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	limit, offset, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	rows, err := cfg.dbQueries.GetConversationsForParticipant(req.Context(), database.GetConversationsForParticipantParams{
+		UserID: userID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting conversations", err)
+		return
+	}
+
+	conversations := make([]Conversation, 0, len(rows))
+	for _, row := range rows {
+		conversations = append(conversations, Conversation{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			IsGroup:     row.IsGroup,
+			UnreadCount: row.UnreadCount,
+		})
+	}
+	err = cfg.addConversationParticipants(req.Context(), conversations)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting participants", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, conversations)
+}
+
+func (cfg *apiConfig) handlerGetUnreadCount(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	count, err := cfg.dbQueries.CountUnreadMessages(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting unread messages", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, UnreadCount{
+		Count: count,
+	})
+}
+
+func (cfg *apiConfig) handlerSendMessage(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get conversation
+	conversationID, ok := cfg.participantConversationID(w, req, userID)
+	if !ok {
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	if len(params.Body) == 0 || len(params.Body) > maxMessageLength {
+		respondWithError(w, http.StatusBadRequest, "Message must be between 1 and 1000 characters", nil)
+		return
+	}
+
+	// Anyone who has blocked the sender since the conversation started
+	// stops it for the sender
+	blocked, err := cfg.dbQueries.IsBlockedByParticipant(req.Context(), database.IsBlockedByParticipantParams{
+		ConversationID: conversationID,
+		BlockedID:      userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking blocks", err)
+		return
+	} else if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't message this conversation", nil)
+		return
+	}
+
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error sending message", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbMessage, err := qtx.CreateMessage(req.Context(), database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Body:           params.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error sending message", err)
+		return
+	}
+
+	err = qtx.TouchConversation(req.Context(), conversationID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error sending message", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error sending message", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, mapMessage(dbMessage))
+}
+
+func (cfg *apiConfig) handlerGetMessages(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	conversationID, ok := cfg.participantConversationID(w, req, userID)
+	if !ok {
+		return
+	}
+
+	limit, err := parseLimit(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	// The cursor is the ID of the oldest message on the previous page
+	params := database.GetMessagesParams{
+		ConversationID: conversationID,
+		ViewerID:       userID,
+		Limit:          limit,
+	}
+	if beforeString := req.URL.Query().Get("before"); len(beforeString) > 0 {
+		beforeID, err := uuid.Parse(beforeString)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+			return
+		}
+		before, err := cfg.dbQueries.GetMessage(req.Context(), beforeID)
+		if err == sql.ErrNoRows || (err == nil && before.ConversationID != conversationID) {
+			respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting messages", err)
+			return
+		}
+		params.BeforeCreatedAt = sql.NullTime{Time: before.CreatedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: before.ID, Valid: true}
+	}
+
+	dbMessages, err := cfg.dbQueries.GetMessages(req.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting messages", err)
+		return
+	}
+
+	list := MessageList{
+		Messages: make([]Message, 0, len(dbMessages)),
+	}
+	for _, dbMessage := range dbMessages {
+		list.Messages = append(list.Messages, mapMessage(dbMessage))
+	}
+	if len(dbMessages) == int(limit) {
+		nextCursor := dbMessages[len(dbMessages)-1].ID
+		list.NextCursor = &nextCursor
+	}
+
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+func (cfg *apiConfig) handlerMarkConversationRead(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	conversationID, ok := cfg.participantConversationID(w, req, userID)
+	if !ok {
+		return
+	}
+
+	err = cfg.dbQueries.MarkConversationRead(req.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error marking conversation read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// participantConversationID reads the conversation from the path and
+// checks userID takes part in it. Conversations are private, so anyone
+// else gets a 404. It writes its own error responses.
+func (cfg *apiConfig) participantConversationID(w http.ResponseWriter, req *http.Request, userID uuid.UUID) (uuid.UUID, bool) {
+	conversationID, err := uuid.Parse(req.PathValue("conversationID"))
+	if err != nil || len(conversationID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversationID", err)
+		return uuid.Nil, false
+	}
+
+	_, err = cfg.dbQueries.GetConversationForParticipant(req.Context(), database.GetConversationForParticipantParams{
+		ID:     conversationID,
+		UserID: userID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return uuid.Nil, false
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting conversation", err)
+		return uuid.Nil, false
+	}
+	return conversationID, true
+}
+
+func (cfg *apiConfig) isBlockedEitherWay(ctx context.Context, userA, userB uuid.UUID) (bool, error) {
+	for _, params := range []database.IsBlockedParams{
+		{BlockerID: userA, BlockedID: userB},
+		{BlockerID: userB, BlockedID: userA},
+	} {
+		blocked, err := cfg.dbQueries.IsBlocked(ctx, params)
+		if err != nil || blocked {
+			return blocked, err
+		}
+	}
+	return false, nil
+}
+
+// directPair orders the users in a one-to-one conversation the way the
+// database stores them, lowest ID first.
+func directPair(userA, userB uuid.UUID) (uuid.UUID, uuid.UUID) {
+	if bytes.Compare(userA[:], userB[:]) < 0 {
+		return userA, userB
+	}
+	return userB, userA
+}
+
+func (cfg *apiConfig) respondWithConversation(w http.ResponseWriter, req *http.Request, code int, dbConversation database.Conversation) {
+	conversations := []Conversation{{
+		ID:        dbConversation.ID,
+		CreatedAt: dbConversation.CreatedAt,
+		UpdatedAt: dbConversation.UpdatedAt,
+		IsGroup:   dbConversation.IsGroup,
+	}}
+	err := cfg.addConversationParticipants(req.Context(), conversations)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting participants", err)
+		return
+	}
+
+	respondWithJSON(w, code, conversations[0])
+}
+
+// addConversationParticipants fills in the participants of each
+// conversation.
+func (cfg *apiConfig) addConversationParticipants(ctx context.Context, conversations []Conversation) error {
+	if len(conversations) == 0 {
+		return nil
+	}
+
+	conversationIDs := make([]uuid.UUID, 0, len(conversations))
+	for _, conversation := range conversations {
+		conversationIDs = append(conversationIDs, conversation.ID)
+	}
+
+	rows, err := cfg.dbQueries.GetConversationParticipants(ctx, conversationIDs)
+	if err != nil {
+		return err
+	}
+	participants := map[uuid.UUID][]PublicUser{}
+	for _, row := range rows {
+		participants[row.ConversationID] = append(participants[row.ConversationID], PublicUser{
+			ID:          row.ID,
+			Handle:      row.Handle.String,
+			DisplayName: row.DisplayName,
+			AvatarURL:   avatarURL(row.AvatarID),
+			IsChirpyRed: row.IsChirpyRed,
+		})
+	}
+
+	for i := range conversations {
+		conversations[i].Participants = participants[conversations[i].ID]
+	}
+	return nil
+}
+
+func mapMessage(dbMessage database.Message) Message {
+	return Message{
+		ID:             dbMessage.ID,
+		CreatedAt:      dbMessage.CreatedAt,
+		ConversationID: dbMessage.ConversationID,
+		SenderID:       dbMessage.SenderID,
+		Body:           dbMessage.Body,
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/events"
+	"chirpy/internal/notifications"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxNotificationActors is how many of a notification's actors are listed.
+// The rest are only counted.
+const maxNotificationActors = 3
+
+// Notification groups events of the same type, such as everyone who
+// rechirped a chirp since the user last read their notifications.
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Type      string     `json:"type"`
+	ChirpID   *uuid.UUID `json:"chirp_id"`
+	// Actors are the most recent first
+	Actors     []PublicUser `json:"actors"`
+	ActorCount int64        `json:"actor_count"`
+	ReadAt     *time.Time   `json:"read_at"`
+}
+
+type NotificationList struct {
+	Count         int64          `json:"count"`
+	UnreadCount   int64          `json:"unread_count"`
+	Notifications []Notification `json:"notifications"`
+}
+
+func (cfg *apiConfig) handlerGetNotifications(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	limit, offset, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	count, err := cfg.dbQueries.CountNotifications(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting notifications", err)
+		return
+	}
+
+	unreadCount, err := cfg.dbQueries.CountUnreadNotifications(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting notifications", err)
+		return
+	}
+
+	dbNotifications, err := cfg.dbQueries.GetNotifications(req.Context(), database.GetNotificationsParams{
+		UserID: userID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting notifications", err)
+		return
+	}
+
+	notificationIDs := make([]uuid.UUID, 0, len(dbNotifications))
+	for _, dbNotification := range dbNotifications {
+		notificationIDs = append(notificationIDs, dbNotification.ID)
+	}
+	actors := map[uuid.UUID][]database.User{}
+	if len(notificationIDs) > 0 {
+		rows, err := cfg.dbQueries.GetNotificationActors(req.Context(), notificationIDs)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting notification actors", err)
+			return
+		}
+		for _, row := range rows {
+			if len(actors[row.NotificationID]) == maxNotificationActors {
+				continue
+			}
+			actors[row.NotificationID] = append(actors[row.NotificationID], row.User)
+		}
+	}
+
+	list := NotificationList{
+		Count:         count,
+		UnreadCount:   unreadCount,
+		Notifications: make([]Notification, 0, len(dbNotifications)),
+	}
+	for _, dbNotification := range dbNotifications {
+		notification := Notification{
+			ID:         dbNotification.ID,
+			CreatedAt:  dbNotification.CreatedAt,
+			UpdatedAt:  dbNotification.UpdatedAt,
+			Type:       dbNotification.Type,
+			ChirpID:    nullUUIDPtr(dbNotification.ChirpID),
+			Actors:     mapPublicUsers(actors[dbNotification.ID]),
+			ActorCount: dbNotification.ActorCount,
+		}
+		if dbNotification.ReadAt.Valid {
+			readAt := dbNotification.ReadAt.Time
+			notification.ReadAt = &readAt
+		}
+		list.Notifications = append(list.Notifications, notification)
+	}
+
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// handlerMarkNotificationsRead marks the listed notifications as read, or
+// all of them if none are listed. Later events start new notifications
+// rather than joining read ones.
+func (cfg *apiConfig) handlerMarkNotificationsRead(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Decode request. The body is optional.
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Write to database
+	if len(params.IDs) == 0 {
+		_, err = cfg.dbQueries.MarkAllNotificationsRead(req.Context(), userID)
+	} else {
+		_, err = cfg.dbQueries.MarkNotificationsRead(req.Context(), database.MarkNotificationsReadParams{
+			UserID: userID,
+			Ids:    params.IDs,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error marking notifications read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// NotificationPreferences says whether each notification type is delivered
+// on each channel, keyed by type and then channel.
+type NotificationPreferences map[string]map[string]bool
+
+func (cfg *apiConfig) handlerGetNotificationPreferences(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	cfg.respondWithNotificationPreferences(w, req, userID)
+}
+
+// handlerUpdateNotificationPreferences saves the types and channels in the
+// request and leaves the rest unchanged.
+func (cfg *apiConfig) handlerUpdateNotificationPreferences(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := NotificationPreferences{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	for eventType, channels := range params {
+		if !notifications.IsValidType(eventType) {
+			respondWithError(w, http.StatusBadRequest, "Invalid notification type", nil)
+			return
+		}
+		for channel := range channels {
+			if !cfg.notifier.HasChannel(channel) {
+				respondWithError(w, http.StatusBadRequest, "Invalid notification channel", nil)
+				return
+			}
+		}
+	}
+
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving preferences", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	for eventType, channels := range params {
+		for channel, enabled := range channels {
+			err = qtx.SetNotificationPreference(req.Context(), database.SetNotificationPreferenceParams{
+				UserID:  userID,
+				Type:    eventType,
+				Channel: channel,
+				Enabled: enabled,
+			})
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Error saving preferences", err)
+				return
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving preferences", err)
+		return
+	}
+
+	cfg.respondWithNotificationPreferences(w, req, userID)
+}
+
+func (cfg *apiConfig) respondWithNotificationPreferences(w http.ResponseWriter, req *http.Request, userID uuid.UUID) {
+	rows, err := cfg.dbQueries.GetNotificationPreferences(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting preferences", err)
+		return
+	}
+
+	preferences := make([]notifications.Preference, 0, len(rows))
+	for _, row := range rows {
+		preferences = append(preferences, notifications.Preference{
+			Type:    row.Type,
+			Channel: row.Channel,
+			Enabled: row.Enabled,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, NotificationPreferences(cfg.notifier.Settings(preferences)))
+}
+
+// notify delivers notifications from outbox subscribers, after the change
+// that caused them is committed. Failures are logged rather than retried,
+// since channels may already have delivered some of them.
+func (cfg *apiConfig) notify(ctx context.Context, events ...notifications.Event) {
+	for _, event := range events {
+		err := cfg.notifier.Notify(ctx, event)
+		if err != nil {
+			log.Printf("Error sending %s notification to %s: %v", event.Type, event.RecipientID, err)
+		}
+	}
+}
+
+// notifyChirpCreated tells users a new chirp mentions them or quotes one of
+// their chirps, if they're allowed to see it. It's an outbox subscriber, so
+// it may see the same chirp twice; the notifier skips repeats.
+func (cfg *apiConfig) notifyChirpCreated(ctx context.Context, event events.ChirpCreated) error {
+	dbChirp, err := cfg.dbQueries.GetChirp(ctx, event.ChirpID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	pending := []notifications.Event{}
+
+	mentionedIDs, err := cfg.dbQueries.GetMentionedUserIDs(ctx, dbChirp.ID)
+	if err != nil {
+		return err
+	}
+	for _, mentionedID := range mentionedIDs {
+		pending = append(pending, notifications.Event{
+			Type:        notifications.TypeMention,
+			RecipientID: mentionedID,
+			ActorID:     dbChirp.UserID,
+			ChirpID:     dbChirp.ID,
+		})
+	}
+
+	if dbChirp.QuoteOf.Valid {
+		quoted, err := cfg.dbQueries.GetChirp(ctx, dbChirp.QuoteOf.UUID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		} else if err == nil {
+			pending = append(pending, notifications.Event{
+				Type:        notifications.TypeQuote,
+				RecipientID: quoted.UserID,
+				ActorID:     dbChirp.UserID,
+				ChirpID:     quoted.ID,
+			})
+		}
+	}
+
+	visible := make([]notifications.Event, 0, len(pending))
+	for _, notification := range pending {
+		canSee, err := cfg.dbQueries.IsChirpVisibleTo(ctx, database.IsChirpVisibleToParams{
+			ChirpID:  dbChirp.ID,
+			ViewerID: notification.RecipientID,
+		})
+		if err != nil {
+			return err
+		}
+		if canSee {
+			visible = append(visible, notification)
+		}
+	}
+	cfg.notify(ctx, visible...)
+	return nil
+}
+
+// notifyUserFollowed tells a user about a new follower. It's an outbox
+// subscriber; the notifier skips repeats.
+func (cfg *apiConfig) notifyUserFollowed(ctx context.Context, event events.UserFollowed) error {
+	cfg.notify(ctx, notifications.Event{
+		Type:        notifications.TypeFollow,
+		RecipientID: event.FolloweeID,
+		ActorID:     event.FollowerID,
+	})
+	return nil
+}
+
+// notifyChirpRechirped tells a user one of their chirps was rechirped. It's
+// an outbox subscriber; the notifier skips repeats.
+func (cfg *apiConfig) notifyChirpRechirped(ctx context.Context, event events.ChirpRechirped) error {
+	cfg.notify(ctx, notifications.Event{
+		Type:        notifications.TypeRechirp,
+		RecipientID: event.AuthorID,
+		ActorID:     event.UserID,
+		ChirpID:     event.ChirpID,
+	})
+	return nil
+}
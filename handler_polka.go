@@ -2,6 +2,7 @@ package main
 
 import (
 	"chirpy/internal/auth"
+	"chirpy/internal/events"
 	"database/sql"
 	"encoding/json"
 	"net/http"
@@ -57,7 +58,27 @@ func (cfg *apiConfig) handlerPolkaWebhooks(w http.ResponseWriter, req *http.Requ
 	}
 
 	if !dbUser.IsChirpyRed {
-		dbUser, err = cfg.dbQueries.UpdateUserToChirpyRed(req.Context(), dbUser.ID)
+		tx, err := cfg.db.BeginTx(req.Context(), nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+		qtx := cfg.dbQueries.WithTx(tx)
+
+		dbUser, err = qtx.UpdateUserToChirpyRed(req.Context(), dbUser.ID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		err = events.Write(req.Context(), qtx, events.UserUpgraded{UserID: dbUser.ID})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		err = tx.Commit()
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const pollCloseInterval = time.Minute
+
+// Poll is a chirp's poll as seen by one viewer. Vote counts are null until
+// the viewer has voted or the poll has closed.
+type Poll struct {
+	ID            uuid.UUID    `json:"id"`
+	ClosesAt      time.Time    `json:"closes_at"`
+	Closed        bool         `json:"closed"`
+	Options       []PollOption `json:"options"`
+	TotalVotes    *int64       `json:"total_votes"`
+	VotedOptionID *uuid.UUID   `json:"voted_option_id"`
+}
+
+type PollOption struct {
+	ID    uuid.UUID `json:"id"`
+	Text  string    `json:"text"`
+	Votes *int64    `json:"votes"`
+}
+
+type pollParameters struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+// newPoll is a poll waiting to be validated and created with its chirp.
+type newPoll struct {
+	Options  []string
+	ClosesAt time.Time
+}
+
+func createPoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, poll newPoll) error {
+	dbPoll, err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: poll.ClosesAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, option := range poll.Options {
+		_, err = q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			PollID:   dbPoll.ID,
+			Position: int32(i),
+			Text:     option,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerVotePoll(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		OptionID uuid.UUID `json:"option_id"`
+	}
+
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get poll
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil || len(chirpID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirpID", err)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetChirpForViewer(req.Context(), database.GetChirpForViewerParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp", err)
+		return
+	}
+
+	dbPoll, err := cfg.dbQueries.GetPollByChirp(req.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting poll", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	if isPollClosed(dbPoll, time.Now().UTC()) {
+		respondWithError(w, http.StatusConflict, "Poll is closed", nil)
+		return
+	}
+
+	dbOptions, err := cfg.dbQueries.GetPollOptionResults(req.Context(), []uuid.UUID{dbPoll.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting poll options", err)
+		return
+	}
+	validOption := false
+	for _, dbOption := range dbOptions {
+		if dbOption.ID == params.OptionID {
+			validOption = true
+		}
+	}
+	if !validOption {
+		respondWithError(w, http.StatusBadRequest, "Invalid option_id", nil)
+		return
+	}
+
+	// Write to database
+	err = cfg.dbQueries.CreatePollVote(req.Context(), database.CreatePollVoteParams{
+		PollID:   dbPoll.ID,
+		UserID:   userID,
+		OptionID: params.OptionID,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Already voted", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error saving vote", err)
+		return
+	}
+
+	polls, err := cfg.getPolls(req.Context(), userID, []uuid.UUID{chirpID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting poll", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, polls[chirpID])
+}
+
+// getPolls returns the polls attached to chirpIDs as seen by viewerID,
+// keyed by chirp ID. Chirps without a poll are left out.
+func (cfg *apiConfig) getPolls(ctx context.Context, viewerID uuid.UUID, chirpIDs []uuid.UUID) (map[uuid.UUID]*Poll, error) {
+	polls := map[uuid.UUID]*Poll{}
+
+	dbPolls, err := cfg.dbQueries.GetPollsByChirpIDs(ctx, chirpIDs)
+	if err != nil || len(dbPolls) == 0 {
+		return polls, err
+	}
+
+	pollIDs := []uuid.UUID{}
+	for _, dbPoll := range dbPolls {
+		pollIDs = append(pollIDs, dbPoll.ID)
+	}
+
+	dbOptions, err := cfg.dbQueries.GetPollOptionResults(ctx, pollIDs)
+	if err != nil {
+		return nil, err
+	}
+	options := map[uuid.UUID][]database.GetPollOptionResultsRow{}
+	for _, dbOption := range dbOptions {
+		options[dbOption.PollID] = append(options[dbOption.PollID], dbOption)
+	}
+
+	// Anonymous viewers haven't voted
+	dbVotes, err := cfg.dbQueries.GetPollVotesByUser(ctx, database.GetPollVotesByUserParams{
+		UserID:  viewerID,
+		PollIds: pollIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	votes := map[uuid.UUID]uuid.UUID{}
+	for _, dbVote := range dbVotes {
+		votes[dbVote.PollID] = dbVote.OptionID
+	}
+
+	now := time.Now().UTC()
+	for _, dbPoll := range dbPolls {
+		poll := &Poll{
+			ID:       dbPoll.ID,
+			ClosesAt: dbPoll.ClosesAt,
+			Closed:   isPollClosed(dbPoll, now),
+			Options:  []PollOption{},
+		}
+		votedOptionID, voted := votes[dbPoll.ID]
+		if voted {
+			poll.VotedOptionID = &votedOptionID
+		}
+
+		showResults := voted || poll.Closed
+		total := int64(0)
+		for _, dbOption := range options[dbPoll.ID] {
+			option := PollOption{
+				ID:   dbOption.ID,
+				Text: dbOption.Text,
+			}
+			if showResults {
+				count := dbOption.Votes
+				option.Votes = &count
+				total += count
+			}
+			poll.Options = append(poll.Options, option)
+		}
+		if showResults {
+			poll.TotalVotes = &total
+		}
+
+		polls[dbPoll.ChirpID] = poll
+	}
+	return polls, nil
+}
+
+// isPollClosed reports whether a poll has closed. Polls stop taking votes
+// at their closing time even if the background job hasn't caught up yet.
+func isPollClosed(dbPoll database.Poll, now time.Time) bool {
+	return dbPoll.ClosedAt.Valid || !dbPoll.ClosesAt.After(now)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/events"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type Rechirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Chirp     Chirp     `json:"chirp"`
+}
+
+func (cfg *apiConfig) handlerAddRechirp(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Get chirp
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil || len(chirpID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirpID", err)
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirpForViewer(req.Context(), database.GetChirpForViewerParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp", err)
+		return
+	}
+	if dbChirp.Visibility != visibilityPublic {
+		respondWithError(w, http.StatusBadRequest, "Only public chirps can be rechirped", nil)
+		return
+	}
+
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating rechirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbRechirp, err := qtx.CreateRechirp(req.Context(), database.CreateRechirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Chirp already rechirped", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating rechirp", err)
+		return
+	}
+
+	err = events.Write(req.Context(), qtx, events.ChirpRechirped{
+		ChirpID:  dbChirp.ID,
+		AuthorID: dbChirp.UserID,
+		UserID:   userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating rechirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating rechirp", err)
+		return
+	}
+
+	chirps, err := cfg.mapChirps(req.Context(), userID, []database.Chirp{dbChirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting quoted chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, Rechirp{
+		ID:        dbRechirp.ID,
+		CreatedAt: dbRechirp.CreatedAt,
+		UserID:    dbRechirp.UserID,
+		Chirp:     chirps[0],
+	})
+}
+
+func (cfg *apiConfig) handlerDeleteRechirp(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil || len(chirpID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirpID", err)
+		return
+	}
+
+	// Rechirps of deleted chirps are kept until the chirp is purged, so they
+	// come back if it's restored and can still be undone meanwhile
+	deleted, err := cfg.dbQueries.DeleteRechirp(req.Context(), database.DeleteRechirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting rechirp", err)
+		return
+	} else if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
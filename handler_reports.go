@@ -0,0 +1,406 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/events"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	reportReasonSpam           = "spam"
+	reportReasonHarassment     = "harassment"
+	reportReasonHate           = "hate"
+	reportReasonViolence       = "violence"
+	reportReasonSexual         = "sexual"
+	reportReasonMisinformation = "misinformation"
+	reportReasonOther          = "other"
+)
+
+var reportReasons = map[string]struct{}{
+	reportReasonSpam:           {},
+	reportReasonHarassment:     {},
+	reportReasonHate:           {},
+	reportReasonViolence:       {},
+	reportReasonSexual:         {},
+	reportReasonMisinformation: {},
+	reportReasonOther:          {},
+}
+
+const (
+	reportStatusOpen      = "open"
+	reportStatusResolved  = "resolved"
+	reportStatusDismissed = "dismissed"
+)
+
+const (
+	moderationActionHideChirp   = "hide_chirp"
+	moderationActionSuspendUser = "suspend_user"
+	moderationActionDismiss     = "dismiss"
+)
+
+const defaultSuspension = 7 * 24 * time.Hour
+
+type Report struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	// ReporterID is nil for reports raised by the content policy
+	ReporterID *uuid.UUID `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+}
+
+type ModerationAction struct {
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ModeratorID  *uuid.UUID `json:"moderator_id"`
+	ReportID     *uuid.UUID `json:"report_id"`
+	ChirpID      *uuid.UUID `json:"chirp_id"`
+	TargetUserID *uuid.UUID `json:"target_user_id"`
+	Action       string     `json:"action"`
+	Note         string     `json:"note"`
+}
+
+func (cfg *apiConfig) handlerReportChirp(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil || len(chirpID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirpID", err)
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirpForViewer(req.Context(), database.GetChirpForViewerParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	if dbChirp.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't report your own chirp", nil)
+		return
+	}
+	if _, ok := reportReasons[params.Reason]; !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid reason", nil)
+		return
+	}
+	const maxDetailsLength = 1000
+	if len(params.Details) > maxDetailsLength {
+		respondWithError(w, http.StatusBadRequest, "Details are too long", nil)
+		return
+	}
+
+	// Write to database
+	dbReport, err := cfg.dbQueries.CreateReport(req.Context(), database.CreateReportParams{
+		ChirpID:    chirpID,
+		ReporterID: uuid.NullUUID{UUID: userID, Valid: true},
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "You have already reported this chirp", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error reporting chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, mapReport(dbReport))
+}
+
+func (cfg *apiConfig) handlerGetReports(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	_, ok := cfg.requireRole(w, req, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	status := req.URL.Query().Get("status")
+	if len(status) == 0 {
+		status = reportStatusOpen
+	}
+	if status != reportStatusOpen && status != reportStatusResolved && status != reportStatusDismissed {
+		respondWithError(w, http.StatusBadRequest, "Invalid status", nil)
+		return
+	}
+
+	limit, offset, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	dbReports, err := cfg.dbQueries.GetReportsByStatus(req.Context(), database.GetReportsByStatusParams{
+		Status: status,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting reports", err)
+		return
+	}
+
+	reports := []Report{}
+	for _, dbReport := range dbReports {
+		reports = append(reports, mapReport(dbReport))
+	}
+	respondWithJSON(w, http.StatusOK, reports)
+}
+
+func (cfg *apiConfig) handlerModerateReport(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+		// SuspendedUntil applies to suspend_user and defaults to a week
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}
+
+	// Authorization
+	moderator, ok := cfg.requireRole(w, req, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(req.PathValue("reportID"))
+	if err != nil || len(reportID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid reportID", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	status := reportStatusResolved
+	switch params.Action {
+	case moderationActionHideChirp:
+	case moderationActionSuspendUser:
+		if params.SuspendedUntil == nil {
+			suspendedUntil := time.Now().Add(defaultSuspension)
+			params.SuspendedUntil = &suspendedUntil
+		} else if params.SuspendedUntil.Before(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "suspended_until must be in the future", nil)
+			return
+		}
+	case moderationActionDismiss:
+		status = reportStatusDismissed
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid action", nil)
+		return
+	}
+
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error moderating report", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// Only open reports can be acted on, so two moderators can't both
+	// close the same report
+	dbReport, err := qtx.ResolveReport(req.Context(), database.ResolveReportParams{
+		ID:     reportID,
+		Status: status,
+	})
+	if err == sql.ErrNoRows {
+		_, err = qtx.GetReport(req.Context(), reportID)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "", err)
+			return
+		}
+		respondWithError(w, http.StatusConflict, "Report is already closed", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error moderating report", err)
+		return
+	}
+
+	dbChirp, err := qtx.GetChirpIncludingDeleted(req.Context(), dbReport.ChirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp", err)
+		return
+	}
+
+	switch params.Action {
+	case moderationActionHideChirp:
+		err = qtx.HideChirp(req.Context(), dbChirp.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error hiding chirp", err)
+			return
+		}
+		err = events.Write(req.Context(), qtx, events.ChirpHidden{
+			ChirpID:  dbChirp.ID,
+			AuthorID: dbChirp.UserID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error hiding chirp", err)
+			return
+		}
+		// Other reports about the chirp are settled by hiding it
+		err = qtx.ResolveChirpReports(req.Context(), dbChirp.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error moderating report", err)
+			return
+		}
+	case moderationActionSuspendUser:
+		dbAuthor, err := qtx.GetUser(req.Context(), dbChirp.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+			return
+		}
+		if dbAuthor.Role != roleUser && moderator.Role != roleAdmin {
+			respondWithError(w, http.StatusForbidden, "Only admins can suspend staff", nil)
+			return
+		}
+
+		reason := params.Note
+		if len(reason) == 0 {
+			reason = "Reported for " + dbReport.Reason
+		}
+		_, err = qtx.SuspendUser(req.Context(), database.SuspendUserParams{
+			ID:               dbChirp.UserID,
+			SuspendedUntil:   sql.NullTime{Time: params.SuspendedUntil.UTC(), Valid: true},
+			SuspensionReason: reason,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error suspending user", err)
+			return
+		}
+		err = qtx.RevokeUserRefreshTokens(req.Context(), dbChirp.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error revoking refresh tokens", err)
+			return
+		}
+	}
+
+	dbAction, err := qtx.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		ReportID:     uuid.NullUUID{UUID: dbReport.ID, Valid: true},
+		ChirpID:      uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: dbChirp.UserID, Valid: true},
+		Action:       params.Action,
+		Note:         params.Note,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error recording moderation action", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error moderating report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mapModerationAction(dbAction))
+}
+
+func (cfg *apiConfig) handlerGetModerationActions(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	_, ok := cfg.requireRole(w, req, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	dbActions, err := cfg.dbQueries.GetModerationActions(req.Context(), database.GetModerationActionsParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting moderation actions", err)
+		return
+	}
+
+	actions := []ModerationAction{}
+	for _, dbAction := range dbActions {
+		actions = append(actions, mapModerationAction(dbAction))
+	}
+	respondWithJSON(w, http.StatusOK, actions)
+}
+
+func mapReport(dbReport database.Report) Report {
+	return Report{
+		ID:         dbReport.ID,
+		CreatedAt:  dbReport.CreatedAt,
+		UpdatedAt:  dbReport.UpdatedAt,
+		ChirpID:    dbReport.ChirpID,
+		ReporterID: nullUUIDPtr(dbReport.ReporterID),
+		Reason:     dbReport.Reason,
+		Details:    dbReport.Details,
+		Status:     dbReport.Status,
+	}
+}
+
+func mapModerationAction(dbAction database.ModerationAction) ModerationAction {
+	return ModerationAction{
+		ID:           dbAction.ID,
+		CreatedAt:    dbAction.CreatedAt,
+		ModeratorID:  nullUUIDPtr(dbAction.ModeratorID),
+		ReportID:     nullUUIDPtr(dbAction.ReportID),
+		ChirpID:      nullUUIDPtr(dbAction.ChirpID),
+		TargetUserID: nullUUIDPtr(dbAction.TargetUserID),
+		Action:       dbAction.Action,
+		Note:         dbAction.Note,
+	}
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
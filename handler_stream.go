@@ -0,0 +1,255 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"chirpy/internal/stream"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	streamReplaySize   = 1000
+	streamQueueSize    = 64
+	streamHeartbeat    = 15 * time.Second
+	streamResetEvent   = "reset"
+	streamTimelineHome = "home"
+)
+
+// handlerStream streams chirp events as Server-Sent Events. Clients can
+// filter by author_id, or follow their home timeline with timeline=home.
+// Reconnecting clients resume from the Last-Event-ID header, or the
+// last_event_id query parameter, and get a reset event if they missed
+// anything that's no longer buffered.
+func (cfg *apiConfig) handlerStream(w http.ResponseWriter, req *http.Request) {
+	viewerID, err := cfg.getOptionalUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming unsupported", nil)
+		return
+	}
+
+	// Validation
+	authorIDString := req.URL.Query().Get("author_id")
+	timelineString := req.URL.Query().Get("timeline")
+	if len(authorIDString) > 0 && len(timelineString) > 0 {
+		respondWithError(w, http.StatusBadRequest, "Use either author_id or timeline", nil)
+		return
+	}
+
+	authorID := uuid.Nil
+	if len(authorIDString) > 0 {
+		authorID, err = uuid.Parse(authorIDString)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author_id", err)
+			return
+		}
+	}
+
+	if len(timelineString) > 0 {
+		if timelineString != streamTimelineHome {
+			respondWithError(w, http.StatusBadRequest, "Invalid timeline", nil)
+			return
+		}
+		if viewerID == uuid.Nil {
+			respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", nil)
+			return
+		}
+	}
+
+	lastEventIDString := req.Header.Get("Last-Event-ID")
+	if len(lastEventIDString) == 0 {
+		lastEventIDString = req.URL.Query().Get("last_event_id")
+	}
+	lastEventID := uint64(0)
+	if len(lastEventIDString) > 0 {
+		lastEventID, err = strconv.ParseUint(lastEventIDString, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID", err)
+			return
+		}
+	}
+
+	// Follows, blocks and mutes are read once per connection, so changes
+	// take effect when the client reconnects
+	audience, err := cfg.streamAudience(req.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting followed users", err)
+		return
+	}
+	filter := func(event stream.Event) bool {
+		if !audience.canSee(event) {
+			return false
+		}
+		if authorID != uuid.Nil {
+			return event.AuthorID == authorID
+		}
+		if timelineString == streamTimelineHome {
+			return audience.inTimeline(event)
+		}
+		// Like GET /api/chirps, mutes hide chirps unless they're asked for
+		// by author
+		return !audience.muted[event.AuthorID]
+	}
+
+	sub := cfg.stream.Subscribe(lastEventID, filter)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if sub.Missed {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", streamResetEvent)
+	}
+	for _, event := range sub.Replay {
+		writeStreamEvent(w, event)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-cfg.serverCtx.Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind. The client reconnects with
+				// Last-Event-ID and resumes from the replay buffer.
+				return
+			}
+			writeStreamEvent(w, event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, event stream.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
+
+// streamAudience is what a viewer is allowed to see on the stream.
+type streamAudience struct {
+	viewerID  uuid.UUID
+	following map[uuid.UUID]bool
+	blockedBy map[uuid.UUID]bool
+	muted     map[uuid.UUID]bool
+}
+
+func (cfg *apiConfig) streamAudience(ctx context.Context, viewerID uuid.UUID) (streamAudience, error) {
+	audience := streamAudience{
+		viewerID:  viewerID,
+		following: map[uuid.UUID]bool{},
+		blockedBy: map[uuid.UUID]bool{},
+		muted:     map[uuid.UUID]bool{},
+	}
+	if viewerID == uuid.Nil {
+		return audience, nil
+	}
+
+	for _, load := range []struct {
+		query func(context.Context, uuid.UUID) ([]uuid.UUID, error)
+		set   map[uuid.UUID]bool
+	}{
+		{cfg.dbQueries.GetFolloweeIDs, audience.following},
+		{cfg.dbQueries.GetBlockerIDs, audience.blockedBy},
+		{cfg.dbQueries.GetMutedIDs, audience.muted},
+	} {
+		ids, err := load.query(ctx, viewerID)
+		if err != nil {
+			return streamAudience{}, err
+		}
+		for _, id := range ids {
+			load.set[id] = true
+		}
+	}
+	return audience, nil
+}
+
+// canSee applies the same visibility and block rules as GET /api/chirps.
+// Mutes only apply to some streams, so they're left to the caller.
+func (a streamAudience) canSee(event stream.Event) bool {
+	if event.AuthorID == a.viewerID {
+		return true
+	}
+	if a.blockedBy[event.AuthorID] {
+		return false
+	}
+	switch event.Visibility {
+	case visibilityPublic:
+		return true
+	case visibilityFollowers:
+		return a.following[event.AuthorID]
+	}
+	return false
+}
+
+func (a streamAudience) inTimeline(event stream.Event) bool {
+	if event.AuthorID == a.viewerID {
+		return true
+	}
+	return a.following[event.AuthorID] && !a.muted[event.AuthorID]
+}
+
+// publishChirpEvent tells stream subscribers about a change to a chirp.
+// Chirps are rendered as an anonymous viewer would see them, and changes to
+// hidden chirps aren't streamed. Failures are logged rather than failing the
+// request that made the change.
+func (cfg *apiConfig) publishChirpEvent(ctx context.Context, eventType string, dbChirp database.Chirp) {
+	if dbChirp.HiddenAt.Valid && eventType != stream.ChirpDeleted {
+		return
+	}
+
+	var payload any = struct {
+		ID uuid.UUID `json:"id"`
+	}{dbChirp.ID}
+	if eventType != stream.ChirpDeleted {
+		chirps, err := cfg.mapChirps(ctx, uuid.Nil, []database.Chirp{dbChirp})
+		if err != nil {
+			log.Printf("Error rendering chirp %s for the stream: %v", dbChirp.ID, err)
+			return
+		}
+		payload = chirps[0]
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error encoding chirp %s for the stream: %v", dbChirp.ID, err)
+		return
+	}
+
+	mentions, err := cfg.dbQueries.GetMentionedUserIDs(ctx, dbChirp.ID)
+	if err != nil {
+		log.Printf("Error getting mentions of chirp %s for the stream: %v", dbChirp.ID, err)
+		return
+	}
+
+	cfg.stream.Publish(stream.Event{
+		Type:       eventType,
+		ChirpID:    dbChirp.ID,
+		AuthorID:   dbChirp.UserID,
+		Visibility: dbChirp.Visibility,
+		QuoteOf:    dbChirp.QuoteOf.UUID,
+		Mentions:   mentions,
+		Data:       data,
+	})
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	moderationActionBanUser        = "ban_user"
+	moderationActionLiftSuspension = "lift_suspension"
+)
+
+type Suspension struct {
+	UserID         uuid.UUID  `json:"user_id"`
+	SuspendedUntil *time.Time `json:"suspended_until"`
+	Banned         bool       `json:"banned"`
+	Reason         string     `json:"reason"`
+}
+
+// isSuspended reports whether the user is banned or currently suspended.
+func isSuspended(dbUser database.User) bool {
+	if dbUser.BannedAt.Valid {
+		return true
+	}
+	return dbUser.SuspendedUntil.Valid && dbUser.SuspendedUntil.Time.After(time.Now().UTC())
+}
+
+func (cfg *apiConfig) handlerSuspendUser(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Reason         string     `json:"reason"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+		Banned         bool       `json:"banned"`
+	}
+
+	// Authorization
+	moderator, ok := cfg.requireRole(w, req, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(targetID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	if len(params.Reason) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Reason is required", nil)
+		return
+	}
+	if params.Banned {
+		if moderator.Role != roleAdmin {
+			respondWithError(w, http.StatusForbidden, "Only admins can ban users", nil)
+			return
+		}
+	} else if params.SuspendedUntil == nil || params.SuspendedUntil.Before(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "suspended_until must be in the future", nil)
+		return
+	}
+	if targetID == moderator.ID {
+		respondWithError(w, http.StatusBadRequest, "You can't suspend yourself", nil)
+		return
+	}
+
+	dbTarget, err := cfg.dbQueries.GetUser(req.Context(), targetID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+	if dbTarget.Role != roleUser && moderator.Role != roleAdmin {
+		respondWithError(w, http.StatusForbidden, "Only admins can suspend staff", nil)
+		return
+	}
+
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error suspending user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	action := moderationActionSuspendUser
+	if params.Banned {
+		action = moderationActionBanUser
+		dbTarget, err = qtx.BanUser(req.Context(), database.BanUserParams{
+			ID:               targetID,
+			SuspensionReason: params.Reason,
+		})
+	} else {
+		dbTarget, err = qtx.SuspendUser(req.Context(), database.SuspendUserParams{
+			ID:               targetID,
+			SuspendedUntil:   sql.NullTime{Time: params.SuspendedUntil.UTC(), Valid: true},
+			SuspensionReason: params.Reason,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error suspending user", err)
+		return
+	}
+
+	err = qtx.RevokeUserRefreshTokens(req.Context(), targetID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking refresh tokens", err)
+		return
+	}
+
+	_, err = qtx.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: targetID, Valid: true},
+		Action:       action,
+		Note:         params.Reason,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error recording moderation action", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error suspending user", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mapSuspension(dbTarget))
+}
+
+func (cfg *apiConfig) handlerLiftSuspension(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Reason string `json:"reason"`
+	}
+
+	// Authorization
+	moderator, ok := cfg.requireRole(w, req, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(targetID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	if len(params.Reason) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Reason is required", nil)
+		return
+	}
+
+	dbTarget, err := cfg.dbQueries.GetUser(req.Context(), targetID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+	if dbTarget.BannedAt.Valid && moderator.Role != roleAdmin {
+		respondWithError(w, http.StatusForbidden, "Only admins can lift bans", nil)
+		return
+	}
+
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error lifting suspension", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbTarget, err = qtx.LiftSuspension(req.Context(), targetID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error lifting suspension", err)
+		return
+	}
+
+	_, err = qtx.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: targetID, Valid: true},
+		Action:       moderationActionLiftSuspension,
+		Note:         params.Reason,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error recording moderation action", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error lifting suspension", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mapSuspension(dbTarget))
+}
+
+func mapSuspension(dbUser database.User) Suspension {
+	suspension := Suspension{
+		UserID: dbUser.ID,
+		Banned: dbUser.BannedAt.Valid,
+		Reason: dbUser.SuspensionReason,
+	}
+	if dbUser.SuspendedUntil.Valid {
+		suspendedUntil := dbUser.SuspendedUntil.Time
+		suspension.SuspendedUntil = &suspendedUntil
+	}
+	return suspension
+}
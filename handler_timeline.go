@@ -0,0 +1,78 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/timeline"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerGetTimeline(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	limit, offset, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	entries, err := cfg.timeline.Home(req.Context(), userID, timeline.Page{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting timeline", err)
+		return
+	}
+
+	dbChirps := make([]database.Chirp, 0, len(entries))
+	rechirperIDs := []uuid.UUID{}
+	for _, entry := range entries {
+		dbChirps = append(dbChirps, entry.Chirp)
+		if entry.RechirpedBy.Valid {
+			rechirperIDs = append(rechirperIDs, entry.RechirpedBy.UUID)
+		}
+	}
+	chirps, err := cfg.mapChirps(req.Context(), userID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting quoted chirps", err)
+		return
+	}
+
+	rechirpers := map[uuid.UUID]PublicUser{}
+	if len(rechirperIDs) > 0 {
+		dbRechirpers, err := cfg.dbQueries.GetUsersByIDs(req.Context(), rechirperIDs)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting rechirpers", err)
+			return
+		}
+		for _, dbRechirper := range dbRechirpers {
+			rechirpers[dbRechirper.ID] = mapPublicUser(dbRechirper)
+		}
+	}
+	for i, entry := range entries {
+		if !entry.RechirpedBy.Valid {
+			continue
+		}
+		if rechirper, ok := rechirpers[entry.RechirpedBy.UUID]; ok {
+			chirps[i].RechirpedBy = &rechirper
+		}
+		rechirpedAt := entry.RechirpedAt.Time
+		chirps[i].RechirpedAt = &rechirpedAt
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/contentpolicy"
+	"chirpy/internal/database"
+	"chirpy/internal/stream"
+	"chirpy/internal/timeline"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+const testTokenSecret = "test-secret"
+
+// newTestAPIConfig connects to the migrated database in TEST_DB_URL. Tests
+// that need one are skipped without it.
+func newTestAPIConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL isn't set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &apiConfig{
+		db:          db,
+		dbQueries:   database.New(db),
+		tokenSecret: testTokenSecret,
+		serverCtx:   context.Background(),
+		// Everything is allowed unless a test replaces the pipeline
+		contentPolicy: contentpolicy.NewPipeline(),
+		stream:        stream.NewHub(streamReplaySize, streamQueueSize),
+	}
+	cfg.timeline = timeline.NewFanOutOnRead(cfg.dbQueries)
+	return cfg
+}
+
+// createTestUser creates a user that's deleted when the test ends, and
+// returns them with an access token.
+func createTestUser(t *testing.T, cfg *apiConfig) (database.User, string) {
+	t.Helper()
+	dbUser, err := cfg.dbQueries.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          uuid.NewString() + "@example.com",
+		HashedPassword: "unused",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	t.Cleanup(func() {
+		cfg.db.Exec("DELETE FROM users WHERE id = $1", dbUser.ID)
+	})
+
+	token, err := auth.MakeJWT(dbUser.ID, testTokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT() error = %v", err)
+	}
+	return dbUser, token
+}
+
+func TestTimelineRechirps(t *testing.T) {
+	cfg := newTestAPIConfig(t)
+	ctx := context.Background()
+	walt, waltToken := createTestUser(t, cfg)
+	jesse, jesseToken := createTestUser(t, cfg)
+	gus, _ := createTestUser(t, cfg)
+
+	// Walt follows Jesse but not Gus, so Gus's chirp only reaches Walt
+	// through Jesse's rechirp
+	err := cfg.dbQueries.CreateFollow(ctx, database.CreateFollowParams{
+		FollowerID: walt.ID,
+		FolloweeID: jesse.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateFollow() error = %v", err)
+	}
+	dbChirp, err := cfg.dbQueries.CreateChirp(ctx, database.CreateChirpParams{
+		Body:       "Say my name",
+		UserID:     gus.ID,
+		Visibility: visibilityPublic,
+	})
+	if err != nil {
+		t.Fatalf("CreateChirp() error = %v", err)
+	}
+
+	getTimeline := func() []Chirp {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/timeline", nil)
+		req.Header.Set("Authorization", "Bearer "+waltToken)
+		w := httptest.NewRecorder()
+		cfg.handlerGetTimeline(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/timeline status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+		chirps := []Chirp{}
+		err := json.NewDecoder(w.Body).Decode(&chirps)
+		if err != nil {
+			t.Fatalf("Error decoding timeline: %v", err)
+		}
+		return chirps
+	}
+	rechirp := func(method string, handler http.HandlerFunc, wantStatus int) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/chirps/"+dbChirp.ID.String()+"/rechirp", nil)
+		req.SetPathValue("chirpID", dbChirp.ID.String())
+		req.Header.Set("Authorization", "Bearer "+jesseToken)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != wantStatus {
+			t.Fatalf("%s rechirp status = %d, want %d: %s", method, w.Code, wantStatus, w.Body)
+		}
+	}
+
+	if chirps := getTimeline(); len(chirps) != 0 {
+		t.Fatalf("timeline has %d chirps before the rechirp, want 0", len(chirps))
+	}
+
+	rechirp(http.MethodPost, cfg.handlerAddRechirp, http.StatusCreated)
+	chirps := getTimeline()
+	if len(chirps) != 1 || chirps[0].ID != dbChirp.ID {
+		t.Fatalf("timeline = %+v, want the rechirped chirp", chirps)
+	}
+	if chirps[0].RechirpedBy == nil || chirps[0].RechirpedBy.ID != jesse.ID || chirps[0].RechirpedAt == nil {
+		t.Errorf("rechirped_by = %+v at %v, want Jesse", chirps[0].RechirpedBy, chirps[0].RechirpedAt)
+	}
+	if chirps[0].RechirpCount != 1 || chirps[0].Rechirped {
+		t.Errorf("rechirp_count = %d, rechirped = %v, want 1 and false", chirps[0].RechirpCount, chirps[0].Rechirped)
+	}
+
+	rechirp(http.MethodDelete, cfg.handlerDeleteRechirp, http.StatusNoContent)
+	if chirps := getTimeline(); len(chirps) != 0 {
+		t.Errorf("timeline has %d chirps after the rechirp was deleted, want 0", len(chirps))
+	}
+}
@@ -38,6 +38,11 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if isSuspended(user) {
+		respondWithError(w, http.StatusForbidden, "Account suspended", nil)
+		return
+	}
+
 	accessToken, err := auth.MakeJWT(user.ID, cfg.tokenSecret, time.Hour)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error generating JWT", err)
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+type Trend struct {
+	Tag           string    `json:"tag"`
+	Rank          int32     `json:"rank"`
+	Score         float64   `json:"score"`
+	RecentCount   int32     `json:"recent_count"`
+	BaselineCount int32     `json:"baseline_count"`
+	ComputedAt    time.Time `json:"computed_at"`
+}
+
+func (cfg *apiConfig) handlerGetTrends(w http.ResponseWriter, req *http.Request) {
+	window := req.URL.Query().Get("window")
+	if len(window) == 0 {
+		window = "hour"
+	}
+
+	// Trends are computed in the background, so this is a plain read
+	dbTrends, err := cfg.dbQueries.GetTrendingTags(req.Context(), window)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting trends", err)
+		return
+	}
+
+	trends := make([]Trend, 0, len(dbTrends))
+	for _, dbTrend := range dbTrends {
+		trends = append(trends, Trend{
+			Tag:           dbTrend.Tag,
+			Rank:          dbTrend.Rank,
+			Score:         dbTrend.Score,
+			RecentCount:   dbTrend.RecentCount,
+			BaselineCount: dbTrend.BaselineCount,
+			ComputedAt:    dbTrend.ComputedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, trends)
+}
@@ -3,25 +3,112 @@ package main
 import (
 	"chirpy/internal/auth"
 	"chirpy/internal/database"
+	"chirpy/internal/entities"
+	"chirpy/internal/events"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+)
+
+// mergePatchContentType is the media type of JSON Merge Patch bodies
+const mergePatchContentType = "application/merge-patch+json"
+
+var errInvalidAvatar = errors.New("invalid avatar_id")
+
 type User struct {
 	ID          uuid.UUID `json:"id"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	Email       string    `json:"email"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   *string   `json:"avatar_url"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+
+	DefaultVisibility string `json:"default_visibility"`
+}
+
+// PublicUser is the view of a user shown to other users. It must never
+// include the user's email address. It's kept small enough to embed in
+// every chirp.
+type PublicUser struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   *string   `json:"avatar_url"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
 }
 
+// Profile is a user's public profile.
+type Profile struct {
+	PublicUser
+	Bio       string    `json:"bio"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handlerGetUser(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil || len(userID) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid userID", err)
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	respondWithProfile(w, dbUser, err)
+}
+
+// handlerGetUserByHandle looks a user up by handle, ignoring case. The
+// handle may be written with its leading @.
+func (cfg *apiConfig) handlerGetUserByHandle(w http.ResponseWriter, req *http.Request) {
+	handle := strings.TrimPrefix(req.PathValue("handle"), "@")
+	if !entities.IsValidHandle(handle) {
+		respondWithError(w, http.StatusBadRequest, "Invalid handle", nil)
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByHandle(req.Context(), handle)
+	respondWithProfile(w, dbUser, err)
+}
+
+// respondWithProfile writes the profile of a user just read from the
+// database. Banned users' profiles aren't shown.
+func respondWithProfile(w http.ResponseWriter, dbUser database.User, err error) {
+	if err == sql.ErrNoRows || (err == nil && dbUser.BannedAt.Valid) {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, Profile{
+		PublicUser: mapPublicUser(dbUser),
+		Bio:        dbUser.Bio,
+		CreatedAt:  dbUser.CreatedAt,
+	})
+}
+
 func (cfg *apiConfig) handlerAddUser(w http.ResponseWriter, req *http.Request) {
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string  `json:"email"`
+		Password string  `json:"password"`
+		Handle   *string `json:"handle"`
 	}
 	type response struct {
 		User
@@ -35,41 +122,73 @@ func (cfg *apiConfig) handlerAddUser(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	handle, ok := parseHandle(params.Handle)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid handle", nil)
+		return
+	}
+
 	hashed_password, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error creating user", err)
 		return
 	}
 
-	dbUser, err := cfg.dbQueries.CreateUser(req.Context(), database.CreateUserParams{
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbUser, err := qtx.CreateUser(req.Context(), database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: hashed_password,
+		Handle:         handle,
 	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email or handle already in use", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating user", err)
+		return
+	}
+
+	err = events.Write(req.Context(), qtx, events.UserCreated{UserID: dbUser.ID})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error creating user", err)
 		return
 	}
 
-	user := User{
-		ID:          dbUser.ID,
-		CreatedAt:   dbUser.CreatedAt,
-		UpdatedAt:   dbUser.UpdatedAt,
-		Email:       dbUser.Email,
-		IsChirpyRed: dbUser.IsChirpyRed,
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating user", err)
+		return
 	}
 
+	w.Header().Set("ETag", userETag(dbUser))
 	respondWithJSON(w, http.StatusCreated, response{
-		User: user,
+		User: mapUser(dbUser),
 	})
 }
 
+// handlerUpdateUser replaces the user's email and password, and updates
+// whichever other fields are given. The current password is only needed
+// when the email or password actually changes, as for PATCH. If-Match works
+// as it does for PATCH.
 func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, req *http.Request) {
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-	type response struct {
-		User
+		Email             string  `json:"email"`
+		Password          string  `json:"password"`
+		CurrentPassword   *string `json:"current_password"`
+		Handle            *string `json:"handle"`
+		DefaultVisibility *string `json:"default_visibility"`
+		DisplayName       *string `json:"display_name"`
+		Bio               *string `json:"bio"`
+		// AvatarID is the ID of an image the user uploaded, or "" to
+		// remove their avatar
+		AvatarID *string `json:"avatar_id"`
 	}
 
 	// Authorization
@@ -94,32 +213,464 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, req *http.Request
 		return
 	}
 
-	// Update email and password
-	hashed_password, err := auth.HashPassword(params.Password)
+	dbUser, ifUpdatedAt, ok := cfg.getUserForUpdate(w, req, userID)
+	if !ok {
+		return
+	}
+
+	// Validation
+	// Sending back the password the user already has isn't a change, so
+	// it needs neither the current password nor a new hash
+	passwordChanged := auth.CheckPasswordHash(dbUser.HashedPassword, params.Password) != nil
+	if passwordChanged || params.Email != dbUser.Email {
+		if !checkCurrentPassword(w, dbUser, params.CurrentPassword) {
+			return
+		}
+	}
+	update := database.PatchUserParams{
+		ID:          userID,
+		IfUpdatedAt: ifUpdatedAt,
+		Email:       sql.NullString{String: params.Email, Valid: true},
+	}
+
+	if passwordChanged {
+		hashedPassword, err := auth.HashPassword(params.Password)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating user", err)
+			return
+		}
+		update.HashedPassword = sql.NullString{String: hashedPassword, Valid: true}
+	}
+
+	// A missing handle leaves the current one unchanged
+	update.Handle, ok = parseHandle(params.Handle)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid handle", nil)
+		return
+	}
+
+	// A missing default visibility leaves the current one unchanged
+	update.DefaultVisibility, ok = parseVisibility(params.DefaultVisibility)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid default visibility", nil)
+		return
+	}
+
+	// Missing profile fields are left unchanged too
+	update.DisplayName, ok = parseDisplayName(params.DisplayName)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid display name", nil)
+		return
+	}
+	update.Bio, ok = parseBio(params.Bio)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Bio is too long", nil)
+		return
+	}
+	update.SetAvatar = params.AvatarID != nil
+	update.AvatarID, err = cfg.parseAvatarID(req.Context(), userID, params.AvatarID)
+	if errors.Is(err, errInvalidAvatar) {
+		respondWithError(w, http.StatusBadRequest, "Invalid avatar_id", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting avatar", err)
+		return
+	}
+
+	// Write to database
+	cfg.saveUser(w, req, update)
+}
+
+// handlerGetMe returns the current user with an ETag to send in If-Match
+// when updating them.
+func (cfg *apiConfig) handlerGetMe(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	}
+
+	w.Header().Set("ETag", userETag(dbUser))
+	respondWithJSON(w, http.StatusOK, mapUser(dbUser))
+}
+
+// handlerPatchUser updates only the fields in a JSON Merge Patch. Changing
+// the email address or password needs the current password. With If-Match,
+// the update only happens if nobody else has changed the user since the
+// client read it.
+func (cfg *apiConfig) handlerPatchUser(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Email             optional[string] `json:"email"`
+		Password          optional[string] `json:"password"`
+		CurrentPassword   optional[string] `json:"current_password"`
+		Handle            optional[string] `json:"handle"`
+		DefaultVisibility optional[string] `json:"default_visibility"`
+		DisplayName       optional[string] `json:"display_name"`
+		Bio               optional[string] `json:"bio"`
+		AvatarID          optional[string] `json:"avatar_id"`
+	}
+
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Decode request
+	if contentType := req.Header.Get("Content-Type"); len(contentType) > 0 {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != mergePatchContentType && mediaType != "application/json") {
+			respondWithError(w, http.StatusUnsupportedMediaType, "Expected "+mergePatchContentType, err)
+			return
+		}
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	dbUser, ifUpdatedAt, ok := cfg.getUserForUpdate(w, req, userID)
+	if !ok {
+		return
+	}
+
+	// Validation
+	if params.Email.Null || params.Password.Null || params.Handle.Null || params.DefaultVisibility.Null {
+		respondWithError(w, http.StatusBadRequest, "Email, password, handle and default visibility can't be removed", nil)
+		return
+	}
+	update := database.PatchUserParams{
+		ID:          userID,
+		IfUpdatedAt: ifUpdatedAt,
+	}
+	needsPassword := false
+
+	if params.Email.Set && params.Email.Value != dbUser.Email {
+		if len(params.Email.Value) == 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid email", nil)
+			return
+		}
+		update.Email = sql.NullString{String: params.Email.Value, Valid: true}
+		needsPassword = true
+	}
+
+	if params.Password.Set {
+		if len(params.Password.Value) == 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid password", nil)
+			return
+		}
+		needsPassword = true
+	}
+
+	if needsPassword {
+		var currentPassword *string
+		if params.CurrentPassword.Set && !params.CurrentPassword.Null {
+			currentPassword = &params.CurrentPassword.Value
+		}
+		if !checkCurrentPassword(w, dbUser, currentPassword) {
+			return
+		}
+	}
+
+	// Setting the same password again isn't a change, so it isn't rehashed
+	if params.Password.Set && params.Password.Value != params.CurrentPassword.Value {
+		hashedPassword, err := auth.HashPassword(params.Password.Value)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating user", err)
+			return
+		}
+		update.HashedPassword = sql.NullString{String: hashedPassword, Valid: true}
+	}
+
+	if params.Handle.Set {
+		update.Handle, ok = parseHandle(&params.Handle.Value)
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Invalid handle", nil)
+			return
+		}
+	}
+
+	if params.DefaultVisibility.Set {
+		update.DefaultVisibility, ok = parseVisibility(&params.DefaultVisibility.Value)
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Invalid default visibility", nil)
+			return
+		}
+	}
+
+	// Null removes the display name or bio, like an empty string
+	if params.DisplayName.Set {
+		update.DisplayName, ok = parseDisplayName(&params.DisplayName.Value)
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Invalid display name", nil)
+			return
+		}
+	}
+	if params.Bio.Set {
+		update.Bio, ok = parseBio(&params.Bio.Value)
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Bio is too long", nil)
+			return
+		}
+	}
+
+	if params.AvatarID.Set {
+		update.SetAvatar = true
+		update.AvatarID, err = cfg.parseAvatarID(req.Context(), userID, &params.AvatarID.Value)
+		if errors.Is(err, errInvalidAvatar) {
+			respondWithError(w, http.StatusBadRequest, "Invalid avatar_id", err)
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error getting avatar", err)
+			return
+		}
+	}
+
+	// Write to database
+	cfg.saveUser(w, req, update)
+}
+
+// getUserForUpdate loads the user about to be updated, and checks they
+// match the version in If-Match if there is one.
+func (cfg *apiConfig) getUserForUpdate(w http.ResponseWriter, req *http.Request, userID uuid.UUID) (database.User, sql.NullTime, bool) {
+	ifUpdatedAt, ok := parseIfMatch(req.Header.Get("If-Match"))
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid If-Match header", nil)
+		return database.User{}, sql.NullTime{}, false
+	}
+
+	dbUser, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return database.User{}, sql.NullTime{}, false
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return database.User{}, sql.NullTime{}, false
+	}
+	if ifUpdatedAt.Valid && !ifUpdatedAt.Time.Equal(dbUser.UpdatedAt) {
+		respondWithError(w, http.StatusPreconditionFailed, "User has changed", nil)
+		return database.User{}, sql.NullTime{}, false
+	}
+	return dbUser, ifUpdatedAt, true
+}
+
+// checkCurrentPassword makes sure a request changing the user's email or
+// password knows their current password.
+func checkCurrentPassword(w http.ResponseWriter, dbUser database.User, currentPassword *string) bool {
+	if currentPassword == nil {
+		respondWithError(w, http.StatusBadRequest, "Current password is required to change email or password", nil)
+		return false
+	}
+	err := auth.CheckPasswordHash(dbUser.HashedPassword, *currentPassword)
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, "Incorrect current password", err)
+		return false
+	}
+	return true
+}
+
+// saveUser writes an update from PUT or PATCH and responds with the user. A
+// new password signs the user out everywhere else by revoking their
+// refresh tokens.
+func (cfg *apiConfig) saveUser(w http.ResponseWriter, req *http.Request, update database.PatchUserParams) {
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error updating user", err)
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
 
-	dbUser, err := cfg.dbQueries.UpdateUser(req.Context(), database.UpdateUserParams{
-		ID:             userID,
-		Email:          params.Email,
-		HashedPassword: hashed_password,
-	})
+	dbUser, err := qtx.PatchUser(req.Context(), update)
+	if err == sql.ErrNoRows && update.IfUpdatedAt.Valid {
+		// Someone else changed the user since it was read
+		respondWithError(w, http.StatusPreconditionFailed, "User has changed", err)
+		return
+	} else if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email or handle already in use", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating user", err)
+		return
+	}
+
+	if update.HashedPassword.Valid {
+		err = qtx.RevokeUserRefreshTokens(req.Context(), dbUser.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error revoking refresh tokens", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error updating user", err)
 		return
 	}
 
-	user := User{
+	w.Header().Set("ETag", userETag(dbUser))
+	respondWithJSON(w, http.StatusOK, mapUser(dbUser))
+}
+
+func mapUser(dbUser database.User) User {
+	return User{
 		ID:          dbUser.ID,
 		CreatedAt:   dbUser.CreatedAt,
 		UpdatedAt:   dbUser.UpdatedAt,
 		Email:       dbUser.Email,
+		Handle:      dbUser.Handle.String,
+		DisplayName: dbUser.DisplayName,
+		Bio:         dbUser.Bio,
+		AvatarURL:   avatarURL(dbUser.AvatarID),
 		IsChirpyRed: dbUser.IsChirpyRed,
+
+		DefaultVisibility: dbUser.DefaultVisibility,
 	}
+}
 
-	respondWithJSON(w, http.StatusOK, response{
-		User: user,
-	})
+func mapPublicUser(dbUser database.User) PublicUser {
+	return PublicUser{
+		ID:          dbUser.ID,
+		Handle:      dbUser.Handle.String,
+		DisplayName: dbUser.DisplayName,
+		AvatarURL:   avatarURL(dbUser.AvatarID),
+		IsChirpyRed: dbUser.IsChirpyRed,
+	}
+}
+
+func mapPublicUsers(dbUsers []database.User) []PublicUser {
+	users := make([]PublicUser, 0, len(dbUsers))
+	for _, dbUser := range dbUsers {
+		users = append(users, mapPublicUser(dbUser))
+	}
+	return users
+}
+
+// userETag identifies a version of a user. It changes whenever the user is
+// updated.
+func userETag(dbUser database.User) string {
+	return `"` + strconv.FormatInt(dbUser.UpdatedAt.UnixMicro(), 36) + `"`
+}
+
+// parseIfMatch reads the version of the user an If-Match header expects.
+// The result isn't valid if there's no header, or it's "*" and any version
+// will do. Only a single ETag can be checked; weak ETags and ones we didn't
+// make never match.
+func parseIfMatch(header string) (sql.NullTime, bool) {
+	header = strings.TrimSpace(header)
+	if len(header) == 0 || header == "*" {
+		return sql.NullTime{}, true
+	}
+
+	weak := strings.HasPrefix(header, "W/")
+	tag, ok := strings.CutPrefix(strings.TrimPrefix(header, "W/"), `"`)
+	if !ok {
+		return sql.NullTime{}, false
+	}
+	tag, ok = strings.CutSuffix(tag, `"`)
+	if !ok || strings.Contains(tag, `"`) {
+		return sql.NullTime{}, false
+	}
+
+	micros, err := strconv.ParseInt(tag, 36, 64)
+	if weak || err != nil {
+		return sql.NullTime{Valid: true}, true
+	}
+	return sql.NullTime{Time: time.UnixMicro(micros).UTC(), Valid: true}, true
+}
+
+// avatarURL is where an avatar is served, or nil if there isn't one.
+func avatarURL(avatarID uuid.NullUUID) *string {
+	if !avatarID.Valid {
+		return nil
+	}
+	url := "/media/" + avatarID.UUID.String()
+	return &url
+}
+
+// parseHandle validates an optional handle from a request body.
+func parseHandle(handle *string) (sql.NullString, bool) {
+	if handle == nil {
+		return sql.NullString{}, true
+	}
+	if !entities.IsValidHandle(*handle) {
+		return sql.NullString{}, false
+	}
+	return sql.NullString{String: *handle, Valid: true}, true
+}
+
+// parseDisplayName validates an optional display name from a request body.
+// Surrounding spaces are trimmed, and an empty name removes it.
+func parseDisplayName(displayName *string) (sql.NullString, bool) {
+	if displayName == nil {
+		return sql.NullString{}, true
+	}
+	name := strings.TrimSpace(*displayName)
+	if utf8.RuneCountInString(name) > maxDisplayNameLength || strings.ContainsFunc(name, unicode.IsControl) {
+		return sql.NullString{}, false
+	}
+	return sql.NullString{String: name, Valid: true}, true
+}
+
+// parseBio validates an optional bio from a request body.
+func parseBio(bio *string) (sql.NullString, bool) {
+	if bio == nil {
+		return sql.NullString{}, true
+	}
+	text := strings.TrimSpace(*bio)
+	if utf8.RuneCountInString(text) > maxBioLength {
+		return sql.NullString{}, false
+	}
+	return sql.NullString{String: text, Valid: true}, true
+}
+
+// parseAvatarID validates an optional avatar from a request body. It must
+// be media the user uploaded; an empty ID removes the avatar.
+func (cfg *apiConfig) parseAvatarID(ctx context.Context, userID uuid.UUID, avatarID *string) (uuid.NullUUID, error) {
+	if avatarID == nil || len(*avatarID) == 0 {
+		return uuid.NullUUID{}, nil
+	}
+	mediaID, err := uuid.Parse(*avatarID)
+	if err != nil {
+		return uuid.NullUUID{}, errInvalidAvatar
+	}
+
+	dbMedia, err := cfg.dbQueries.GetMediaFile(ctx, mediaID)
+	if err == sql.ErrNoRows || (err == nil && dbMedia.UserID != userID) {
+		return uuid.NullUUID{}, errInvalidAvatar
+	} else if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: mediaID, Valid: true}, nil
 }
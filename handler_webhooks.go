@@ -0,0 +1,596 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/events"
+	"chirpy/internal/webhooks"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxWebhooksPerUser  = 10
+	webhookSendInterval = 5 * time.Second
+)
+
+// webhookEventTypes are the outbox events users can receive by webhook.
+// Each webhook only gets events about its owner.
+var webhookEventTypes = []string{
+	events.TypeChirpCreated,
+	events.TypeChirpDeleted,
+	events.TypeUserUpgraded,
+}
+
+var errWebhookNotFound = errors.New("webhook not found")
+
+// Webhook is an endpoint that receives signed events. Secret is only
+// included when the webhook is created.
+type Webhook struct {
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Enabled             bool       `json:"enabled"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	Secret              string     `json:"secret,omitempty"`
+}
+
+// WebhookDelivery is an entry in a webhook's delivery log.
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	EventID        *uuid.UUID `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus *int32     `json:"response_status"`
+	LastError      string     `json:"last_error"`
+}
+
+type WebhookDeliveryList struct {
+	Count      int64             `json:"count"`
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+// webhookPayload is the body of every webhook request. ID is the same for
+// every webhook that receives the event and across retries, so receivers
+// can discard duplicates.
+type webhookPayload struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type webhookParameters struct {
+	URL     *string  `json:"url"`
+	Events  []string `json:"events"`
+	Enabled *bool    `json:"enabled"`
+}
+
+func (cfg *apiConfig) handlerAddWebhook(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := webhookParameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	if params.URL == nil {
+		respondWithError(w, http.StatusBadRequest, "url is required", nil)
+		return
+	}
+	err = webhooks.ValidateURL(req.Context(), *params.URL)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	eventTypes, ok := parseWebhookEventTypes(params.Events)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid events", nil)
+		return
+	}
+
+	count, err := cfg.dbQueries.CountWebhooksByUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting webhooks", err)
+		return
+	} else if count >= maxWebhooksPerUser {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("You can't have more than %d webhooks", maxWebhooksPerUser), nil)
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating webhook", err)
+		return
+	}
+
+	// Write to database
+	dbWebhook, err := cfg.dbQueries.CreateWebhook(req.Context(), database.CreateWebhookParams{
+		UserID:     userID,
+		Url:        *params.URL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating webhook", err)
+		return
+	}
+
+	// The secret is only shown once
+	webhook := mapWebhook(dbWebhook)
+	webhook.Secret = dbWebhook.Secret
+	respondWithJSON(w, http.StatusCreated, webhook)
+}
+
+func (cfg *apiConfig) handlerGetWebhooks(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	dbWebhooks, err := cfg.dbQueries.GetWebhooksByUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting webhooks", err)
+		return
+	}
+
+	webhooks := make([]Webhook, 0, len(dbWebhooks))
+	for _, dbWebhook := range dbWebhooks {
+		webhooks = append(webhooks, mapWebhook(dbWebhook))
+	}
+	respondWithJSON(w, http.StatusOK, webhooks)
+}
+
+// handlerUpdateWebhook changes a webhook's URL or events, or enables it
+// again after it was disabled for failing.
+func (cfg *apiConfig) handlerUpdateWebhook(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	dbWebhook, err := cfg.getOwnWebhook(req.Context(), userID, req.PathValue("webhookID"))
+	if err == errWebhookNotFound {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting webhook", err)
+		return
+	}
+
+	// Decode request
+	decoder := json.NewDecoder(req.Body)
+	params := webhookParameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Validation
+	url := dbWebhook.Url
+	if params.URL != nil {
+		err = webhooks.ValidateURL(req.Context(), *params.URL)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		url = *params.URL
+	}
+	eventTypes := dbWebhook.EventTypes
+	if params.Events != nil {
+		var ok bool
+		eventTypes, ok = parseWebhookEventTypes(params.Events)
+		if !ok {
+			respondWithError(w, http.StatusBadRequest, "Invalid events", nil)
+			return
+		}
+	}
+	if params.Enabled != nil && !*params.Enabled {
+		respondWithError(w, http.StatusBadRequest, "Delete the webhook to stop receiving events", nil)
+		return
+	}
+
+	// Write to database
+	tx, err := cfg.db.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating webhook", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbWebhook, err = qtx.UpdateWebhook(req.Context(), database.UpdateWebhookParams{
+		ID:         dbWebhook.ID,
+		Url:        url,
+		EventTypes: eventTypes,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating webhook", err)
+		return
+	}
+
+	if params.Enabled != nil && dbWebhook.DisabledAt.Valid {
+		dbWebhook, err = qtx.EnableWebhook(req.Context(), dbWebhook.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating webhook", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating webhook", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mapWebhook(dbWebhook))
+}
+
+func (cfg *apiConfig) handlerDeleteWebhook(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	dbWebhook, err := cfg.getOwnWebhook(req.Context(), userID, req.PathValue("webhookID"))
+	if err == errWebhookNotFound {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting webhook", err)
+		return
+	}
+
+	// Pending deliveries and the delivery log go with it
+	err = cfg.dbQueries.DeleteWebhook(req.Context(), dbWebhook.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting webhook", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	dbWebhook, err := cfg.getOwnWebhook(req.Context(), userID, req.PathValue("webhookID"))
+	if err == errWebhookNotFound {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting webhook", err)
+		return
+	}
+
+	limit, offset, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	count, err := cfg.dbQueries.CountWebhookDeliveries(req.Context(), dbWebhook.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting deliveries", err)
+		return
+	}
+
+	dbDeliveries, err := cfg.dbQueries.GetWebhookDeliveries(req.Context(), database.GetWebhookDeliveriesParams{
+		WebhookID: dbWebhook.ID,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting deliveries", err)
+		return
+	}
+
+	list := WebhookDeliveryList{
+		Count:      count,
+		Deliveries: make([]WebhookDelivery, 0, len(dbDeliveries)),
+	}
+	for _, dbDelivery := range dbDeliveries {
+		list.Deliveries = append(list.Deliveries, mapWebhookDelivery(dbDelivery))
+	}
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// handlerTestWebhook sends a webhook.test event straight away and responds
+// with the outcome. Test events aren't retried and don't count towards
+// disabling the webhook, so they can be sent to disabled webhooks to check
+// they're fixed.
+func (cfg *apiConfig) handlerTestWebhook(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	dbWebhook, err := cfg.getOwnWebhook(req.Context(), userID, req.PathValue("webhookID"))
+	if err == errWebhookNotFound {
+		respondWithError(w, http.StatusNotFound, "", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting webhook", err)
+		return
+	}
+
+	payload, err := json.Marshal(webhookPayload{
+		ID:        uuid.New(),
+		Type:      webhooks.TypeTest,
+		CreatedAt: time.Now().UTC(),
+		Data: struct {
+			WebhookID uuid.UUID `json:"webhook_id"`
+		}{dbWebhook.ID},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error encoding test event", err)
+		return
+	}
+
+	dbDelivery, err := cfg.dbQueries.CreateWebhookTestDelivery(req.Context(), database.CreateWebhookTestDeliveryParams{
+		ID:        uuid.New(),
+		WebhookID: dbWebhook.ID,
+		EventType: webhooks.TypeTest,
+		Payload:   payload,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating delivery", err)
+		return
+	}
+
+	result := cfg.webhookSender.Send(req.Context(), webhooks.Delivery{
+		ID:        dbDelivery.ID,
+		WebhookID: dbWebhook.ID,
+		URL:       dbWebhook.Url,
+		Secret:    dbWebhook.Secret,
+		EventType: webhooks.TypeTest,
+		Payload:   payload,
+		Attempts:  1,
+	})
+	if result.OK() {
+		dbDelivery, err = cfg.dbQueries.MarkWebhookDeliverySucceeded(req.Context(), database.MarkWebhookDeliverySucceededParams{
+			AttemptedAt:    time.Now().UTC(),
+			ResponseStatus: webhooks.ResponseStatus(result),
+			ID:             dbDelivery.ID,
+		})
+	} else {
+		dbDelivery, err = cfg.dbQueries.FailWebhookDelivery(req.Context(), database.FailWebhookDeliveryParams{
+			AttemptedAt:    time.Now().UTC(),
+			ResponseStatus: webhooks.ResponseStatus(result),
+			LastError:      result.Err.Error(),
+			ID:             dbDelivery.ID,
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error recording delivery", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, mapWebhookDelivery(dbDelivery))
+}
+
+// queueWebhookDeliveries is an outbox subscriber that queues an event for
+// each of its owner's webhooks. Deliveries are unique per webhook and event,
+// so seeing an event twice doesn't send it twice.
+func (cfg *apiConfig) queueWebhookDeliveries(ctx context.Context, record events.Record) error {
+	var ownerID uuid.UUID
+	var data any
+	switch record.Type {
+	case events.TypeChirpCreated:
+		event := events.ChirpCreated{}
+		err := json.Unmarshal(record.Payload, &event)
+		if err != nil {
+			return err
+		}
+		dbChirp, err := cfg.dbQueries.GetChirp(ctx, event.ChirpID)
+		if err == sql.ErrNoRows {
+			// Deleted already, which sends its own event
+			return nil
+		} else if err != nil {
+			return err
+		}
+		chirps, err := cfg.mapChirps(ctx, event.AuthorID, []database.Chirp{dbChirp})
+		if err != nil {
+			return err
+		}
+		ownerID, data = event.AuthorID, chirps[0]
+	case events.TypeChirpDeleted:
+		event := events.ChirpDeleted{}
+		err := json.Unmarshal(record.Payload, &event)
+		if err != nil {
+			return err
+		}
+		ownerID, data = event.AuthorID, event
+	case events.TypeUserUpgraded:
+		event := events.UserUpgraded{}
+		err := json.Unmarshal(record.Payload, &event)
+		if err != nil {
+			return err
+		}
+		ownerID, data = event.UserID, event
+	default:
+		return nil
+	}
+
+	dbWebhooks, err := cfg.dbQueries.GetSubscribedWebhooks(ctx, database.GetSubscribedWebhooksParams{
+		UserID:    ownerID,
+		EventType: record.Type,
+	})
+	if err != nil || len(dbWebhooks) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(webhookPayload{
+		ID:        record.ID,
+		Type:      record.Type,
+		CreatedAt: record.CreatedAt,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, dbWebhook := range dbWebhooks {
+		err = cfg.dbQueries.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			WebhookID: dbWebhook.ID,
+			EventID:   uuid.NullUUID{UUID: record.ID, Valid: true},
+			EventType: record.Type,
+			Payload:   payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getOwnWebhook returns the user's webhook with the given ID. Other users'
+// webhooks are reported as not found.
+func (cfg *apiConfig) getOwnWebhook(ctx context.Context, userID uuid.UUID, webhookIDString string) (database.Webhook, error) {
+	webhookID, err := uuid.Parse(webhookIDString)
+	if err != nil {
+		return database.Webhook{}, errWebhookNotFound
+	}
+
+	dbWebhook, err := cfg.dbQueries.GetWebhook(ctx, webhookID)
+	if err == sql.ErrNoRows {
+		return database.Webhook{}, errWebhookNotFound
+	} else if err != nil {
+		return database.Webhook{}, err
+	} else if dbWebhook.UserID != userID {
+		return database.Webhook{}, errWebhookNotFound
+	}
+	return dbWebhook, nil
+}
+
+// parseWebhookEventTypes validates a webhook's events, removing duplicates.
+func parseWebhookEventTypes(eventTypes []string) ([]string, bool) {
+	if len(eventTypes) == 0 {
+		return nil, false
+	}
+	for _, eventType := range eventTypes {
+		if !slices.Contains(webhookEventTypes, eventType) {
+			return nil, false
+		}
+	}
+	eventTypes = slices.Clone(eventTypes)
+	slices.Sort(eventTypes)
+	return slices.Compact(eventTypes), true
+}
+
+func mapWebhook(dbWebhook database.Webhook) Webhook {
+	webhook := Webhook{
+		ID:                  dbWebhook.ID,
+		CreatedAt:           dbWebhook.CreatedAt,
+		UpdatedAt:           dbWebhook.UpdatedAt,
+		URL:                 dbWebhook.Url,
+		Events:              dbWebhook.EventTypes,
+		Enabled:             !dbWebhook.DisabledAt.Valid,
+		ConsecutiveFailures: dbWebhook.ConsecutiveFailures,
+	}
+	if dbWebhook.DisabledAt.Valid {
+		disabledAt := dbWebhook.DisabledAt.Time
+		webhook.DisabledAt = &disabledAt
+	}
+	return webhook
+}
+
+func mapWebhookDelivery(dbDelivery database.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID:        dbDelivery.ID,
+		CreatedAt: dbDelivery.CreatedAt,
+		EventID:   nullUUIDPtr(dbDelivery.EventID),
+		EventType: dbDelivery.EventType,
+		Status:    dbDelivery.Status,
+		Attempts:  dbDelivery.Attempts,
+		LastError: dbDelivery.LastError,
+	}
+	if dbDelivery.NextAttemptAt.Valid {
+		nextAttemptAt := dbDelivery.NextAttemptAt.Time
+		delivery.NextAttemptAt = &nextAttemptAt
+	}
+	if dbDelivery.LastAttemptAt.Valid {
+		lastAttemptAt := dbDelivery.LastAttemptAt.Time
+		delivery.LastAttemptAt = &lastAttemptAt
+	}
+	if dbDelivery.ResponseStatus.Valid {
+		responseStatus := dbDelivery.ResponseStatus.Int32
+		delivery.ResponseStatus = &responseStatus
+	}
+	return delivery
+}
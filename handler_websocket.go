@@ -0,0 +1,314 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/stream"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsMaxSubscriptions = 10
+	wsMaxMessageSize   = 4096
+	wsPingInterval     = 30 * time.Second
+	wsPongWait         = 60 * time.Second
+	wsWriteWait        = 10 * time.Second
+)
+
+const (
+	wsChannelTimeline     = "timeline"
+	wsChannelMentions     = "mentions"
+	wsChannelThreadPrefix = "thread:"
+)
+
+// Clients authenticate with a bearer token rather than cookies, so a page
+// on another origin can't act as the user and any origin is allowed.
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(req *http.Request) bool { return true },
+}
+
+// wsClientMessage is a message from the client. Type is subscribe or
+// unsubscribe.
+type wsClientMessage struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+}
+
+// wsServerMessage is a message to the client. Type is subscribed,
+// unsubscribed, event or error.
+type wsServerMessage struct {
+	Type    string          `json:"type"`
+	Channel string          `json:"channel,omitempty"`
+	ID      uint64          `json:"id,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// wsChannel is a parsed channel name. ThreadID is only set for threads,
+// which carry a chirp and the chirps quoting it.
+type wsChannel struct {
+	Name     string
+	ThreadID uuid.UUID
+}
+
+func parseWSChannel(name string) (wsChannel, bool) {
+	switch name {
+	case wsChannelTimeline, wsChannelMentions:
+		return wsChannel{Name: name}, true
+	}
+	if threadID, ok := strings.CutPrefix(name, wsChannelThreadPrefix); ok {
+		chirpID, err := uuid.Parse(threadID)
+		if err != nil {
+			return wsChannel{}, false
+		}
+		return wsChannel{Name: name, ThreadID: chirpID}, true
+	}
+	return wsChannel{}, false
+}
+
+// handlerWebSocket upgrades to a WebSocket connection where the client
+// subscribes to channels: timeline, mentions, or thread:<chirpID>. It's
+// authenticated with the same bearer token as the rest of the API.
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, req *http.Request) {
+	// Authorization
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Error validating token", err)
+		return
+	}
+
+	// The upgrade is a GET, which middlewareRejectSuspended lets through
+	dbUser, err := cfg.dbQueries.GetUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting user", err)
+		return
+	} else if isSuspended(dbUser) {
+		respondWithError(w, http.StatusForbidden, "Account suspended", nil)
+		return
+	}
+
+	audience, err := cfg.streamAudience(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error getting followed users", err)
+		return
+	}
+
+	// The upgrader writes its own error response
+	conn, err := wsUpgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+	cfg.sockets.Add(1)
+	defer cfg.sockets.Done()
+
+	client := &wsClient{
+		conn:     conn,
+		userID:   userID,
+		audience: audience,
+		replies:  make(chan wsServerMessage, wsMaxSubscriptions),
+		done:     make(chan struct{}),
+	}
+	client.channels.Store(&[]wsChannel{})
+
+	sub := cfg.stream.Subscribe(0, client.wants)
+	defer sub.Close()
+
+	written := make(chan struct{})
+	go func() {
+		client.writeLoop(cfg, sub)
+		close(written)
+	}()
+	client.readLoop()
+	<-written
+}
+
+// wsClient is one WebSocket connection. Only writeLoop writes to conn.
+type wsClient struct {
+	conn     *websocket.Conn
+	userID   uuid.UUID
+	audience streamAudience
+	// channels is replaced rather than modified, so the hub can read it
+	// without locking
+	channels atomic.Pointer[[]wsChannel]
+	replies  chan wsServerMessage
+	// done is closed when readLoop returns
+	done chan struct{}
+}
+
+// wants is the client's stream filter.
+func (c *wsClient) wants(event stream.Event) bool {
+	if !c.audience.canSee(event) {
+		return false
+	}
+	for _, channel := range *c.channels.Load() {
+		if c.matches(channel, event) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *wsClient) matches(channel wsChannel, event stream.Event) bool {
+	switch {
+	case channel.Name == wsChannelTimeline:
+		return c.audience.inTimeline(event)
+	case channel.Name == wsChannelMentions:
+		return event.Type != stream.ChirpDeleted && slices.Contains(event.Mentions, c.userID)
+	case channel.ThreadID != uuid.Nil:
+		return event.ChirpID == channel.ThreadID || event.QuoteOf == channel.ThreadID
+	}
+	return false
+}
+
+// readLoop handles subscription requests until the connection fails or is
+// closed.
+func (c *wsClient) readLoop() {
+	defer close(c.done)
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		msg := wsClientMessage{}
+		err := c.conn.ReadJSON(&msg)
+		if isMalformedMessage(err) {
+			c.reply(wsServerMessage{Type: "error", Message: "Invalid message"})
+			continue
+		} else if err != nil {
+			return
+		}
+		c.reply(c.handle(msg))
+	}
+}
+
+// isMalformedMessage reports whether a read failed only because the frame
+// wasn't a valid message, such as invalid JSON or a number where a string
+// belongs. The connection is still usable after those.
+func isMalformedMessage(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+}
+
+func (c *wsClient) handle(msg wsClientMessage) wsServerMessage {
+	channel, ok := parseWSChannel(msg.Channel)
+	if !ok {
+		return wsServerMessage{Type: "error", Channel: msg.Channel, Message: "Invalid channel"}
+	}
+
+	channels := slices.Clone(*c.channels.Load())
+	index := slices.IndexFunc(channels, func(existing wsChannel) bool {
+		return existing.Name == channel.Name
+	})
+
+	switch msg.Type {
+	case "subscribe":
+		if index >= 0 {
+			return wsServerMessage{Type: "subscribed", Channel: channel.Name}
+		}
+		if len(channels) >= wsMaxSubscriptions {
+			return wsServerMessage{Type: "error", Channel: channel.Name, Message: "Too many subscriptions"}
+		}
+		channels = append(channels, channel)
+		c.channels.Store(&channels)
+		return wsServerMessage{Type: "subscribed", Channel: channel.Name}
+	case "unsubscribe":
+		if index >= 0 {
+			channels = slices.Delete(channels, index, index+1)
+			c.channels.Store(&channels)
+		}
+		return wsServerMessage{Type: "unsubscribed", Channel: channel.Name}
+	}
+	return wsServerMessage{Type: "error", Message: "Invalid message type"}
+}
+
+// reply queues a message for writeLoop. Clients that send requests faster
+// than they read replies are disconnected.
+func (c *wsClient) reply(msg wsServerMessage) {
+	select {
+	case c.replies <- msg:
+	default:
+		c.conn.Close()
+	}
+}
+
+// writeLoop sends replies, events and pings until the connection closes,
+// the client falls behind, or the server shuts down.
+func (c *wsClient) writeLoop(cfg *apiConfig, sub *stream.Subscription) {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	defer c.conn.Close()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-cfg.serverCtx.Done():
+			c.writeClose(websocket.CloseGoingAway, "Server shutting down")
+			return
+		case msg := <-c.replies:
+			if err := c.write(msg); err != nil {
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				c.writeClose(websocket.CloseTryAgainLater, "Too slow")
+				return
+			}
+			// An event is sent once for each channel it belongs to
+			for _, channel := range *c.channels.Load() {
+				if !c.matches(channel, event) {
+					continue
+				}
+				err := c.write(wsServerMessage{
+					Type:    "event",
+					Channel: channel.Name,
+					ID:      event.ID,
+					Event:   event.Type,
+					Data:    event.Data,
+				})
+				if err != nil {
+					return
+				}
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *wsClient) write(msg wsServerMessage) error {
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteJSON(msg)
+}
+
+func (c *wsClient) writeClose(code int, text string) {
+	err := c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(wsWriteWait))
+	if err != nil {
+		log.Printf("Error closing WebSocket: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+func TestIsMalformedMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{name: "valid", data: `{"type":"subscribe","channel":"chirps"}`, want: false},
+		{name: "invalid JSON", data: `{"type":`, want: true},
+		{name: "wrong type", data: `{"type":42}`, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := wsClientMessage{}
+			err := json.Unmarshal([]byte(tt.data), &msg)
+			if got := isMalformedMessage(err); got != tt.want {
+				t.Errorf("isMalformedMessage(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+
+	if isMalformedMessage(io.ErrUnexpectedEOF) {
+		t.Errorf("isMalformedMessage(%v) = true, want false", io.ErrUnexpectedEOF)
+	}
+}
@@ -0,0 +1,371 @@
+package activitypub
+
+import (
+	"chirpy/internal/clock"
+	"chirpy/internal/egress"
+	"context"
+	"encoding/json"
+	"errors"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ContentType is the media type of ActivityPub documents
+	ContentType = "application/activity+json"
+	// acceptHeader asks for ActivityStreams in either of its media types
+	acceptHeader = `application/activity+json, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+	ActivityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	SecurityContext        = "https://w3id.org/security/v1"
+	// Public addresses an activity to everyone
+	Public = "https://www.w3.org/ns/activitystreams#Public"
+
+	// ActorCacheTTL is how long a remote actor is cached before it's
+	// fetched again
+	ActorCacheTTL = 24 * time.Hour
+	// MaxBodySize is the largest document accepted from another server
+	MaxBodySize = 1 << 20
+)
+
+var (
+	ErrInvalidActivity = errors.New("invalid activity")
+	ErrActorNotFound   = errors.New("actor not found")
+	ErrKeyNotFound     = errors.New("key not found")
+)
+
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPEM string `json:"publicKeyPem"`
+}
+
+// Activity is an activity we send. Object is an ID or an embedded object.
+type Activity struct {
+	Context   any        `json:"@context,omitempty"`
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	Actor     string     `json:"actor"`
+	Published *time.Time `json:"published,omitempty"`
+	To        []string   `json:"to,omitempty"`
+	Cc        []string   `json:"cc,omitempty"`
+	Object    any        `json:"object"`
+}
+
+type Note struct {
+	Context      any        `json:"@context,omitempty"`
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	AttributedTo string     `json:"attributedTo"`
+	Content      string     `json:"content"`
+	Published    time.Time  `json:"published"`
+	Updated      *time.Time `json:"updated,omitempty"`
+	To           []string   `json:"to"`
+	Cc           []string   `json:"cc"`
+}
+
+type Tombstone struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type OrderedCollection struct {
+	Context    any    `json:"@context,omitempty"`
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	TotalItems int64  `json:"totalItems"`
+	First      string `json:"first,omitempty"`
+}
+
+type OrderedCollectionPage struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	PartOf       string `json:"partOf"`
+	Next         string `json:"next,omitempty"`
+	OrderedItems []any  `json:"orderedItems"`
+}
+
+// RemoteActor is what we keep about an actor on another server.
+type RemoteActor struct {
+	ID                string
+	Inbox             string
+	SharedInbox       string
+	PreferredUsername string
+	KeyID             string
+	PublicKey         string
+	FetchedAt         time.Time
+}
+
+// Outgoing is an activity queued for delivery to a remote inbox.
+type Outgoing struct {
+	UserID     uuid.UUID
+	KeyID      string
+	Inbox      string
+	ActivityID string
+	Activity   []byte
+}
+
+// Chirp is a local chirp to publish as a Note.
+type Chirp struct {
+	ID        uuid.UUID
+	AuthorID  uuid.UUID
+	Body      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Store keeps local keys, remote actors and their follows, and queues
+// outgoing activities.
+type Store interface {
+	// GetKey returns ErrKeyNotFound if the user has no key yet
+	GetKey(ctx context.Context, userID uuid.UUID) (KeyPair, error)
+	// CreateKey does nothing if the user already has a key
+	CreateKey(ctx context.Context, userID uuid.UUID, key KeyPair) error
+	// GetActor returns ErrActorNotFound if the actor isn't cached
+	GetActor(ctx context.Context, id string) (RemoteActor, error)
+	SaveActor(ctx context.Context, actor RemoteActor) error
+	// DeleteActor removes an actor along with its follows
+	DeleteActor(ctx context.Context, id string) error
+	AddFollower(ctx context.Context, userID uuid.UUID, actorID, followID string) error
+	RemoveFollower(ctx context.Context, userID uuid.UUID, actorID string) error
+	RemoveFollow(ctx context.Context, actorID, followID string) error
+	// FollowerInboxes returns the inboxes to deliver a user's activities
+	// to, using shared inboxes where servers have them
+	FollowerInboxes(ctx context.Context, userID uuid.UUID) ([]string, error)
+	// Queue adds an outgoing activity unless it's already queued for the
+	// same inbox
+	Queue(ctx context.Context, outgoing Outgoing) error
+}
+
+// Federation publishes local users as ActivityPub actors and handles what
+// other servers send them. Local users are identified by their ID under
+// baseURL, e.g. https://chirpy.example/users/{userID}.
+type Federation struct {
+	baseURL string
+	store   Store
+	client  *http.Client
+	clock   clock.Clock
+}
+
+func New(baseURL string, store Store) *Federation {
+	return &Federation{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		store:   store,
+		client:  egress.NewClient(Timeout),
+		clock:   clock.Real{},
+	}
+}
+
+// WithClock replaces the federation's clock, for tests.
+func (f *Federation) WithClock(clock clock.Clock) *Federation {
+	f.clock = clock
+	return f
+}
+
+// WithClient replaces the HTTP client used to fetch remote actors. The
+// default one refuses to connect to addresses that aren't public.
+func (f *Federation) WithClient(client *http.Client) *Federation {
+	f.client = client
+	return f
+}
+
+// Host is the domain in our users' WebFinger addresses.
+func (f *Federation) Host() string {
+	u, err := url.Parse(f.baseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func (f *Federation) ActorURL(userID uuid.UUID) string {
+	return f.baseURL + "/users/" + userID.String()
+}
+
+func (f *Federation) InboxURL(userID uuid.UUID) string {
+	return f.ActorURL(userID) + "/inbox"
+}
+
+func (f *Federation) OutboxURL(userID uuid.UUID) string {
+	return f.ActorURL(userID) + "/outbox"
+}
+
+func (f *Federation) FollowersURL(userID uuid.UUID) string {
+	return f.ActorURL(userID) + "/followers"
+}
+
+func (f *Federation) KeyID(userID uuid.UUID) string {
+	return f.ActorURL(userID) + "#main-key"
+}
+
+func (f *Federation) NoteURL(userID, chirpID uuid.UUID) string {
+	return f.ActorURL(userID) + "/chirps/" + chirpID.String()
+}
+
+// Actor returns a user's actor document, creating their key if they don't
+// have one yet.
+func (f *Federation) Actor(ctx context.Context, userID uuid.UUID, handle string) (Actor, error) {
+	key, err := f.key(ctx, userID)
+	if err != nil {
+		return Actor{}, err
+	}
+
+	return Actor{
+		Context:           []string{ActivityStreamsContext, SecurityContext},
+		ID:                f.ActorURL(userID),
+		Type:              "Person",
+		PreferredUsername: handle,
+		Inbox:             f.InboxURL(userID),
+		Outbox:            f.OutboxURL(userID),
+		Followers:         f.FollowersURL(userID),
+		PublicKey: PublicKey{
+			ID:           f.KeyID(userID),
+			Owner:        f.ActorURL(userID),
+			PublicKeyPEM: key.PublicKey,
+		},
+	}, nil
+}
+
+// Note renders a public chirp. Chirps are plain text, so the body is
+// escaped and line breaks kept.
+func (f *Federation) Note(chirp Chirp) Note {
+	content := "<p>" + strings.ReplaceAll(html.EscapeString(chirp.Body), "\n", "<br>") + "</p>"
+	note := Note{
+		ID:           f.NoteURL(chirp.AuthorID, chirp.ID),
+		Type:         "Note",
+		AttributedTo: f.ActorURL(chirp.AuthorID),
+		Content:      content,
+		Published:    chirp.CreatedAt.UTC(),
+		To:           []string{Public},
+		Cc:           []string{f.FollowersURL(chirp.AuthorID)},
+	}
+	if chirp.UpdatedAt.After(chirp.CreatedAt) {
+		updated := chirp.UpdatedAt.UTC()
+		note.Updated = &updated
+	}
+	return note
+}
+
+func (f *Federation) Create(chirp Chirp) Activity {
+	note := f.Note(chirp)
+	return Activity{
+		ID:        note.ID + "/activity",
+		Type:      "Create",
+		Actor:     note.AttributedTo,
+		Published: &note.Published,
+		To:        note.To,
+		Cc:        note.Cc,
+		Object:    note,
+	}
+}
+
+// Update carries an edited chirp's new Note. Deliveries are only queued once
+// per activity, so each edit gets its own ID.
+func (f *Federation) Update(chirp Chirp) Activity {
+	note := f.Note(chirp)
+	updated := chirp.UpdatedAt.UTC()
+	return Activity{
+		ID:        note.ID + "#update-" + strconv.FormatInt(updated.UnixMilli(), 10),
+		Type:      "Update",
+		Actor:     note.AttributedTo,
+		Published: &updated,
+		To:        note.To,
+		Cc:        note.Cc,
+		Object:    note,
+	}
+}
+
+func (f *Federation) Delete(authorID, chirpID uuid.UUID) Activity {
+	noteURL := f.NoteURL(authorID, chirpID)
+	return Activity{
+		ID:    noteURL + "#delete",
+		Type:  "Delete",
+		Actor: f.ActorURL(authorID),
+		To:    []string{Public},
+		Cc:    []string{f.FollowersURL(authorID)},
+		Object: Tombstone{
+			ID:   noteURL,
+			Type: "Tombstone",
+		},
+	}
+}
+
+// SendToFollowers queues an activity for each of a user's remote followers'
+// servers. Activities are only queued once per inbox, so it's safe to call
+// again for the same activity.
+func (f *Federation) SendToFollowers(ctx context.Context, userID uuid.UUID, activity Activity) error {
+	inboxes, err := f.store.FollowerInboxes(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, inbox := range inboxes {
+		err = f.send(ctx, userID, inbox, activity)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Federation) send(ctx context.Context, userID uuid.UUID, inbox string, activity Activity) error {
+	// The deliverer signs with the key, so make sure there is one
+	_, err := f.key(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	activity.Context = ActivityStreamsContext
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	return f.store.Queue(ctx, Outgoing{
+		UserID:     userID,
+		KeyID:      f.KeyID(userID),
+		Inbox:      inbox,
+		ActivityID: activity.ID,
+		Activity:   body,
+	})
+}
+
+// key returns a user's key pair, creating it on first use.
+func (f *Federation) key(ctx context.Context, userID uuid.UUID) (KeyPair, error) {
+	key, err := f.store.GetKey(ctx, userID)
+	if !errors.Is(err, ErrKeyNotFound) {
+		return key, err
+	}
+
+	key, err = GenerateKeyPair()
+	if err != nil {
+		return KeyPair{}, err
+	}
+	err = f.store.CreateKey(ctx, userID, key)
+	if err != nil {
+		return KeyPair{}, err
+	}
+	// Another request may have created one first
+	return f.store.GetKey(ctx, userID)
+}
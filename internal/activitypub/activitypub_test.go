@@ -0,0 +1,478 @@
+package activitypub
+
+import (
+	"chirpy/internal/clock"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const testBaseURL = "https://chirpy.example"
+
+type fakeDelivery struct {
+	Outgoing
+	id            uuid.UUID
+	attempts      int
+	status        string
+	nextAttemptAt time.Time
+	lastError     string
+}
+
+// fakeStore is an in-memory Store and DeliveryStore.
+type fakeStore struct {
+	keys       map[uuid.UUID]KeyPair
+	actors     map[string]RemoteActor
+	followers  map[uuid.UUID]map[string]string
+	deliveries []*fakeDelivery
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		keys:      map[uuid.UUID]KeyPair{},
+		actors:    map[string]RemoteActor{},
+		followers: map[uuid.UUID]map[string]string{},
+	}
+}
+
+func (s *fakeStore) GetKey(ctx context.Context, userID uuid.UUID) (KeyPair, error) {
+	key, ok := s.keys[userID]
+	if !ok {
+		return KeyPair{}, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func (s *fakeStore) CreateKey(ctx context.Context, userID uuid.UUID, key KeyPair) error {
+	if _, ok := s.keys[userID]; !ok {
+		s.keys[userID] = key
+	}
+	return nil
+}
+
+func (s *fakeStore) GetActor(ctx context.Context, id string) (RemoteActor, error) {
+	actor, ok := s.actors[id]
+	if !ok {
+		return RemoteActor{}, ErrActorNotFound
+	}
+	return actor, nil
+}
+
+func (s *fakeStore) SaveActor(ctx context.Context, actor RemoteActor) error {
+	s.actors[actor.ID] = actor
+	return nil
+}
+
+func (s *fakeStore) DeleteActor(ctx context.Context, id string) error {
+	delete(s.actors, id)
+	for _, followers := range s.followers {
+		delete(followers, id)
+	}
+	return nil
+}
+
+func (s *fakeStore) AddFollower(ctx context.Context, userID uuid.UUID, actorID, followID string) error {
+	if s.followers[userID] == nil {
+		s.followers[userID] = map[string]string{}
+	}
+	s.followers[userID][actorID] = followID
+	return nil
+}
+
+func (s *fakeStore) RemoveFollower(ctx context.Context, userID uuid.UUID, actorID string) error {
+	delete(s.followers[userID], actorID)
+	return nil
+}
+
+func (s *fakeStore) RemoveFollow(ctx context.Context, actorID, followID string) error {
+	for _, followers := range s.followers {
+		if followers[actorID] == followID {
+			delete(followers, actorID)
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) FollowerInboxes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	inboxes := []string{}
+	for actorID := range s.followers[userID] {
+		actor := s.actors[actorID]
+		inbox := actor.SharedInbox
+		if inbox == "" {
+			inbox = actor.Inbox
+		}
+		if !slices.Contains(inboxes, inbox) {
+			inboxes = append(inboxes, inbox)
+		}
+	}
+	slices.Sort(inboxes)
+	return inboxes, nil
+}
+
+func (s *fakeStore) Queue(ctx context.Context, outgoing Outgoing) error {
+	for _, delivery := range s.deliveries {
+		if delivery.ActivityID == outgoing.ActivityID && delivery.Inbox == outgoing.Inbox {
+			return nil
+		}
+	}
+	s.deliveries = append(s.deliveries, &fakeDelivery{
+		Outgoing: outgoing,
+		id:       uuid.New(),
+		status:   "pending",
+	})
+	return nil
+}
+
+func (s *fakeStore) find(id uuid.UUID) *fakeDelivery {
+	for _, delivery := range s.deliveries {
+		if delivery.id == id {
+			return delivery
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error) {
+	claimed := []Delivery{}
+	for _, delivery := range s.deliveries {
+		if len(claimed) == limit {
+			break
+		}
+		if delivery.status != "pending" || delivery.nextAttemptAt.After(now) {
+			continue
+		}
+		delivery.attempts++
+		delivery.nextAttemptAt = leaseUntil
+		claimed = append(claimed, Delivery{
+			ID:         delivery.id,
+			Inbox:      delivery.Inbox,
+			KeyID:      delivery.KeyID,
+			PrivateKey: s.keys[delivery.UserID].PrivateKey,
+			Activity:   delivery.Activity,
+			Attempts:   delivery.attempts,
+		})
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) MarkDelivered(ctx context.Context, id uuid.UUID, at time.Time) error {
+	s.find(id).status = "delivered"
+	return nil
+}
+
+func (s *fakeStore) Retry(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error {
+	delivery := s.find(id)
+	delivery.nextAttemptAt = at
+	delivery.lastError = lastError
+	return nil
+}
+
+func (s *fakeStore) Fail(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error {
+	delivery := s.find(id)
+	delivery.status = "failed"
+	delivery.lastError = lastError
+	return nil
+}
+
+func (s *fakeStore) PurgeBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// fakeRemote is another ActivityPub server. It serves its actors' documents
+// and records what's posted to their inboxes.
+type fakeRemote struct {
+	*httptest.Server
+	mu       sync.Mutex
+	actors   map[string]*remoteActor
+	status   int
+	received []receivedRequest
+	fetches  int
+}
+
+type remoteActor struct {
+	id          string
+	keyID       string
+	key         *rsa.PrivateKey
+	publicKey   string
+	sharedInbox bool
+	gone        bool
+}
+
+type receivedRequest struct {
+	req  *http.Request
+	body []byte
+}
+
+func newFakeRemote(t *testing.T) *fakeRemote {
+	r := &fakeRemote{
+		actors: map[string]*remoteActor{},
+		status: http.StatusAccepted,
+	}
+	r.Server = httptest.NewTLSServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *fakeRemote) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.Method == http.MethodPost {
+		body, _ := io.ReadAll(req.Body)
+		r.received = append(r.received, receivedRequest{req: req.Clone(context.Background()), body: body})
+		w.WriteHeader(r.status)
+		return
+	}
+
+	r.fetches++
+	actor, ok := r.actors[r.URL+req.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if actor.gone {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	json.NewEncoder(w).Encode(r.document(actor))
+}
+
+func (r *fakeRemote) document(actor *remoteActor) Actor {
+	doc := Actor{
+		Context:           ActivityStreamsContext,
+		ID:                actor.id,
+		Type:              "Person",
+		PreferredUsername: actor.id[strings.LastIndex(actor.id, "/")+1:],
+		Inbox:             actor.id + "/inbox",
+		PublicKey: PublicKey{
+			ID:           actor.keyID,
+			Owner:        actor.id,
+			PublicKeyPEM: actor.publicKey,
+		},
+	}
+	if actor.sharedInbox {
+		doc.Endpoints = &Endpoints{SharedInbox: r.URL + "/inbox"}
+	}
+	return doc
+}
+
+func (r *fakeRemote) addActor(t *testing.T, name string, sharedInbox bool) *remoteActor {
+	actor := &remoteActor{id: r.URL + "/users/" + name, sharedInbox: sharedInbox}
+	r.rotateKey(t, actor)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.actors[actor.id] = actor
+	return actor
+}
+
+// rotateKey gives an actor a new key, as servers do now and then.
+func (r *fakeRemote) rotateKey(t *testing.T, actor *remoteActor) {
+	pair, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	key, err := ParsePrivateKey(pair.PrivateKey)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey() error = %v", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	actor.keyID = actor.id + "#key-" + uuid.NewString()[:8]
+	actor.key = key
+	actor.publicKey = pair.PublicKey
+}
+
+func (r *fakeRemote) setGone(actor *remoteActor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	actor.gone = true
+}
+
+func (r *fakeRemote) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *fakeRemote) fetchCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fetches
+}
+
+func (r *fakeRemote) requests() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.received
+}
+
+// post builds an activity from the actor to a local user's inbox, signed
+// with the actor's key.
+func (a *remoteActor) post(t *testing.T, f *Federation, userID uuid.UUID, activity map[string]any) (*http.Request, []byte) {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, f.InboxURL(userID), strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", ContentType)
+	err = Sign(req, body, a.keyID, a.key, f.clock.Now())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return req, body
+}
+
+func TestNote(t *testing.T) {
+	f := New(testBaseURL, newFakeStore())
+	chirp := Chirp{
+		ID:        uuid.New(),
+		AuthorID:  uuid.New(),
+		Body:      "<script>alert(1)</script> & more\nsecond line",
+		CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	note := f.Note(chirp)
+	want := "<p>&lt;script&gt;alert(1)&lt;/script&gt; &amp; more<br>second line</p>"
+	if note.Content != want {
+		t.Errorf("Content = %q, want %q", note.Content, want)
+	}
+	if note.ID != testBaseURL+"/users/"+chirp.AuthorID.String()+"/chirps/"+chirp.ID.String() {
+		t.Errorf("ID = %q", note.ID)
+	}
+	if note.AttributedTo != f.ActorURL(chirp.AuthorID) {
+		t.Errorf("AttributedTo = %q, want %q", note.AttributedTo, f.ActorURL(chirp.AuthorID))
+	}
+	if !slices.Contains(note.To, Public) {
+		t.Errorf("To = %v, want it to include %s", note.To, Public)
+	}
+	if note.Updated != nil {
+		t.Errorf("Updated = %v for an unedited chirp, want nil", note.Updated)
+	}
+
+	create := f.Create(chirp)
+	if create.Type != "Create" || create.Actor != note.AttributedTo || create.ID == note.ID {
+		t.Errorf("Create() = %+v", create)
+	}
+	edited := chirp
+	edited.UpdatedAt = chirp.CreatedAt.Add(time.Minute)
+	update := f.Update(edited)
+	if updatedNote, ok := update.Object.(Note); update.Type != "Update" || !ok || updatedNote.Updated == nil {
+		t.Errorf("Update() = %+v, want an Update with the edited note", update)
+	}
+	edited.UpdatedAt = edited.UpdatedAt.Add(time.Minute)
+	if f.Update(edited).ID == update.ID {
+		t.Errorf("Update() ID = %q for two edits, want a new one per edit", update.ID)
+	}
+	del := f.Delete(chirp.AuthorID, chirp.ID)
+	if tombstone, ok := del.Object.(Tombstone); !ok || tombstone.ID != note.ID {
+		t.Errorf("Delete() object = %+v, want a tombstone for %s", del.Object, note.ID)
+	}
+}
+
+// TestSendToFollowers follows a chirp from creation to the followers'
+// servers, which get one signed copy per inbox.
+func TestSendToFollowers(t *testing.T) {
+	now := time.Now().UTC()
+	clock := clock.NewFake(now)
+	store := newFakeStore()
+	remote := newFakeRemote(t)
+	bob := remote.addActor(t, "bob", true)
+	carol := remote.addActor(t, "carol", true)
+	other := newFakeRemote(t)
+	dave := other.addActor(t, "dave", false)
+
+	// Test servers share a certificate, so either's client trusts both
+	f := New(testBaseURL, store).WithClock(clock).WithClient(remote.Client())
+	deliverer := NewDeliverer(store, time.Second).WithClock(clock).WithClient(remote.Client())
+
+	userID := uuid.New()
+	for _, follower := range []*remoteActor{bob, carol, dave} {
+		req, body := follower.post(t, f, userID, map[string]any{
+			"id":     follower.id + "/follows/1",
+			"type":   "Follow",
+			"actor":  follower.id,
+			"object": f.ActorURL(userID),
+		})
+		err := f.HandleInbox(context.Background(), userID, req, body)
+		if err != nil {
+			t.Fatalf("HandleInbox() error = %v", err)
+		}
+	}
+
+	chirp := Chirp{ID: uuid.New(), AuthorID: userID, Body: "Hello, fediverse", CreatedAt: now, UpdatedAt: now}
+	for range 2 {
+		err := f.SendToFollowers(context.Background(), userID, f.Create(chirp))
+		if err != nil {
+			t.Fatalf("SendToFollowers() error = %v", err)
+		}
+	}
+
+	_, err := deliverer.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+
+	actor, err := f.Actor(context.Background(), userID, "alice")
+	if err != nil {
+		t.Fatalf("Actor() error = %v", err)
+	}
+	publicKey, err := ParsePublicKey(actor.PublicKey.PublicKeyPEM)
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		remote *fakeRemote
+		inbox  string
+	}{
+		{
+			name:   "Shared inbox",
+			remote: remote,
+			inbox:  "/inbox",
+		},
+		{
+			name:   "Personal inbox",
+			remote: other,
+			inbox:  "/users/dave/inbox",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creates := []receivedRequest{}
+			for _, received := range tt.remote.requests() {
+				if strings.Contains(string(received.body), `"Create"`) {
+					creates = append(creates, received)
+				}
+			}
+			if len(creates) != 1 {
+				t.Fatalf("server got %d Creates, want 1", len(creates))
+			}
+			got := creates[0]
+			if got.req.URL.Path != tt.inbox {
+				t.Errorf("Create posted to %s, want %s", got.req.URL.Path, tt.inbox)
+			}
+			if got.req.Header.Get("Content-Type") != ContentType {
+				t.Errorf("Content-Type = %q, want %q", got.req.Header.Get("Content-Type"), ContentType)
+			}
+			err := Verify(got.req, got.body, publicKey, now)
+			if err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+			if !strings.Contains(string(got.body), "Hello, fediverse") {
+				t.Errorf("body = %s, want the chirp", got.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package activitypub
+
+import (
+	"bytes"
+	"chirpy/internal/clock"
+	"chirpy/internal/egress"
+	"chirpy/internal/worker"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// BatchSize is how many deliveries are claimed at once
+	BatchSize = 50
+	// Lease is how long a claimed delivery is left alone before it's
+	// assumed lost and claimed again
+	Lease = 5 * time.Minute
+	// MaxAttempts is how many times a delivery is tried before it fails
+	MaxAttempts = 10
+	// Timeout is how long a remote server has to respond
+	Timeout = 10 * time.Second
+	// Retention is how long finished deliveries are kept
+	Retention = 7 * 24 * time.Hour
+
+	baseBackoff = time.Minute
+	maxBackoff  = 12 * time.Hour
+	userAgent   = "Chirpy-ActivityPub/1.0"
+)
+
+// Delivery is an activity waiting to be sent to an inbox.
+type Delivery struct {
+	ID         uuid.UUID
+	Inbox      string
+	KeyID      string
+	PrivateKey string
+	Activity   []byte
+	// Attempts includes the current one
+	Attempts int
+}
+
+// DeliveryStore tracks outgoing activities.
+type DeliveryStore interface {
+	// Claim returns up to limit deliveries due at now and hides them from
+	// other claims until leaseUntil
+	Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error)
+	MarkDelivered(ctx context.Context, id uuid.UUID, at time.Time) error
+	Retry(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error
+	Fail(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error
+	// PurgeBefore removes finished deliveries created before cutoff
+	PurgeBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// statusError is a response other than 2xx from a remote inbox.
+type statusError struct {
+	StatusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("inbox responded with %d", e.StatusCode)
+}
+
+// permanent reports whether retrying can't help. Client errors other than
+// timeouts and rate limits mean the inbox won't take the activity.
+func (e *statusError) permanent() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500 &&
+		e.StatusCode != http.StatusRequestTimeout && e.StatusCode != http.StatusTooManyRequests
+}
+
+// Deliverer posts queued activities to remote inboxes, signed with their
+// author's key, retrying failures with exponential backoff.
+type Deliverer struct {
+	store    DeliveryStore
+	client   *http.Client
+	clock    clock.Clock
+	interval time.Duration
+}
+
+func NewDeliverer(store DeliveryStore, interval time.Duration) *Deliverer {
+	return &Deliverer{
+		store:    store,
+		client:   egress.NewClient(Timeout),
+		clock:    clock.Real{},
+		interval: interval,
+	}
+}
+
+// WithClock replaces the deliverer's clock, for tests.
+func (d *Deliverer) WithClock(clock clock.Clock) *Deliverer {
+	d.clock = clock
+	return d
+}
+
+// WithClient replaces the deliverer's HTTP client. The default one refuses
+// to connect to addresses that aren't public.
+func (d *Deliverer) WithClient(client *http.Client) *Deliverer {
+	d.client = client
+	return d
+}
+
+// Run delivers due activities every interval until ctx is cancelled.
+func (d *Deliverer) Run(ctx context.Context) {
+	worker.Queue{
+		Name:      "activity deliveries",
+		BatchSize: BatchSize,
+		Process:   d.DeliverDue,
+		Purge: func(ctx context.Context) (int64, error) {
+			return d.store.PurgeBefore(ctx, d.clock.Now().Add(-Retention))
+		},
+	}.Run(ctx, d.interval)
+}
+
+// DeliverDue sends a batch of due deliveries and returns how many it
+// claimed.
+func (d *Deliverer) DeliverDue(ctx context.Context) (int, error) {
+	now := d.clock.Now()
+	deliveries, err := d.store.Claim(ctx, now, now.Add(Lease), BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		err = d.deliver(ctx, delivery)
+		if err != nil {
+			return len(deliveries), err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// deliver sends a delivery and records the outcome. It only returns errors
+// from the store.
+func (d *Deliverer) deliver(ctx context.Context, delivery Delivery) error {
+	err := d.Deliver(ctx, delivery)
+	now := d.clock.Now()
+	if err == nil {
+		return d.store.MarkDelivered(ctx, delivery.ID, now)
+	}
+
+	statusErr := &statusError{}
+	if delivery.Attempts >= MaxAttempts || (errors.As(err, &statusErr) && statusErr.permanent()) {
+		log.Printf("Giving up on delivering %s to %s: %v", delivery.ID, delivery.Inbox, err)
+		return d.store.Fail(ctx, delivery.ID, now, err.Error())
+	}
+	return d.store.Retry(ctx, delivery.ID, now.Add(Backoff(delivery.Attempts)), err.Error())
+}
+
+// Deliver makes one signed attempt at a delivery without recording it.
+func (d *Deliverer) Deliver(ctx context.Context, delivery Delivery) error {
+	key, err := ParsePrivateKey(delivery.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Inbox, bytes.NewReader(delivery.Activity))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("User-Agent", userAgent)
+	err = Sign(req, delivery.Activity, delivery.KeyID, key, d.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	io.CopyN(io.Discard, resp.Body, 4096)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// Backoff is how long to wait after a failed attempt, doubling each time up
+// to twelve hours.
+func Backoff(attempts int) time.Duration {
+	return worker.Backoff(attempts, baseBackoff, maxBackoff)
+}
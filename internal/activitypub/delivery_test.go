@@ -0,0 +1,163 @@
+package activitypub
+
+import (
+	"chirpy/internal/clock"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// queueTestActivity queues an activity from a new local user to a remote
+// inbox and returns the delivery along with the user's key pair.
+func queueTestActivity(t *testing.T, f *Federation, store *fakeStore, inbox string) (*fakeDelivery, KeyPair) {
+	userID := uuid.New()
+	err := f.send(context.Background(), userID, inbox, Activity{
+		ID:     f.ActorURL(userID) + "/activities/1",
+		Type:   "Create",
+		Actor:  f.ActorURL(userID),
+		Object: "https://remote.example/notes/1",
+	})
+	if err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	return store.deliveries[len(store.deliveries)-1], store.keys[userID]
+}
+
+func TestDeliverDueSignsRequests(t *testing.T) {
+	now := time.Now().UTC()
+	clock := clock.NewFake(now)
+	store := newFakeStore()
+	f := New(testBaseURL, store).WithClock(clock)
+	remote := newFakeRemote(t)
+	deliverer := NewDeliverer(store, time.Second).WithClock(clock).WithClient(remote.Client())
+
+	delivery, pair := queueTestActivity(t, f, store, remote.URL+"/inbox")
+	delivered, err := deliverer.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+	if delivered != 1 || delivery.status != "delivered" {
+		t.Fatalf("DeliverDue() = %d with delivery %s, want 1 delivered", delivered, delivery.status)
+	}
+
+	requests := remote.requests()
+	if len(requests) != 1 {
+		t.Fatalf("remote got %d requests, want 1", len(requests))
+	}
+	key, err := ParsePublicKey(pair.PublicKey)
+	if err != nil {
+		t.Fatalf("ParsePublicKey() error = %v", err)
+	}
+	err = Verify(requests[0].req, requests[0].body, key, now)
+	if err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	keyID, _ := SignatureKeyID(requests[0].req.Header)
+	if keyID != delivery.KeyID {
+		t.Errorf("keyId = %q, want %q", keyID, delivery.KeyID)
+	}
+}
+
+func TestDeliverDueRetries(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := clock.NewFake(now)
+	store := newFakeStore()
+	f := New(testBaseURL, store).WithClock(clock)
+	remote := newFakeRemote(t)
+	deliverer := NewDeliverer(store, time.Second).WithClock(clock).WithClient(remote.Client())
+	remote.setStatus(http.StatusServiceUnavailable)
+
+	delivery, _ := queueTestActivity(t, f, store, remote.URL+"/inbox")
+	_, err := deliverer.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+	if delivery.status != "pending" || delivery.lastError == "" {
+		t.Errorf("delivery = %s with error %q, want pending with an error", delivery.status, delivery.lastError)
+	}
+	if want := now.Add(Backoff(1)); !delivery.nextAttemptAt.Equal(want) {
+		t.Errorf("nextAttemptAt = %v, want %v", delivery.nextAttemptAt, want)
+	}
+
+	remote.setStatus(http.StatusAccepted)
+	clock.Set(delivery.nextAttemptAt)
+	_, err = deliverer.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+	if delivery.status != "delivered" {
+		t.Errorf("delivery = %s after the inbox recovered, want delivered", delivery.status)
+	}
+}
+
+func TestDeliverDueGivesUp(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantAttempts int
+	}{
+		{
+			name:         "Gone",
+			status:       http.StatusGone,
+			wantAttempts: 1,
+		},
+		{
+			name:         "Rate limited",
+			status:       http.StatusTooManyRequests,
+			wantAttempts: MaxAttempts,
+		},
+		{
+			name:         "Server error",
+			status:       http.StatusInternalServerError,
+			wantAttempts: MaxAttempts,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := clock.NewFake(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+			store := newFakeStore()
+			f := New(testBaseURL, store).WithClock(clock)
+			remote := newFakeRemote(t)
+			deliverer := NewDeliverer(store, time.Second).WithClock(clock).WithClient(remote.Client())
+			remote.setStatus(tt.status)
+
+			delivery, _ := queueTestActivity(t, f, store, remote.URL+"/inbox")
+			for range MaxAttempts + 1 {
+				clock.Set(delivery.nextAttemptAt)
+				_, err := deliverer.DeliverDue(context.Background())
+				if err != nil {
+					t.Fatalf("DeliverDue() error = %v", err)
+				}
+			}
+
+			if delivery.status != "failed" {
+				t.Errorf("delivery = %s, want failed", delivery.status)
+			}
+			if len(remote.requests()) != tt.wantAttempts {
+				t.Errorf("remote got %d requests, want %d", len(remote.requests()), tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{6, 32 * time.Minute},
+		{20, 12 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
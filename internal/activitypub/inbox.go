@@ -0,0 +1,327 @@
+package activitypub
+
+import (
+	"chirpy/internal/clock"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ErrActorGone is returned when a remote server says an actor doesn't exist.
+var ErrActorGone = errors.New("actor is gone")
+
+// incomingActivity is an activity from another server. Only the parts we
+// use are decoded, and the object is left raw because it can be an ID or
+// an embedded object.
+type incomingActivity struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+type incomingNote struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	AttributedTo string `json:"attributedTo"`
+}
+
+// HandleInbox verifies and processes an activity POSTed to a user's inbox.
+// It returns ErrInvalidSignature if the request can't be shown to come from
+// the activity's actor, and ErrInvalidActivity if the activity doesn't make
+// sense. Activity types we don't handle are ignored.
+func (f *Federation) HandleInbox(ctx context.Context, userID uuid.UUID, req *http.Request, body []byte) error {
+	activity := incomingActivity{}
+	err := json.Unmarshal(body, &activity)
+	if err != nil || activity.Type == "" || activity.Actor == "" {
+		return fmt.Errorf("%w: %v", ErrInvalidActivity, err)
+	}
+
+	actor, err := f.verifiedActor(ctx, req, body, activity.Actor)
+	if errors.Is(err, ErrActorGone) && activity.Type == "Delete" && objectID(activity.Object) == activity.Actor {
+		return f.handleAccountDeleted(ctx, req, body, activity.Actor)
+	} else if errors.Is(err, ErrActorGone) {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	} else if err != nil {
+		return err
+	}
+
+	switch activity.Type {
+	case "Follow":
+		return f.handleFollow(ctx, userID, actor, activity)
+	case "Undo":
+		return f.handleUndo(ctx, userID, actor, activity)
+	case "Create":
+		return f.handleCreate(ctx, actor, activity)
+	case "Delete":
+		return f.handleDelete(ctx, actor, activity)
+	default:
+		return nil
+	}
+}
+
+// verifiedActor checks a request was signed by actorID's key. If the check
+// fails with a cached key, the actor is fetched again in case they've
+// changed keys.
+func (f *Federation) verifiedActor(ctx context.Context, req *http.Request, body []byte, actorID string) (RemoteActor, error) {
+	keyID, err := SignatureKeyID(req.Header)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	// Servers keep their actors' keys on their own host, so a request
+	// signed anywhere else isn't worth fetching the actor for
+	if !sameHost(keyID, actorID) {
+		return RemoteActor{}, fmt.Errorf("%w: key %q isn't on the actor's host", ErrInvalidSignature, keyID)
+	}
+
+	actor, fetched, err := f.resolveActor(ctx, actorID, false)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	err = verifyWith(req, body, actor, keyID, f.clock)
+	if err != nil && !fetched {
+		actor, _, err = f.resolveActor(ctx, actorID, true)
+		if err != nil {
+			return RemoteActor{}, err
+		}
+		err = verifyWith(req, body, actor, keyID, f.clock)
+	}
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	return actor, nil
+}
+
+func verifyWith(req *http.Request, body []byte, actor RemoteActor, keyID string, clock clock.Clock) error {
+	if actor.KeyID != keyID {
+		return fmt.Errorf("%w: signed with a key that isn't the actor's", ErrInvalidSignature)
+	}
+	key, err := ParsePublicKey(actor.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return Verify(req, body, key, clock.Now())
+}
+
+// resolveActor returns a remote actor from the cache, or fetches it if it
+// isn't cached, the cache is stale, or refresh is set. It reports whether
+// the actor was fetched.
+func (f *Federation) resolveActor(ctx context.Context, id string, refresh bool) (RemoteActor, bool, error) {
+	if !refresh {
+		actor, err := f.store.GetActor(ctx, id)
+		if err == nil && f.clock.Now().Sub(actor.FetchedAt) < ActorCacheTTL {
+			return actor, false, nil
+		} else if err != nil && !errors.Is(err, ErrActorNotFound) {
+			return RemoteActor{}, false, err
+		}
+	}
+
+	actor, err := f.FetchActor(ctx, id)
+	if err != nil {
+		return RemoteActor{}, false, err
+	}
+	err = f.store.SaveActor(ctx, actor)
+	if err != nil {
+		return RemoteActor{}, false, err
+	}
+	return actor, true, nil
+}
+
+// FetchActor gets an actor document from its server, which must be on a
+// public host and use HTTPS. Failures are reported as ErrInvalidSignature,
+// since without the actor's key nothing they send can be trusted, except
+// ErrActorGone when the server says they don't exist.
+func (f *Federation) FetchActor(ctx context.Context, id string) (RemoteActor, error) {
+	if !isHTTPS(id) {
+		return RemoteActor{}, fmt.Errorf("%w: invalid actor ID %q", ErrInvalidSignature, id)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, id, nil)
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	req.Header.Set("Accept", acceptHeader)
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return RemoteActor{}, fmt.Errorf("%w: fetching actor: %v", ErrInvalidSignature, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return RemoteActor{}, ErrActorGone
+	} else if resp.StatusCode != http.StatusOK {
+		return RemoteActor{}, fmt.Errorf("%w: fetching actor: status %d", ErrInvalidSignature, resp.StatusCode)
+	}
+
+	actor := Actor{}
+	err = json.NewDecoder(io.LimitReader(resp.Body, MaxBodySize)).Decode(&actor)
+	if err != nil {
+		return RemoteActor{}, fmt.Errorf("%w: decoding actor: %v", ErrInvalidSignature, err)
+	}
+	if actor.ID != id || actor.Inbox == "" || actor.PublicKey.ID == "" || actor.PublicKey.PublicKeyPEM == "" {
+		return RemoteActor{}, fmt.Errorf("%w: incomplete actor %q", ErrInvalidSignature, id)
+	}
+	if !isHTTPS(actor.Inbox) || (actor.Endpoints != nil && actor.Endpoints.SharedInbox != "" && !isHTTPS(actor.Endpoints.SharedInbox)) {
+		return RemoteActor{}, fmt.Errorf("%w: actor %q has an invalid inbox", ErrInvalidSignature, id)
+	}
+	if actor.PublicKey.Owner != "" && actor.PublicKey.Owner != actor.ID {
+		return RemoteActor{}, fmt.Errorf("%w: actor's key belongs to %q", ErrInvalidSignature, actor.PublicKey.Owner)
+	}
+
+	remote := RemoteActor{
+		ID:                actor.ID,
+		Inbox:             actor.Inbox,
+		PreferredUsername: actor.PreferredUsername,
+		KeyID:             actor.PublicKey.ID,
+		PublicKey:         actor.PublicKey.PublicKeyPEM,
+		FetchedAt:         f.clock.Now(),
+	}
+	if actor.Endpoints != nil {
+		remote.SharedInbox = actor.Endpoints.SharedInbox
+	}
+	return remote, nil
+}
+
+// handleFollow accepts every follow, since chirps federated to followers
+// are public anyway.
+func (f *Federation) handleFollow(ctx context.Context, userID uuid.UUID, actor RemoteActor, activity incomingActivity) error {
+	if activity.ID == "" || objectID(activity.Object) != f.ActorURL(userID) {
+		return fmt.Errorf("%w: follow isn't for this actor", ErrInvalidActivity)
+	}
+
+	err := f.store.AddFollower(ctx, userID, actor.ID, activity.ID)
+	if err != nil {
+		return err
+	}
+
+	return f.send(ctx, userID, actor.Inbox, Activity{
+		ID:    f.ActorURL(userID) + "#accepts/" + uuid.NewString(),
+		Type:  "Accept",
+		Actor: f.ActorURL(userID),
+		Object: Activity{
+			ID:     activity.ID,
+			Type:   "Follow",
+			Actor:  actor.ID,
+			Object: f.ActorURL(userID),
+		},
+	})
+}
+
+// handleUndo handles unfollows, which refer to the Follow either by ID or by
+// embedding it.
+func (f *Federation) handleUndo(ctx context.Context, userID uuid.UUID, actor RemoteActor, activity incomingActivity) error {
+	undone := incomingActivity{}
+	if json.Unmarshal(activity.Object, &undone) != nil {
+		followID := objectID(activity.Object)
+		if followID == "" {
+			return fmt.Errorf("%w: undo has no object", ErrInvalidActivity)
+		}
+		return f.store.RemoveFollow(ctx, actor.ID, followID)
+	}
+
+	if undone.Type != "Follow" {
+		return nil
+	}
+	if undone.Actor != actor.ID {
+		return fmt.Errorf("%w: can't undo another actor's follow", ErrInvalidActivity)
+	}
+	if objectID(undone.Object) != f.ActorURL(userID) {
+		return nil
+	}
+	return f.store.RemoveFollower(ctx, userID, actor.ID)
+}
+
+// handleCreate checks notes sent to our users are the actor's own. Remote
+// posts aren't shown anywhere, so they aren't kept. Notes referred to by ID
+// rather than embedded are ignored.
+func (f *Federation) handleCreate(ctx context.Context, actor RemoteActor, activity incomingActivity) error {
+	note := incomingNote{}
+	if json.Unmarshal(activity.Object, &note) != nil || note.Type != "Note" {
+		return nil
+	}
+	if note.ID == "" {
+		return fmt.Errorf("%w: note has no ID", ErrInvalidActivity)
+	}
+	if note.AttributedTo != actor.ID {
+		return fmt.Errorf("%w: note isn't by the actor", ErrInvalidActivity)
+	}
+	return nil
+}
+
+// handleDelete removes everything we have from an actor when they delete
+// their account. Deleted notes need nothing, since we don't keep them.
+func (f *Federation) handleDelete(ctx context.Context, actor RemoteActor, activity incomingActivity) error {
+	id := objectID(activity.Object)
+	if id == "" {
+		return fmt.Errorf("%w: delete has no object", ErrInvalidActivity)
+	}
+	if id != actor.ID {
+		return nil
+	}
+	return f.store.DeleteActor(ctx, actor.ID)
+}
+
+// handleAccountDeleted handles an actor deleting their account. They can't
+// be fetched any more, so the signature is checked with the cached key,
+// however old. If they aren't cached we have nothing of theirs to delete.
+func (f *Federation) handleAccountDeleted(ctx context.Context, req *http.Request, body []byte, actorID string) error {
+	actor, err := f.store.GetActor(ctx, actorID)
+	if errors.Is(err, ErrActorNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	keyID, err := SignatureKeyID(req.Header)
+	if err != nil {
+		return err
+	}
+	err = verifyWith(req, body, actor, keyID, f.clock)
+	if err != nil {
+		return err
+	}
+	return f.store.DeleteActor(ctx, actor.ID)
+}
+
+// isHTTPS reports whether rawURL is an absolute HTTPS URL.
+func isHTTPS(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
+
+// sameHost reports whether two URLs are on the same host and port.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Host != "" && strings.EqualFold(ua.Host, ub.Host)
+}
+
+// objectID returns the ID of an object that's either an ID or an embedded
+// object, or "" if it's neither.
+func objectID(raw json.RawMessage) string {
+	var id string
+	if json.Unmarshal(raw, &id) == nil {
+		return id
+	}
+	object := struct {
+		ID string `json:"id"`
+	}{}
+	if json.Unmarshal(raw, &object) == nil {
+		return object.ID
+	}
+	return ""
+}
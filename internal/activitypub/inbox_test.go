@@ -0,0 +1,411 @@
+package activitypub
+
+import (
+	"chirpy/internal/clock"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestFederation(store *fakeStore, remote *fakeRemote) *Federation {
+	return New(testBaseURL, store).WithClock(clock.NewFake(time.Now().UTC())).WithClient(remote.Client())
+}
+
+func follow(f *Federation, actor *remoteActor, userID uuid.UUID) map[string]any {
+	return map[string]any{
+		"@context": ActivityStreamsContext,
+		"id":       actor.id + "/follows/" + userID.String(),
+		"type":     "Follow",
+		"actor":    actor.id,
+		"object":   f.ActorURL(userID),
+	}
+}
+
+func TestHandleInboxFollow(t *testing.T) {
+	store := newFakeStore()
+	remote := newFakeRemote(t)
+	f := newTestFederation(store, remote)
+	bob := remote.addActor(t, "bob", true)
+	userID := uuid.New()
+
+	activity := follow(f, bob, userID)
+	req, body := bob.post(t, f, userID, activity)
+	err := f.HandleInbox(context.Background(), userID, req, body)
+	if err != nil {
+		t.Fatalf("HandleInbox() error = %v", err)
+	}
+
+	if got := store.followers[userID][bob.id]; got != activity["id"] {
+		t.Errorf("follow ID = %q, want %q", got, activity["id"])
+	}
+	if store.actors[bob.id].SharedInbox != remote.URL+"/inbox" {
+		t.Errorf("cached shared inbox = %q, want %q", store.actors[bob.id].SharedInbox, remote.URL+"/inbox")
+	}
+
+	// The follow is accepted straight away, in bob's own inbox
+	if len(store.deliveries) != 1 {
+		t.Fatalf("queued %d deliveries, want 1", len(store.deliveries))
+	}
+	queued := store.deliveries[0]
+	if queued.Inbox != bob.id+"/inbox" {
+		t.Errorf("Accept queued for %s, want %s", queued.Inbox, bob.id+"/inbox")
+	}
+	if queued.KeyID != f.KeyID(userID) {
+		t.Errorf("Accept signed with %s, want %s", queued.KeyID, f.KeyID(userID))
+	}
+	accept := struct {
+		Type   string `json:"type"`
+		Actor  string `json:"actor"`
+		Object struct {
+			ID string `json:"id"`
+		} `json:"object"`
+	}{}
+	err = json.Unmarshal(queued.Activity, &accept)
+	if err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if accept.Type != "Accept" || accept.Actor != f.ActorURL(userID) || accept.Object.ID != activity["id"] {
+		t.Errorf("Accept = %+v, want an Accept of %s", accept, activity["id"])
+	}
+	if _, ok := store.keys[userID]; !ok {
+		t.Error("no key was created to sign the Accept")
+	}
+}
+
+func TestHandleInboxUndoFollow(t *testing.T) {
+	tests := []struct {
+		name   string
+		object func(followActivity map[string]any) any
+	}{
+		{
+			name: "Embedded follow",
+			object: func(followActivity map[string]any) any {
+				return followActivity
+			},
+		},
+		{
+			name: "Follow ID",
+			object: func(followActivity map[string]any) any {
+				return followActivity["id"]
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			remote := newFakeRemote(t)
+			f := newTestFederation(store, remote)
+			bob := remote.addActor(t, "bob", false)
+			userID := uuid.New()
+
+			followActivity := follow(f, bob, userID)
+			req, body := bob.post(t, f, userID, followActivity)
+			err := f.HandleInbox(context.Background(), userID, req, body)
+			if err != nil {
+				t.Fatalf("HandleInbox() error = %v", err)
+			}
+
+			req, body = bob.post(t, f, userID, map[string]any{
+				"id":     bob.id + "/undo/1",
+				"type":   "Undo",
+				"actor":  bob.id,
+				"object": tt.object(followActivity),
+			})
+			err = f.HandleInbox(context.Background(), userID, req, body)
+			if err != nil {
+				t.Fatalf("HandleInbox() error = %v", err)
+			}
+			if _, ok := store.followers[userID][bob.id]; ok {
+				t.Error("bob still follows after Undo")
+			}
+		})
+	}
+}
+
+// TestHandleInboxNotes accepts notes and their deletion without keeping
+// anything, and without mistaking a deleted note for a deleted account.
+func TestHandleInboxNotes(t *testing.T) {
+	store := newFakeStore()
+	remote := newFakeRemote(t)
+	f := newTestFederation(store, remote)
+	bob := remote.addActor(t, "bob", false)
+	userID := uuid.New()
+	noteID := bob.id + "/notes/1"
+
+	activities := []map[string]any{
+		{
+			"id":    noteID + "/activity",
+			"type":  "Create",
+			"actor": bob.id,
+			"object": map[string]any{
+				"id":           noteID,
+				"type":         "Note",
+				"attributedTo": bob.id,
+				"content":      "<p>Hi <a href=\"https://chirpy.example/users/1\">@alice</a></p>",
+			},
+		},
+		{
+			"id":     noteID + "#delete",
+			"type":   "Delete",
+			"actor":  bob.id,
+			"object": map[string]any{"id": noteID, "type": "Tombstone"},
+		},
+	}
+	for _, activity := range activities {
+		req, body := bob.post(t, f, userID, activity)
+		err := f.HandleInbox(context.Background(), userID, req, body)
+		if err != nil {
+			t.Fatalf("HandleInbox(%s) error = %v", activity["type"], err)
+		}
+	}
+
+	if _, ok := store.actors[bob.id]; !ok {
+		t.Error("deleting a note removed its actor")
+	}
+}
+
+func TestHandleInboxAccountDeleted(t *testing.T) {
+	store := newFakeStore()
+	remote := newFakeRemote(t)
+	f := newTestFederation(store, remote)
+	bob := remote.addActor(t, "bob", false)
+	userID := uuid.New()
+
+	req, body := bob.post(t, f, userID, follow(f, bob, userID))
+	err := f.HandleInbox(context.Background(), userID, req, body)
+	if err != nil {
+		t.Fatalf("HandleInbox() error = %v", err)
+	}
+
+	// Deleted accounts can't be fetched, even once the cache is stale
+	remote.setGone(bob)
+	f.clock.(*clock.Fake).Set(f.clock.Now().Add(ActorCacheTTL + time.Minute))
+
+	req, body = bob.post(t, f, userID, map[string]any{
+		"id":     bob.id + "#delete",
+		"type":   "Delete",
+		"actor":  bob.id,
+		"object": bob.id,
+	})
+	err = f.HandleInbox(context.Background(), userID, req, body)
+	if err != nil {
+		t.Fatalf("HandleInbox() error = %v", err)
+	}
+	if _, ok := store.actors[bob.id]; ok {
+		t.Error("bob is still cached")
+	}
+	if _, ok := store.followers[userID][bob.id]; ok {
+		t.Error("bob still follows")
+	}
+
+	// Deletes of accounts we never knew are ignored
+	carol := remote.addActor(t, "carol", false)
+	remote.setGone(carol)
+	req, body = carol.post(t, f, userID, map[string]any{
+		"id":     carol.id + "#delete",
+		"type":   "Delete",
+		"actor":  carol.id,
+		"object": carol.id,
+	})
+	err = f.HandleInbox(context.Background(), userID, req, body)
+	if err != nil {
+		t.Errorf("HandleInbox() error = %v for an unknown deleted account", err)
+	}
+}
+
+func TestHandleInboxKeyRotation(t *testing.T) {
+	store := newFakeStore()
+	remote := newFakeRemote(t)
+	f := newTestFederation(store, remote)
+	bob := remote.addActor(t, "bob", false)
+	userID := uuid.New()
+
+	req, body := bob.post(t, f, userID, follow(f, bob, userID))
+	err := f.HandleInbox(context.Background(), userID, req, body)
+	if err != nil {
+		t.Fatalf("HandleInbox() error = %v", err)
+	}
+
+	remote.rotateKey(t, bob)
+	req, body = bob.post(t, f, userID, map[string]any{
+		"id":     bob.id + "/undo/1",
+		"type":   "Undo",
+		"actor":  bob.id,
+		"object": bob.id + "/follows/" + userID.String(),
+	})
+	err = f.HandleInbox(context.Background(), userID, req, body)
+	if err != nil {
+		t.Fatalf("HandleInbox() error = %v after a key rotation", err)
+	}
+	if store.actors[bob.id].KeyID != bob.keyID {
+		t.Errorf("cached key = %s, want %s", store.actors[bob.id].KeyID, bob.keyID)
+	}
+}
+
+func TestHandleInboxRejects(t *testing.T) {
+	remote := newFakeRemote(t)
+	bob := remote.addActor(t, "bob", false)
+	mallory := remote.addActor(t, "mallory", false)
+	eve := newFakeRemote(t).addActor(t, "eve", false)
+	userID := uuid.New()
+
+	tests := []struct {
+		name    string
+		request func(t *testing.T, f *Federation) (*http.Request, []byte)
+		wantErr error
+	}{
+		{
+			name: "Unsigned",
+			request: func(t *testing.T, f *Federation) (*http.Request, []byte) {
+				req, body := bob.post(t, f, userID, follow(f, bob, userID))
+				req.Header.Del("Signature")
+				return req, body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "Modified body",
+			request: func(t *testing.T, f *Federation) (*http.Request, []byte) {
+				req, _ := bob.post(t, f, userID, follow(f, bob, userID))
+				other := follow(f, bob, uuid.New())
+				body, _ := json.Marshal(other)
+				return req, body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "Signed by another actor",
+			request: func(t *testing.T, f *Federation) (*http.Request, []byte) {
+				return mallory.post(t, f, userID, follow(f, bob, userID))
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "Old request",
+			request: func(t *testing.T, f *Federation) (*http.Request, []byte) {
+				req, body := bob.post(t, f, userID, follow(f, bob, userID))
+				f.clock.(*clock.Fake).Set(f.clock.Now().Add(2 * time.Hour))
+				return req, body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "Unknown actor",
+			request: func(t *testing.T, f *Federation) (*http.Request, []byte) {
+				activity := follow(f, bob, userID)
+				activity["actor"] = remote.URL + "/users/nobody"
+				return bob.post(t, f, userID, activity)
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "Key on another host",
+			request: func(t *testing.T, f *Federation) (*http.Request, []byte) {
+				return eve.post(t, f, userID, follow(f, bob, userID))
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "Plain HTTP actor",
+			request: func(t *testing.T, f *Federation) (*http.Request, []byte) {
+				activity := follow(f, bob, userID)
+				activity["actor"] = strings.Replace(bob.id, "https://", "http://", 1)
+				return bob.post(t, f, userID, activity)
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "Follow for another user",
+			request: func(t *testing.T, f *Federation) (*http.Request, []byte) {
+				return bob.post(t, f, userID, follow(f, bob, uuid.New()))
+			},
+			wantErr: ErrInvalidActivity,
+		},
+		{
+			name: "Note by another actor",
+			request: func(t *testing.T, f *Federation) (*http.Request, []byte) {
+				return mallory.post(t, f, userID, map[string]any{
+					"id":    mallory.id + "/notes/1/activity",
+					"type":  "Create",
+					"actor": mallory.id,
+					"object": map[string]any{
+						"id":           bob.id + "/notes/1",
+						"type":         "Note",
+						"attributedTo": bob.id,
+						"content":      "Not really from bob",
+					},
+				})
+			},
+			wantErr: ErrInvalidActivity,
+		},
+		{
+			name: "Not JSON",
+			request: func(t *testing.T, f *Federation) (*http.Request, []byte) {
+				req, _ := bob.post(t, f, userID, follow(f, bob, userID))
+				return req, []byte("<xml/>")
+			},
+			wantErr: ErrInvalidActivity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			f := newTestFederation(store, remote)
+			req, body := tt.request(t, f)
+
+			err := f.HandleInbox(context.Background(), userID, req, body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("HandleInbox() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(store.followers[userID]) != 0 {
+				t.Error("HandleInbox() changed the store for a rejected activity")
+			}
+		})
+	}
+}
+
+// TestHandleInboxChecksKeyHost makes sure a request signed on one host
+// can't make us fetch actors from another.
+func TestHandleInboxChecksKeyHost(t *testing.T) {
+	remote := newFakeRemote(t)
+	bob := remote.addActor(t, "bob", false)
+	eve := newFakeRemote(t).addActor(t, "eve", false)
+	store := newFakeStore()
+	f := newTestFederation(store, remote)
+	userID := uuid.New()
+
+	req, body := eve.post(t, f, userID, follow(f, bob, userID))
+	err := f.HandleInbox(context.Background(), userID, req, body)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("HandleInbox() error = %v, wantErr %v", err, ErrInvalidSignature)
+	}
+	if remote.fetchCount() != 0 {
+		t.Errorf("remote got %d fetches, want 0", remote.fetchCount())
+	}
+}
+
+func TestHandleInboxRefusesLocalActors(t *testing.T) {
+	remote := newFakeRemote(t)
+	bob := remote.addActor(t, "bob", false)
+	store := newFakeStore()
+	// The default client, which won't connect to the loopback test server
+	f := New(testBaseURL, store).WithClock(clock.NewFake(time.Now().UTC()))
+	userID := uuid.New()
+
+	req, body := bob.post(t, f, userID, follow(f, bob, userID))
+	err := f.HandleInbox(context.Background(), userID, req, body)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("HandleInbox() error = %v, wantErr %v", err, ErrInvalidSignature)
+	}
+	if remote.fetchCount() != 0 {
+		t.Errorf("remote got %d fetches, want 0", remote.fetchCount())
+	}
+}
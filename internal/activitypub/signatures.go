@@ -0,0 +1,241 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+const (
+	keySize = 2048
+	// signatureTolerance is how far a request's Date can be from our clock
+	signatureTolerance = time.Hour
+)
+
+// KeyPair is an actor's RSA key pair, PEM encoded.
+type KeyPair struct {
+	PublicKey  string
+	PrivateKey string
+}
+
+func GenerateKeyPair() (KeyPair, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return KeyPair{}, err
+	}
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return KeyPair{}, err
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return KeyPair{}, err
+	}
+	return KeyPair{
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private})),
+	}, nil
+}
+
+func ParsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("no PEM block in private key")
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key isn't an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// ParsePublicKey reads the public keys other servers publish, which can be
+// in either PKIX or PKCS #1 form.
+func ParsePublicKey(s string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("no PEM block in public key")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key isn't an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// Sign adds an HTTP Signature to a request, as most ActivityPub servers
+// expect. POST requests also get a Digest of the body, which is signed.
+func Sign(req *http.Request, body []byte, keyID string, key *rsa.PrivateKey, now time.Time) error {
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+	req.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+	headers := []string{"(request-target)", "host", "date"}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		req.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+
+	signingString, err := signingString(req, headers)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(signingString))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// Verify checks a request's HTTP Signature against the sender's public key.
+// The signature must cover the request target, host and date, and the
+// body's digest if there is one, so it can't be replayed elsewhere or with
+// another body.
+func Verify(req *http.Request, body []byte, key *rsa.PublicKey, now time.Time) error {
+	params, err := parseSignature(req.Header)
+	if err != nil {
+		return err
+	}
+	if alg := params["algorithm"]; alg != "" && alg != "rsa-sha256" && alg != "hs2019" {
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, alg)
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	required := []string{"(request-target)", "host", "date"}
+	if len(body) > 0 {
+		required = append(required, "digest")
+	}
+	for _, header := range required {
+		if !slices.Contains(headers, header) {
+			return fmt.Errorf("%w: %s isn't signed", ErrInvalidSignature, header)
+		}
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("%w: invalid date", ErrInvalidSignature)
+	}
+	if skew := now.Sub(date); skew > signatureTolerance || skew < -signatureTolerance {
+		return fmt.Errorf("%w: date is too far from now", ErrInvalidSignature)
+	}
+
+	if slices.Contains(headers, "digest") && !digestMatches(req.Header.Get("Digest"), body) {
+		return fmt.Errorf("%w: digest doesn't match the body", ErrInvalidSignature)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return fmt.Errorf("%w: signature isn't base64", ErrInvalidSignature)
+	}
+	signingString, err := signingString(req, headers)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(signingString))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature)
+	if err != nil {
+		return fmt.Errorf("%w: signature doesn't match", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// SignatureKeyID returns the ID of the key a request says it was signed
+// with. The signature still needs to be verified.
+func SignatureKeyID(header http.Header) (string, error) {
+	params, err := parseSignature(header)
+	if err != nil {
+		return "", err
+	}
+	return params["keyId"], nil
+}
+
+var signatureParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func parseSignature(header http.Header) (map[string]string, error) {
+	value := header.Get("Signature")
+	if value == "" {
+		return nil, fmt.Errorf("%w: no Signature header", ErrInvalidSignature)
+	}
+	params := map[string]string{}
+	for _, match := range signatureParam.FindAllStringSubmatch(value, -1) {
+		params[match[1]] = match[2]
+	}
+	if params["keyId"] == "" || params["signature"] == "" {
+		return nil, fmt.Errorf("%w: malformed Signature header", ErrInvalidSignature)
+	}
+	return params, nil
+}
+
+func signingString(req *http.Request, headers []string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, header := range headers {
+		var value string
+		switch header {
+		case "(request-target)":
+			value = strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			value = req.Host
+		default:
+			values := req.Header.Values(header)
+			if len(values) == 0 {
+				return "", fmt.Errorf("%w: signed header %s is missing", ErrInvalidSignature, header)
+			}
+			value = strings.Join(values, ", ")
+		}
+		lines = append(lines, header+": "+strings.TrimSpace(value))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// digestMatches checks the SHA-256 entry of a Digest header, which may list
+// digests made with other algorithms too.
+func digestMatches(header string, body []byte) bool {
+	want := digest(body)
+	for _, entry := range strings.Split(header, ",") {
+		entry = strings.TrimSpace(entry)
+		algorithm, _, _ := strings.Cut(entry, "=")
+		if strings.EqualFold(algorithm, "SHA-256") {
+			return "SHA-256="+entry[len(algorithm)+1:] == want
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package activitypub
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKey(t *testing.T) (*rsa.PrivateKey, KeyPair) {
+	pair, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	key, err := ParsePrivateKey(pair.PrivateKey)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey() error = %v", err)
+	}
+	return key, pair
+}
+
+func TestVerify(t *testing.T) {
+	key, _ := newTestKey(t)
+	otherKey, _ := newTestKey(t)
+	signedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"Follow"}`)
+
+	signed := func(t *testing.T) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "https://chirpy.example/users/1/inbox", strings.NewReader(string(body)))
+		err := Sign(req, body, "https://remote.example/users/bob#main-key", key, signedAt)
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		request func(t *testing.T) *http.Request
+		body    []byte
+		key     *rsa.PublicKey
+		now     time.Time
+		wantErr error
+	}{
+		{
+			name:    "Valid signature",
+			request: signed,
+			body:    body,
+			key:     &key.PublicKey,
+			now:     signedAt.Add(time.Minute),
+		},
+		{
+			name:    "Wrong key",
+			request: signed,
+			body:    body,
+			key:     &otherKey.PublicKey,
+			now:     signedAt,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Modified body",
+			request: signed,
+			body:    []byte(`{"type":"Delete"}`),
+			key:     &key.PublicKey,
+			now:     signedAt,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "Different path",
+			request: func(t *testing.T) *http.Request {
+				req := signed(t)
+				req.URL.Path = "/users/2/inbox"
+				return req
+			},
+			body:    body,
+			key:     &key.PublicKey,
+			now:     signedAt,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "Digest not signed",
+			request: func(t *testing.T) *http.Request {
+				req := signed(t)
+				req.Header.Set("Signature", strings.Replace(req.Header.Get("Signature"), " digest", "", 1))
+				return req
+			},
+			body:    body,
+			key:     &key.PublicKey,
+			now:     signedAt,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Too old",
+			request: signed,
+			body:    body,
+			key:     &key.PublicKey,
+			now:     signedAt.Add(2 * time.Hour),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "Unsigned",
+			request: func(t *testing.T) *http.Request {
+				req := signed(t)
+				req.Header.Del("Signature")
+				return req
+			},
+			body:    body,
+			key:     &key.PublicKey,
+			now:     signedAt,
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.request(t), tt.body, tt.key, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignatureKeyID(t *testing.T) {
+	header := http.Header{}
+	header.Set("Signature", `keyId="https://remote.example/users/bob#main-key",algorithm="rsa-sha256",headers="(request-target) host date",signature="c2ln"`)
+
+	keyID, err := SignatureKeyID(header)
+	if err != nil {
+		t.Fatalf("SignatureKeyID() error = %v", err)
+	}
+	if keyID != "https://remote.example/users/bob#main-key" {
+		t.Errorf("SignatureKeyID() = %q", keyID)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key, pair := newTestKey(t)
+	pkcs1 := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PUBLIC KEY",
+		Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey),
+	}))
+
+	tests := []struct {
+		name    string
+		pem     string
+		wantErr bool
+	}{
+		{
+			name: "PKIX",
+			pem:  pair.PublicKey,
+		},
+		{
+			name: "PKCS #1",
+			pem:  pkcs1,
+		},
+		{
+			name:    "Not PEM",
+			pem:     "not a key",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePublicKey(tt.pem)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePublicKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !got.Equal(&key.PublicKey) {
+				t.Error("ParsePublicKey() returned a different key")
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package activitypub
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DBStore is the Postgres-backed Store and DeliveryStore.
+type DBStore struct {
+	dbQueries *database.Queries
+}
+
+func NewDBStore(dbQueries *database.Queries) *DBStore {
+	return &DBStore{
+		dbQueries: dbQueries,
+	}
+}
+
+func (s *DBStore) GetKey(ctx context.Context, userID uuid.UUID) (KeyPair, error) {
+	dbKey, err := s.dbQueries.GetActorKey(ctx, userID)
+	if err == sql.ErrNoRows {
+		return KeyPair{}, ErrKeyNotFound
+	} else if err != nil {
+		return KeyPair{}, err
+	}
+	return KeyPair{
+		PublicKey:  dbKey.PublicKey,
+		PrivateKey: dbKey.PrivateKey,
+	}, nil
+}
+
+func (s *DBStore) CreateKey(ctx context.Context, userID uuid.UUID, key KeyPair) error {
+	return s.dbQueries.CreateActorKey(ctx, database.CreateActorKeyParams{
+		UserID:     userID,
+		PublicKey:  key.PublicKey,
+		PrivateKey: key.PrivateKey,
+	})
+}
+
+func (s *DBStore) GetActor(ctx context.Context, id string) (RemoteActor, error) {
+	dbActor, err := s.dbQueries.GetRemoteActor(ctx, id)
+	if err == sql.ErrNoRows {
+		return RemoteActor{}, ErrActorNotFound
+	} else if err != nil {
+		return RemoteActor{}, err
+	}
+	return RemoteActor{
+		ID:                dbActor.ID,
+		Inbox:             dbActor.Inbox,
+		SharedInbox:       dbActor.SharedInbox,
+		PreferredUsername: dbActor.PreferredUsername,
+		KeyID:             dbActor.KeyID,
+		PublicKey:         dbActor.PublicKey,
+		FetchedAt:         dbActor.FetchedAt,
+	}, nil
+}
+
+func (s *DBStore) SaveActor(ctx context.Context, actor RemoteActor) error {
+	return s.dbQueries.UpsertRemoteActor(ctx, database.UpsertRemoteActorParams{
+		ID:                actor.ID,
+		Inbox:             actor.Inbox,
+		SharedInbox:       actor.SharedInbox,
+		PreferredUsername: actor.PreferredUsername,
+		KeyID:             actor.KeyID,
+		PublicKey:         actor.PublicKey,
+		FetchedAt:         actor.FetchedAt,
+	})
+}
+
+func (s *DBStore) DeleteActor(ctx context.Context, id string) error {
+	return s.dbQueries.DeleteRemoteActor(ctx, id)
+}
+
+func (s *DBStore) AddFollower(ctx context.Context, userID uuid.UUID, actorID, followID string) error {
+	return s.dbQueries.UpsertRemoteFollower(ctx, database.UpsertRemoteFollowerParams{
+		UserID:   userID,
+		ActorID:  actorID,
+		FollowID: followID,
+	})
+}
+
+func (s *DBStore) RemoveFollower(ctx context.Context, userID uuid.UUID, actorID string) error {
+	return s.dbQueries.DeleteRemoteFollower(ctx, database.DeleteRemoteFollowerParams{
+		UserID:  userID,
+		ActorID: actorID,
+	})
+}
+
+func (s *DBStore) RemoveFollow(ctx context.Context, actorID, followID string) error {
+	return s.dbQueries.DeleteRemoteFollowByID(ctx, database.DeleteRemoteFollowByIDParams{
+		ActorID:  actorID,
+		FollowID: followID,
+	})
+}
+
+func (s *DBStore) FollowerInboxes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	rows, err := s.dbQueries.GetRemoteFollowerInboxes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	inboxes := make([]string, 0, len(rows))
+	for _, row := range rows {
+		inbox := row.SharedInbox
+		if inbox == "" {
+			inbox = row.Inbox
+		}
+		if !slices.Contains(inboxes, inbox) {
+			inboxes = append(inboxes, inbox)
+		}
+	}
+	return inboxes, nil
+}
+
+func (s *DBStore) Queue(ctx context.Context, outgoing Outgoing) error {
+	return s.dbQueries.CreateFederationDelivery(ctx, database.CreateFederationDeliveryParams{
+		UserID:     outgoing.UserID,
+		KeyID:      outgoing.KeyID,
+		Inbox:      outgoing.Inbox,
+		ActivityID: outgoing.ActivityID,
+		Activity:   outgoing.Activity,
+	})
+}
+
+func (s *DBStore) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error) {
+	rows, err := s.dbQueries.ClaimFederationDeliveries(ctx, database.ClaimFederationDeliveriesParams{
+		LeaseUntil: leaseUntil,
+		Now:        now,
+		Limit:      int32(limit),
+	})
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		userIDs = append(userIDs, row.UserID)
+	}
+	dbKeys, err := s.dbQueries.GetActorKeysByUserIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[uuid.UUID]string, len(dbKeys))
+	for _, dbKey := range dbKeys {
+		keys[dbKey.UserID] = dbKey.PrivateKey
+	}
+
+	deliveries := make([]Delivery, 0, len(rows))
+	for _, row := range rows {
+		// Users deleted since the claim take their deliveries with them
+		key, ok := keys[row.UserID]
+		if !ok {
+			continue
+		}
+		deliveries = append(deliveries, Delivery{
+			ID:         row.ID,
+			Inbox:      row.Inbox,
+			KeyID:      row.KeyID,
+			PrivateKey: key,
+			Activity:   row.Activity,
+			Attempts:   int(row.Attempts),
+		})
+	}
+	return deliveries, nil
+}
+
+func (s *DBStore) MarkDelivered(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return s.dbQueries.MarkFederationDeliveryDelivered(ctx, database.MarkFederationDeliveryDeliveredParams{
+		DeliveredAt: at,
+		ID:          id,
+	})
+}
+
+func (s *DBStore) Retry(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error {
+	return s.dbQueries.RetryFederationDelivery(ctx, database.RetryFederationDeliveryParams{
+		NextAttemptAt: at,
+		LastError:     lastError,
+		ID:            id,
+	})
+}
+
+func (s *DBStore) Fail(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error {
+	return s.dbQueries.FailFederationDelivery(ctx, database.FailFederationDeliveryParams{
+		FailedAt:  at,
+		LastError: lastError,
+		ID:        id,
+	})
+}
+
+func (s *DBStore) PurgeBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return s.dbQueries.DeleteFinishedFederationDeliveries(ctx, cutoff)
+}
@@ -0,0 +1,53 @@
+package activitypub
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// WebFingerContentType is the media type of WebFinger responses
+const WebFingerContentType = "application/jrd+json"
+
+var ErrInvalidResource = errors.New("invalid resource")
+
+// WebFinger is a JSON Resource Descriptor pointing at an actor.
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+// ParseResource splits a WebFinger resource such as acct:alice@chirpy.example
+// into a username and host. The acct: scheme and a leading @ are optional.
+func ParseResource(resource string) (username, host string, err error) {
+	resource = strings.TrimPrefix(resource, "acct:")
+	resource = strings.TrimPrefix(resource, "@")
+	username, host, ok := strings.Cut(resource, "@")
+	if !ok || username == "" || host == "" || strings.ContainsAny(host, "@/") {
+		return "", "", ErrInvalidResource
+	}
+	return username, host, nil
+}
+
+// WebFinger describes a local user for WebFinger lookups.
+func (f *Federation) WebFinger(userID uuid.UUID, handle string) WebFinger {
+	return WebFinger{
+		Subject: "acct:" + handle + "@" + f.Host(),
+		Aliases: []string{f.ActorURL(userID)},
+		Links: []WebFingerLink{
+			{
+				Rel:  "self",
+				Type: ContentType,
+				Href: f.ActorURL(userID),
+			},
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package activitypub
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseResource(t *testing.T) {
+	tests := []struct {
+		name         string
+		resource     string
+		wantUsername string
+		wantHost     string
+		wantErr      error
+	}{
+		{
+			name:         "acct URI",
+			resource:     "acct:alice@chirpy.example",
+			wantUsername: "alice",
+			wantHost:     "chirpy.example",
+		},
+		{
+			name:         "Handle",
+			resource:     "@alice@chirpy.example",
+			wantUsername: "alice",
+			wantHost:     "chirpy.example",
+		},
+		{
+			name:         "With a port",
+			resource:     "acct:alice@localhost:8080",
+			wantUsername: "alice",
+			wantHost:     "localhost:8080",
+		},
+		{
+			name:     "No host",
+			resource: "acct:alice",
+			wantErr:  ErrInvalidResource,
+		},
+		{
+			name:     "URL",
+			resource: "https://chirpy.example/users/alice",
+			wantErr:  ErrInvalidResource,
+		},
+		{
+			name:     "Empty",
+			resource: "",
+			wantErr:  ErrInvalidResource,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username, host, err := ParseResource(tt.resource)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseResource(%q) error = %v, wantErr %v", tt.resource, err, tt.wantErr)
+			}
+			if username != tt.wantUsername || host != tt.wantHost {
+				t.Errorf("ParseResource(%q) = %q, %q, want %q, %q", tt.resource, username, host, tt.wantUsername, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestWebFinger(t *testing.T) {
+	f := New(testBaseURL, newFakeStore())
+	userID := uuid.New()
+
+	got := f.WebFinger(userID, "alice")
+	if got.Subject != "acct:alice@chirpy.example" {
+		t.Errorf("Subject = %q, want %q", got.Subject, "acct:alice@chirpy.example")
+	}
+	if len(got.Links) != 1 || got.Links[0].Href != f.ActorURL(userID) || got.Links[0].Type != ContentType {
+		t.Errorf("Links = %+v, want a self link to %s", got.Links, f.ActorURL(userID))
+	}
+}
@@ -0,0 +1,180 @@
+package contentfilter
+
+import (
+	"bufio"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+type Action string
+
+const (
+	// Mask replaces the word with asterisks
+	Mask Action = "mask"
+	// Reject refuses the whole text
+	Reject Action = "reject"
+)
+
+const maskText = "****"
+
+func (a Action) Valid() bool {
+	return a == Mask || a == Reject
+}
+
+type Word struct {
+	Word   string
+	Action Action
+}
+
+// Match is a filtered word found in a text. Start and End are offsets in
+// Unicode code points into the original text.
+type Match struct {
+	Word   string
+	Action Action
+	Start  int
+	End    int
+}
+
+type Result struct {
+	// Text is the input with every masked word replaced
+	Text     string
+	Matches  []Match
+	Rejected bool
+}
+
+// Filter finds filtered words in text. Matching happens on normalized text
+// and only on whole words, so "Kerfuffle!" and "kérfuffle" are caught but
+// "kerfuffles" is not. The word list can be replaced while the filter is in
+// use.
+type Filter struct {
+	compiled atomic.Pointer[compiled]
+}
+
+type compiled struct {
+	words   []Word
+	lengths []int
+	matcher *matcher
+}
+
+func New(words []Word) *Filter {
+	f := &Filter{}
+	f.Replace(words)
+	return f
+}
+
+// Replace swaps in a new word list. When a word appears more than once,
+// Reject wins over Mask.
+func (f *Filter) Replace(words []Word) {
+	actions := map[string]Action{}
+	for _, word := range words {
+		normalized := strings.TrimSpace(Normalize(word.Word))
+		if len(normalized) == 0 {
+			continue
+		}
+		if actions[normalized] != Reject {
+			actions[normalized] = word.Action
+		}
+	}
+
+	c := &compiled{}
+	patterns := [][]rune{}
+	for _, word := range slices.Sorted(maps.Keys(actions)) {
+		pattern := []rune(word)
+		c.words = append(c.words, Word{Word: word, Action: actions[word]})
+		c.lengths = append(c.lengths, len(pattern))
+		patterns = append(patterns, pattern)
+	}
+	c.matcher = newMatcher(patterns)
+
+	f.compiled.Store(c)
+}
+
+// Apply checks text against the word list, masking any Mask words.
+func (f *Filter) Apply(text string) Result {
+	c := f.compiled.Load()
+	n := normalize(text)
+
+	result := Result{Matches: []Match{}}
+	for _, m := range c.matcher.find(n.runes, c.lengths) {
+		if m.start > 0 && isWordRune(n.runes[m.start-1]) {
+			continue
+		}
+		if m.end < len(n.runes) && isWordRune(n.runes[m.end]) {
+			continue
+		}
+
+		word := c.words[m.pattern]
+		result.Matches = append(result.Matches, Match{
+			Word:   word.Word,
+			Action: word.Action,
+			Start:  n.source[m.start],
+			End:    n.source[m.end-1] + 1,
+		})
+		if word.Action == Reject {
+			result.Rejected = true
+		}
+	}
+
+	result.Text = mask(text, result.Matches)
+	return result
+}
+
+// mask replaces the Mask matches in text, merging any that overlap.
+func mask(text string, matches []Match) string {
+	masked := []Match{}
+	for _, m := range matches {
+		if m.Action == Mask {
+			masked = append(masked, m)
+		}
+	}
+	if len(masked) == 0 {
+		return text
+	}
+	slices.SortFunc(masked, func(a, b Match) int {
+		return a.Start - b.Start
+	})
+
+	runes := []rune(text)
+	out := strings.Builder{}
+	pos := 0
+	for _, m := range masked {
+		if m.Start < pos {
+			if m.End > pos {
+				pos = m.End
+			}
+			continue
+		}
+		out.WriteString(string(runes[pos:m.Start]))
+		out.WriteString(maskText)
+		pos = m.End
+	}
+	out.WriteString(string(runes[pos:]))
+	return out.String()
+}
+
+// ParseWordList reads a word list with one entry per line, optionally
+// followed by an action: "word" or "word reject". Blank lines and lines
+// starting with # are ignored.
+func ParseWordList(r io.Reader) ([]Word, error) {
+	words := []Word{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		word := Word{Word: text, Action: Mask}
+		if i := strings.LastIndexAny(text, " \t"); i >= 0 {
+			action := Action(strings.TrimSpace(text[i+1:]))
+			if action.Valid() {
+				word = Word{Word: strings.TrimSpace(text[:i]), Action: action}
+			}
+		}
+		words = append(words, word)
+	}
+	return words, scanner.Err()
+}
@@ -0,0 +1,158 @@
+package contentfilter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	filter := New([]Word{
+		{Word: "kerfuffle", Action: Mask},
+		{Word: "sharbert", Action: Mask},
+		{Word: "fornax", Action: Mask},
+		{Word: "wretched hive", Action: Reject},
+	})
+
+	tests := []struct {
+		name         string
+		text         string
+		want         string
+		wantRejected bool
+	}{
+		{
+			name: "Clean",
+			text: "I had something interesting for breakfast",
+			want: "I had something interesting for breakfast",
+		},
+		{
+			name: "Case insensitive",
+			text: "I really need a KERFUFFLE to go to bed sooner, Fornax !",
+			want: "I really need a **** to go to bed sooner, **** !",
+		},
+		{
+			name: "Trailing punctuation",
+			text: "What a Kerfuffle! Such a kerfuffle, really.",
+			want: "What a ****! Such a ****, really.",
+		},
+		{
+			name: "Longer words aren't matched",
+			text: "Kerfuffles and sharberts",
+			want: "Kerfuffles and sharberts",
+		},
+		{
+			name: "Diacritics",
+			text: "a kérfüfflé indeed",
+			want: "a **** indeed",
+		},
+		{
+			name: "Confusables",
+			text: "shаrbеrt with Cyrillic letters and f0rn4x with digits",
+			want: "**** with Cyrillic letters and **** with digits",
+		},
+		{
+			name: "Full-width",
+			text: "ｆｏｒｎａｘ!",
+			want: "****!",
+		},
+		{
+			name:         "Reject phrase",
+			text:         "A WRETCHED   hive of scum",
+			want:         "A WRETCHED   hive of scum",
+			wantRejected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filter.Apply(tt.text)
+			if got.Text != tt.want {
+				t.Errorf("Apply() text = %q, want %q", got.Text, tt.want)
+			}
+			if got.Rejected != tt.wantRejected {
+				t.Errorf("Apply() rejected = %v, want %v", got.Rejected, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestApplyMatches(t *testing.T) {
+	filter := New([]Word{
+		{Word: "he", Action: Mask},
+		{Word: "she", Action: Mask},
+		{Word: "hers", Action: Mask},
+	})
+
+	got := filter.Apply("ushers, she, hers")
+	want := []Match{
+		{Word: "she", Action: Mask, Start: 8, End: 11},
+		{Word: "hers", Action: Mask, Start: 13, End: 17},
+	}
+	if !reflect.DeepEqual(got.Matches, want) {
+		t.Errorf("Apply() matches = %+v, want %+v", got.Matches, want)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	filter := New([]Word{{Word: "kerfuffle", Action: Mask}})
+	filter.Replace([]Word{
+		{Word: "sharbert", Action: Mask},
+		{Word: "Sharbert", Action: Reject},
+	})
+
+	got := filter.Apply("kerfuffle sharbert")
+	if got.Text != "kerfuffle sharbert" || !got.Rejected {
+		t.Errorf("Apply() after Replace() = %+v", got)
+	}
+}
+
+func TestParseWordList(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Word
+		wantErr bool
+	}{
+		{
+			name: "Words and actions",
+			input: `# House rules
+kerfuffle
+wretched hive reject
+
+sharbert	mask
+`,
+			want: []Word{
+				{Word: "kerfuffle", Action: Mask},
+				{Word: "wretched hive", Action: Reject},
+				{Word: "sharbert", Action: Mask},
+			},
+		},
+		{
+			name:  "Unknown action is part of the word",
+			input: "scruffy nerf herder",
+			want: []Word{
+				{Word: "scruffy nerf herder", Action: Mask},
+			},
+		},
+		{
+			name:  "Action on its own is a word",
+			input: "reject",
+			want: []Word{
+				{Word: "reject", Action: Mask},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWordList(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseWordList() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseWordList() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
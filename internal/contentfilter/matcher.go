@@ -0,0 +1,91 @@
+package contentfilter
+
+// matcher is an Aho-Corasick automaton over runes. It finds every
+// occurrence of every pattern in a single pass over the text.
+type matcher struct {
+	nodes []node
+}
+
+type node struct {
+	next   map[rune]int
+	fail   int
+	output []int // indexes of patterns ending at this node
+}
+
+type match struct {
+	pattern int
+	start   int
+	end     int
+}
+
+func newMatcher(patterns [][]rune) *matcher {
+	m := &matcher{nodes: []node{{next: map[rune]int{}}}}
+
+	for i, pattern := range patterns {
+		current := 0
+		for _, r := range pattern {
+			next, ok := m.nodes[current].next[r]
+			if !ok {
+				next = len(m.nodes)
+				m.nodes = append(m.nodes, node{next: map[rune]int{}})
+				m.nodes[current].next[r] = next
+			}
+			current = next
+		}
+		m.nodes[current].output = append(m.nodes[current].output, i)
+	}
+
+	// Breadth-first, so a node's failure link is always resolved before
+	// its children need it
+	queue := []int{}
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[current].next {
+			queue = append(queue, child)
+
+			fail := m.nodes[current].fail
+			for fail != 0 {
+				if _, ok := m.nodes[fail].next[r]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if next, ok := m.nodes[fail].next[r]; ok && next != child {
+				m.nodes[child].fail = next
+			}
+			m.nodes[child].output = append(m.nodes[child].output, m.nodes[m.nodes[child].fail].output...)
+		}
+	}
+
+	return m
+}
+
+// find returns all pattern occurrences in text. lengths holds the length of
+// each pattern so match starts can be recovered.
+func (m *matcher) find(text []rune, lengths []int) []match {
+	matches := []match{}
+	current := 0
+	for i, r := range text {
+		for current != 0 {
+			if _, ok := m.nodes[current].next[r]; ok {
+				break
+			}
+			current = m.nodes[current].fail
+		}
+		if next, ok := m.nodes[current].next[r]; ok {
+			current = next
+		}
+		for _, pattern := range m.nodes[current].output {
+			matches = append(matches, match{
+				pattern: pattern,
+				start:   i + 1 - lengths[pattern],
+				end:     i + 1,
+			})
+		}
+	}
+	return matches
+}
@@ -0,0 +1,68 @@
+package contentfilter
+
+import (
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps characters that are commonly substituted for Latin
+// letters to the letter they imitate. Compatibility decomposition already
+// handles full-width and stylised forms, so this only needs lookalikes from
+// other scripts and digits.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h',
+	'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i',
+	'ј': 'j', 'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v',
+	'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x',
+	// Digits
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't',
+}
+
+// normalized is a normalized form of some text, with the index of the
+// original rune each normalized rune came from.
+type normalized struct {
+	runes  []rune
+	source []int
+}
+
+// normalize folds case, strips diacritics, expands compatibility forms and
+// replaces confusable characters, so that visually similar spellings of a
+// word compare equal. Whitespace is collapsed to a single space.
+func normalize(text string) normalized {
+	n := normalized{}
+	for i, r := range []rune(text) {
+		if unicode.IsSpace(r) {
+			if len(n.runes) == 0 || n.runes[len(n.runes)-1] != ' ' {
+				n.runes = append(n.runes, ' ')
+				n.source = append(n.source, i)
+			}
+			continue
+		}
+
+		for _, d := range norm.NFKD.String(string(r)) {
+			if unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			d = unicode.ToLower(d)
+			if c, ok := confusables[d]; ok {
+				d = c
+			}
+			n.runes = append(n.runes, d)
+			n.source = append(n.source, i)
+		}
+	}
+	return n
+}
+
+// Normalize returns the canonical form of a filtered word.
+func Normalize(word string) string {
+	return string(normalize(word).runes)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
@@ -0,0 +1,100 @@
+package contentpolicy
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type Action int
+
+const (
+	Allow Action = iota
+	// Mask accepts the submission with a rewritten body
+	Mask
+	// Flag accepts the submission but queues it for review
+	Flag
+	// Reject refuses the submission
+	Reject
+)
+
+// Reason is a machine-readable code explaining why a policy acted.
+type Reason string
+
+const (
+	ReasonProfanity   Reason = "profanity"
+	ReasonBlockedLink Reason = "blocked_link"
+	ReasonSpam        Reason = "spam"
+	ReasonDuplicate   Reason = "duplicate"
+)
+
+// Submission is a chirp body being created or edited.
+type Submission struct {
+	AuthorID uuid.UUID
+	// ChirpID is the chirp being edited, or uuid.Nil for a new chirp
+	ChirpID uuid.UUID
+	Body    string
+}
+
+// Decision is a single policy's verdict on a submission.
+type Decision struct {
+	Action Action
+	Reason Reason
+	// Body is the rewritten body when Action is Mask
+	Body string
+}
+
+// Policy checks a submission against one rule.
+type Policy interface {
+	Check(ctx context.Context, sub Submission) (Decision, error)
+}
+
+// Result is the combined outcome of a Pipeline.
+type Result struct {
+	// Body is the submission's body after every Mask decision
+	Body     string
+	Rejected bool
+	// Reason is set when the submission was rejected
+	Reason Reason
+	Flags  []Reason
+}
+
+// Pipeline runs policies in order. Each policy sees the body as rewritten
+// by the policies before it, and the first rejection stops the pipeline.
+type Pipeline struct {
+	policies []Policy
+}
+
+func NewPipeline(policies ...Policy) *Pipeline {
+	return &Pipeline{
+		policies: policies,
+	}
+}
+
+func (p *Pipeline) Check(ctx context.Context, sub Submission) (Result, error) {
+	result := Result{
+		Body:  sub.Body,
+		Flags: []Reason{},
+	}
+
+	for _, policy := range p.policies {
+		sub.Body = result.Body
+		decision, err := policy.Check(ctx, sub)
+		if err != nil {
+			return Result{}, err
+		}
+
+		switch decision.Action {
+		case Mask:
+			result.Body = decision.Body
+		case Flag:
+			result.Flags = append(result.Flags, decision.Reason)
+		case Reject:
+			result.Rejected = true
+			result.Reason = decision.Reason
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
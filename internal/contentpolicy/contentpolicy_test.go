@@ -0,0 +1,201 @@
+package contentpolicy
+
+import (
+	"chirpy/internal/contentfilter"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fakePolicy struct {
+	decision Decision
+	err      error
+	seen     []string
+}
+
+func (p *fakePolicy) Check(ctx context.Context, sub Submission) (Decision, error) {
+	p.seen = append(p.seen, sub.Body)
+	return p.decision, p.err
+}
+
+type fakeDuplicateStore struct {
+	bodies map[uuid.UUID][]string
+}
+
+func (s *fakeDuplicateStore) RecentBodies(ctx context.Context, authorID uuid.UUID, excludeID uuid.UUID, since time.Time) ([]string, error) {
+	return s.bodies[authorID], nil
+}
+
+func TestPipeline(t *testing.T) {
+	errFailed := errors.New("policy failed")
+
+	tests := []struct {
+		name      string
+		decisions []Decision
+		err       error
+		want      Result
+		wantErr   bool
+	}{
+		{
+			name:      "All allow",
+			decisions: []Decision{{Action: Allow}, {Action: Allow}},
+			want:      Result{Body: "hello", Flags: []Reason{}},
+		},
+		{
+			name: "Mask and flag",
+			decisions: []Decision{
+				{Action: Mask, Reason: ReasonProfanity, Body: "****"},
+				{Action: Flag, Reason: ReasonSpam},
+			},
+			want: Result{Body: "****", Flags: []Reason{ReasonSpam}},
+		},
+		{
+			name: "Reject stops the pipeline",
+			decisions: []Decision{
+				{Action: Flag, Reason: ReasonSpam},
+				{Action: Reject, Reason: ReasonDuplicate},
+				{Action: Mask, Reason: ReasonProfanity, Body: "****"},
+			},
+			want: Result{Body: "hello", Rejected: true, Reason: ReasonDuplicate, Flags: []Reason{ReasonSpam}},
+		},
+		{
+			name:      "Error",
+			decisions: []Decision{{Action: Allow}},
+			err:       errFailed,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies := []Policy{}
+			for _, decision := range tt.decisions {
+				policies = append(policies, &fakePolicy{decision: decision, err: tt.err})
+			}
+
+			got, err := NewPipeline(policies...).Check(context.Background(), Submission{Body: "hello"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelinePassesMaskedBody(t *testing.T) {
+	mask := &fakePolicy{decision: Decision{Action: Mask, Body: "masked"}}
+	next := &fakePolicy{decision: Decision{Action: Allow}}
+
+	_, err := NewPipeline(mask, next).Check(context.Background(), Submission{Body: "original"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(next.seen, []string{"masked"}) {
+		t.Errorf("next policy saw %v, want [masked]", next.seen)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	authorID := uuid.New()
+	filter := contentfilter.New([]contentfilter.Word{
+		{Word: "kerfuffle", Action: contentfilter.Mask},
+		{Word: "fornax", Action: contentfilter.Reject},
+	})
+	duplicates := &fakeDuplicateStore{bodies: map[uuid.UUID][]string{
+		authorID: {"Good morning   everyone"},
+	}}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		body   string
+		want   Decision
+	}{
+		{
+			name:   "Profanity allows clean chirps",
+			policy: NewProfanity(filter),
+			body:   "What a lovely day",
+			want:   Decision{Action: Allow},
+		},
+		{
+			name:   "Profanity masks",
+			policy: NewProfanity(filter),
+			body:   "What a kerfuffle!",
+			want:   Decision{Action: Mask, Reason: ReasonProfanity, Body: "What a ****!"},
+		},
+		{
+			name:   "Profanity rejects",
+			policy: NewProfanity(filter),
+			body:   "Fornax",
+			want:   Decision{Action: Reject, Reason: ReasonProfanity},
+		},
+		{
+			name:   "Blocked domain",
+			policy: NewLinkBlocklist([]string{"spam.example"}),
+			body:   "Free stuff at https://spam.example/win",
+			want:   Decision{Action: Reject, Reason: ReasonBlockedLink},
+		},
+		{
+			name:   "Blocked subdomain without scheme",
+			policy: NewLinkBlocklist([]string{"spam.example"}),
+			body:   "Visit WWW.Spam.Example.",
+			want:   Decision{Action: Reject, Reason: ReasonBlockedLink},
+		},
+		{
+			name:   "Similar domain is allowed",
+			policy: NewLinkBlocklist([]string{"spam.example"}),
+			body:   "Visit notspam.example, e.g. today",
+			want:   Decision{Action: Allow},
+		},
+		{
+			name:   "Spam allows normal chirps",
+			policy: NewSpam(DefaultSpamLimits()),
+			body:   "Reading #golang docs with @alice at go.dev",
+			want:   Decision{Action: Allow},
+		},
+		{
+			name:   "Spam flags too many hashtags",
+			policy: NewSpam(DefaultSpamLimits()),
+			body:   "#a #b #c #d #e #f",
+			want:   Decision{Action: Flag, Reason: ReasonSpam},
+		},
+		{
+			name:   "Spam flags repeated characters",
+			policy: NewSpam(DefaultSpamLimits()),
+			body:   "wow" + strings.Repeat("!", 11),
+			want:   Decision{Action: Flag, Reason: ReasonSpam},
+		},
+		{
+			name:   "Duplicate",
+			policy: NewDuplicates(duplicates, time.Hour),
+			body:   "good MORNING everyone",
+			want:   Decision{Action: Reject, Reason: ReasonDuplicate},
+		},
+		{
+			name:   "Not a duplicate",
+			policy: NewDuplicates(duplicates, time.Hour),
+			body:   "Good evening everyone",
+			want:   Decision{Action: Allow},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.Check(context.Background(), Submission{AuthorID: authorID, Body: tt.body})
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Check() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
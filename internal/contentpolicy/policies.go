@@ -0,0 +1,175 @@
+package contentpolicy
+
+import (
+	"chirpy/internal/contentfilter"
+	"chirpy/internal/entities"
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Profanity masks or rejects words on the content filter's word list.
+type Profanity struct {
+	filter *contentfilter.Filter
+}
+
+func NewProfanity(filter *contentfilter.Filter) *Profanity {
+	return &Profanity{
+		filter: filter,
+	}
+}
+
+func (p *Profanity) Check(ctx context.Context, sub Submission) (Decision, error) {
+	filtered := p.filter.Apply(sub.Body)
+	if filtered.Rejected {
+		return Decision{Action: Reject, Reason: ReasonProfanity}, nil
+	}
+	if filtered.Text != sub.Body {
+		return Decision{Action: Mask, Reason: ReasonProfanity, Body: filtered.Text}, nil
+	}
+	return Decision{Action: Allow}, nil
+}
+
+// linkPattern matches URLs and bare domain names, capturing the host.
+var linkPattern = regexp.MustCompile(`(?i)(?:https?://)?((?:[\p{L}\p{N}-]+\.)+\p{L}{2,})\b`)
+
+func links(body string) []string {
+	hosts := []string{}
+	for _, match := range linkPattern.FindAllStringSubmatch(body, -1) {
+		hosts = append(hosts, strings.ToLower(match[1]))
+	}
+	return hosts
+}
+
+// LinkBlocklist rejects chirps linking to a blocked domain or any of its
+// subdomains.
+type LinkBlocklist struct {
+	domains map[string]struct{}
+}
+
+func NewLinkBlocklist(domains []string) *LinkBlocklist {
+	l := &LinkBlocklist{
+		domains: map[string]struct{}{},
+	}
+	for _, domain := range domains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if len(domain) > 0 {
+			l.domains[domain] = struct{}{}
+		}
+	}
+	return l
+}
+
+func (l *LinkBlocklist) Check(ctx context.Context, sub Submission) (Decision, error) {
+	for _, host := range links(sub.Body) {
+		for {
+			if _, ok := l.domains[host]; ok {
+				return Decision{Action: Reject, Reason: ReasonBlockedLink}, nil
+			}
+			i := strings.IndexByte(host, '.')
+			if i < 0 {
+				break
+			}
+			host = host[i+1:]
+		}
+	}
+	return Decision{Action: Allow}, nil
+}
+
+type SpamLimits struct {
+	MaxLinks    int
+	MaxMentions int
+	MaxHashtags int
+	// MaxRepeat is the longest run of one repeated character
+	MaxRepeat int
+}
+
+func DefaultSpamLimits() SpamLimits {
+	return SpamLimits{
+		MaxLinks:    3,
+		MaxMentions: 5,
+		MaxHashtags: 5,
+		MaxRepeat:   10,
+	}
+}
+
+// Spam flags chirps that look like spam for review. The heuristics are
+// crude, so nothing is rejected outright.
+type Spam struct {
+	limits SpamLimits
+}
+
+func NewSpam(limits SpamLimits) *Spam {
+	return &Spam{
+		limits: limits,
+	}
+}
+
+func (s *Spam) Check(ctx context.Context, sub Submission) (Decision, error) {
+	parsed := entities.Parse(sub.Body)
+	if len(links(sub.Body)) > s.limits.MaxLinks ||
+		len(entities.Mentions(parsed)) > s.limits.MaxMentions ||
+		len(entities.Hashtags(parsed)) > s.limits.MaxHashtags ||
+		longestRun(sub.Body) > s.limits.MaxRepeat {
+		return Decision{Action: Flag, Reason: ReasonSpam}, nil
+	}
+	return Decision{Action: Allow}, nil
+}
+
+func longestRun(text string) int {
+	longest, run := 0, 0
+	var prev rune
+	for i, r := range []rune(text) {
+		if i > 0 && r == prev {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+		prev = r
+	}
+	return longest
+}
+
+// DuplicateStore looks up an author's recent chirps.
+type DuplicateStore interface {
+	// RecentBodies returns the bodies of the author's chirps created after
+	// since, other than excludeID
+	RecentBodies(ctx context.Context, authorID uuid.UUID, excludeID uuid.UUID, since time.Time) ([]string, error)
+}
+
+// Duplicates rejects chirps that repeat one of the author's chirps from
+// within the window, ignoring case and spacing.
+type Duplicates struct {
+	store  DuplicateStore
+	window time.Duration
+}
+
+func NewDuplicates(store DuplicateStore, window time.Duration) *Duplicates {
+	return &Duplicates{
+		store:  store,
+		window: window,
+	}
+}
+
+func (d *Duplicates) Check(ctx context.Context, sub Submission) (Decision, error) {
+	bodies, err := d.store.RecentBodies(ctx, sub.AuthorID, sub.ChirpID, time.Now().Add(-d.window))
+	if err != nil {
+		return Decision{}, err
+	}
+
+	body := canonicalBody(sub.Body)
+	for _, recent := range bodies {
+		if canonicalBody(recent) == body {
+			return Decision{Action: Reject, Reason: ReasonDuplicate}, nil
+		}
+	}
+	return Decision{Action: Allow}, nil
+}
+
+func canonicalBody(body string) string {
+	return strings.Join(strings.Fields(contentfilter.Normalize(body)), " ")
+}
@@ -0,0 +1,28 @@
+package contentpolicy
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DBDuplicateStore reads recent chirps from the database.
+type DBDuplicateStore struct {
+	dbQueries *database.Queries
+}
+
+func NewDBDuplicateStore(dbQueries *database.Queries) *DBDuplicateStore {
+	return &DBDuplicateStore{
+		dbQueries: dbQueries,
+	}
+}
+
+func (s *DBDuplicateStore) RecentBodies(ctx context.Context, authorID uuid.UUID, excludeID uuid.UUID, since time.Time) ([]string, error) {
+	return s.dbQueries.GetRecentChirpBodies(ctx, database.GetRecentChirpBodiesParams{
+		UserID:    authorID,
+		ExcludeID: excludeID,
+		Since:     since,
+	})
+}
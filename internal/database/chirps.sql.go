@@ -7,29 +7,47 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countPublicChirpsByUser = `-- name: CountPublicChirpsByUser :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL AND hidden_at IS NULL AND visibility = 'public'
+`
+
+func (q *Queries) CountPublicChirpsByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPublicChirpsByUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quote_of, visibility)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, quote_of, hidden_at, deleted_at, visibility
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body       string
+	UserID     uuid.UUID
+	QuoteOf    uuid.NullUUID
+	Visibility string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.QuoteOf, arg.Visibility)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,12 +55,18 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.QuoteOf,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.Visibility,
 	)
 	return i, err
 }
 
 const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps WHERE id = $1
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
@@ -51,7 +75,7 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, quote_of, hidden_at, deleted_at, visibility FROM chirps WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -63,17 +87,103 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.QuoteOf,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getChirpForViewer = `-- name: GetChirpForViewer :one
+SELECT id, created_at, updated_at, body, user_id, quote_of, hidden_at, deleted_at, visibility FROM chirps
+WHERE id = $1 AND deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid
+) AND (
+    chirps.hidden_at IS NULL OR chirps.user_id = $2::uuid OR $3::boolean
+) AND (
+    chirps.visibility = 'public' OR chirps.user_id = $2::uuid OR (
+        chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $2::uuid AND follows.followee_id = chirps.user_id
+        )
+    )
+)
+`
+
+type GetChirpForViewerParams struct {
+	ID                uuid.UUID
+	ViewerID          uuid.UUID
+	ViewerIsModerator bool
+}
+
+func (q *Queries) GetChirpForViewer(ctx context.Context, arg GetChirpForViewerParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForViewer, arg.ID, arg.ViewerID, arg.ViewerIsModerator)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.QuoteOf,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getChirpIncludingDeleted = `-- name: GetChirpIncludingDeleted :one
+SELECT id, created_at, updated_at, body, user_id, quote_of, hidden_at, deleted_at, visibility FROM chirps WHERE id = $1
+`
+
+func (q *Queries) GetChirpIncludingDeleted(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpIncludingDeleted, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.QuoteOf,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.Visibility,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, quote_of, hidden_at, deleted_at, visibility FROM chirps
+WHERE deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1::uuid
+) AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $1::uuid AND mutes.muted_id = chirps.user_id
+) AND (
+    chirps.hidden_at IS NULL OR chirps.user_id = $1::uuid OR $2::boolean
+) AND (
+    chirps.visibility = 'public' OR chirps.user_id = $1::uuid OR (
+        chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $1::uuid AND follows.followee_id = chirps.user_id
+        )
+    )
+)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+type GetChirpsParams struct {
+	ViewerID          uuid.UUID
+	ViewerIsModerator bool
+}
+
+func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, arg.ViewerID, arg.ViewerIsModerator)
 	if err != nil {
 		return nil, err
 	}
@@ -87,6 +197,65 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.QuoteOf,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, quote_of, hidden_at, deleted_at, visibility FROM chirps
+WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid
+) AND (
+    chirps.hidden_at IS NULL OR chirps.user_id = $2::uuid
+) AND (
+    chirps.visibility = 'public' OR chirps.user_id = $2::uuid OR (
+        chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $2::uuid AND follows.followee_id = chirps.user_id
+        )
+    )
+)
+`
+
+type GetChirpsByIDsParams struct {
+	Ids      []uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, arg GetChirpsByIDsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(arg.Ids), arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.QuoteOf,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -102,13 +271,71 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUser = `-- name: GetChirpsByUser :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
+SELECT id, created_at, updated_at, body, user_id, quote_of, hidden_at, deleted_at, visibility FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid
+) AND (
+    chirps.hidden_at IS NULL OR chirps.user_id = $2::uuid OR $3::boolean
+) AND (
+    chirps.visibility = 'public' OR chirps.user_id = $2::uuid OR (
+        chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $2::uuid AND follows.followee_id = chirps.user_id
+        )
+    )
+)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUser, userID)
+type GetChirpsByUserParams struct {
+	UserID            uuid.UUID
+	ViewerID          uuid.UUID
+	ViewerIsModerator bool
+}
+
+func (q *Queries) GetChirpsByUser(ctx context.Context, arg GetChirpsByUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUser, arg.UserID, arg.ViewerID, arg.ViewerIsModerator)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.QuoteOf,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPublicChirps = `-- name: GetPublicChirps :many
+SELECT id, created_at, updated_at, body, user_id, quote_of, hidden_at, deleted_at, visibility FROM chirps
+WHERE deleted_at IS NULL AND hidden_at IS NULL AND visibility = 'public'
+ORDER BY created_at DESC
+LIMIT $1
+`
+
+func (q *Queries) GetPublicChirps(ctx context.Context, limit int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getPublicChirps, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -122,6 +349,10 @@ func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chir
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.QuoteOf,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -135,3 +366,193 @@ func (q *Queries) GetChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chir
 	}
 	return items, nil
 }
+
+const getPublicChirpsByUser = `-- name: GetPublicChirpsByUser :many
+SELECT id, created_at, updated_at, body, user_id, quote_of, hidden_at, deleted_at, visibility FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL AND hidden_at IS NULL AND visibility = 'public'
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type GetPublicChirpsByUserParams struct {
+	UserID uuid.UUID
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) GetPublicChirpsByUser(ctx context.Context, arg GetPublicChirpsByUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getPublicChirpsByUser, arg.UserID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.QuoteOf,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecentChirpBodies = `-- name: GetRecentChirpBodies :many
+SELECT body FROM chirps
+WHERE user_id = $1 AND id <> $2::uuid AND created_at > $3::timestamp AND deleted_at IS NULL
+`
+
+type GetRecentChirpBodiesParams struct {
+	UserID    uuid.UUID
+	ExcludeID uuid.UUID
+	Since     time.Time
+}
+
+func (q *Queries) GetRecentChirpBodies(ctx context.Context, arg GetRecentChirpBodiesParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentChirpBodies, arg.UserID, arg.ExcludeID, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var body string
+		if err := rows.Scan(&body); err != nil {
+			return nil, err
+		}
+		items = append(items, body)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1 AND hidden_at IS NULL
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const isChirpVisibleTo = `-- name: IsChirpVisibleTo :one
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = $1::uuid
+        AND chirps.hidden_at IS NULL
+        AND chirps.deleted_at IS NULL
+        AND (
+            chirps.user_id = $2::uuid OR chirps.visibility = 'public' OR (
+                chirps.visibility = 'followers' AND EXISTS (
+                    SELECT 1 FROM follows
+                    WHERE follows.follower_id = $2::uuid AND follows.followee_id = chirps.user_id
+                )
+            )
+        )
+)
+`
+
+type IsChirpVisibleToParams struct {
+	ChirpID  uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) IsChirpVisibleTo(ctx context.Context, arg IsChirpVisibleToParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isChirpVisibleTo, arg.ChirpID, arg.ViewerID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps WHERE deleted_at < $1::timestamp
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at > $2::timestamp
+RETURNING id, created_at, updated_at, body, user_id, quote_of, hidden_at, deleted_at, visibility
+`
+
+type RestoreChirpParams struct {
+	ID           uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.DeletedAfter)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.QuoteOf,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, quote_of, hidden_at, deleted_at, visibility
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.QuoteOf,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.Visibility,
+	)
+	return i, err
+}
@@ -6,17 +6,231 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ActorKey struct {
+	UserID     uuid.UUID
+	CreatedAt  time.Time
+	PublicKey  string
+	PrivateKey string
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type Bookmark struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	ChirpID      uuid.UUID
+	CollectionID uuid.NullUUID
+}
+
 type Chirp struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Body       string
+	UserID     uuid.UUID
+	QuoteOf    uuid.NullUUID
+	HiddenAt   sql.NullTime
+	DeletedAt  sql.NullTime
+	Visibility string
+}
+
+type ChirpAttachment struct {
+	ChirpID  uuid.UUID
+	MediaID  uuid.UUID
+	Position int32
+}
+
+type ChirpMention struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+}
+
+type ChirpTag struct {
+	ChirpID uuid.UUID
+	Tag     string
+}
+
+type Collection struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	CreatedBy uuid.NullUUID
+	IsGroup   bool
+	UserLow   uuid.NullUUID
+	UserHigh  uuid.NullUUID
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type Draft struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Body       string
+	QuoteOf    uuid.NullUUID
+	MediaIds   []uuid.UUID
+	PublishAt  sql.NullTime
+	Status     string
+	Error      string
+	Visibility sql.NullString
+	Attempts   int32
+	RetryAt    sql.NullTime
+}
+
+type FederationDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UserID        uuid.UUID
+	KeyID         string
+	Inbox         string
+	ActivityID    string
+	Activity      json.RawMessage
+	Attempts      int32
+	NextAttemptAt sql.NullTime
+	DeliveredAt   sql.NullTime
+	FailedAt      sql.NullTime
+	LastError     string
+}
+
+type FilterWord struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Word      string
+	Action    string
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type MediaFile struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	ContentType  string
+	SizeBytes    int32
+	Width        int32
+	Height       int32
+	StorageKey   string
+	ThumbnailKey string
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+type ModerationAction struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	ModeratorID  uuid.NullUUID
+	ReportID     uuid.NullUUID
+	ChirpID      uuid.NullUUID
+	TargetUserID uuid.NullUUID
+	Action       string
+	Note         string
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Body      string
 	UserID    uuid.UUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	GroupKey  string
+	ReadAt    sql.NullTime
+}
+
+type NotificationActor struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+	CreatedAt      time.Time
+}
+
+type NotificationPreference struct {
+	UserID  uuid.UUID
+	Type    string
+	Channel string
+	Enabled bool
+}
+
+type OutboxEvent struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	Type          string
+	Payload       json.RawMessage
+	Attempts      int32
+	NextAttemptAt sql.NullTime
+	DeliveredAt   sql.NullTime
+	FailedAt      sql.NullTime
+	LastError     string
+}
+
+type Poll struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ChirpID   uuid.UUID
+	ClosesAt  time.Time
+	ClosedAt  sql.NullTime
+}
+
+type PollOption struct {
+	ID       uuid.UUID
+	PollID   uuid.UUID
+	Position int32
+	Text     string
+}
+
+type PollVote struct {
+	PollID    uuid.UUID
+	UserID    uuid.UUID
+	OptionID  uuid.UUID
+	CreatedAt time.Time
+}
+
+type Rechirp struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
 }
 
 type RefreshToken struct {
@@ -28,11 +242,85 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type RemoteActor struct {
+	ID                string
+	Inbox             string
+	SharedInbox       string
+	PreferredUsername string
+	KeyID             string
+	PublicKey         string
+	FetchedAt         time.Time
+}
+
+type RemoteFollower struct {
+	UserID    uuid.UUID
+	ActorID   string
+	FollowID  string
+	CreatedAt time.Time
+}
+
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ChirpID    uuid.UUID
+	ReporterID uuid.NullUUID
+	Reason     string
+	Details    string
+	Status     string
+}
+
+type TrendingTag struct {
+	WindowName    string
+	Tag           string
+	Rank          int32
+	Score         float64
+	RecentCount   int32
+	BaselineCount int32
+	ComputedAt    time.Time
+}
+
 type User struct {
+	ID                uuid.UUID
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Email             string
+	HashedPassword    string
+	IsChirpyRed       bool
+	Handle            sql.NullString
+	Role              string
+	SuspendedUntil    sql.NullTime
+	BannedAt          sql.NullTime
+	SuspensionReason  string
+	DefaultVisibility string
+	DisplayName       string
+	Bio               string
+	AvatarID          uuid.NullUUID
+}
+
+type Webhook struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	UserID              uuid.UUID
+	Url                 string
+	Secret              string
+	EventTypes          []string
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
+}
+
+type WebhookDelivery struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	WebhookID      uuid.UUID
+	EventID        uuid.NullUUID
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  sql.NullTime
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	LastError      string
 }
@@ -64,7 +64,7 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshTok
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE id = (
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id FROM users WHERE id = (
     SELECT user_id FROM refresh_tokens WHERE token = $1
 )
 `
@@ -79,6 +79,15 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Us
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.SuspensionReason,
+		&i.DefaultVisibility,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarID,
 	)
 	return i, err
 }
@@ -103,3 +112,14 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) (Refresh
 	)
 	return i, err
 }
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
+	return err
+}
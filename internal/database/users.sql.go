@@ -7,29 +7,68 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const banUser = `-- name: BanUser :one
+UPDATE users
+SET banned_at = NOW(), suspension_reason = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id
+`
+
+type BanUserParams struct {
+	ID               uuid.UUID
+	SuspensionReason string
+}
+
+func (q *Queries) BanUser(ctx context.Context, arg BanUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, banUser, arg.ID, arg.SuspensionReason)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.SuspensionReason,
+		&i.DefaultVisibility,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarID,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Handle)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -38,6 +77,15 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.SuspensionReason,
+		&i.DefaultVisibility,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarID,
 	)
 	return i, err
 }
@@ -51,8 +99,60 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 	return err
 }
 
+const getMentionableUsers = `-- name: GetMentionableUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id FROM users
+WHERE LOWER(handle) = ANY($1::text[]) AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = users.id AND blocks.blocked_id = $2::uuid
+)
+`
+
+type GetMentionableUsersParams struct {
+	Handles  []string
+	AuthorID uuid.UUID
+}
+
+func (q *Queries) GetMentionableUsers(ctx context.Context, arg GetMentionableUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getMentionableUsers, pq.Array(arg.Handles), arg.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Handle,
+			&i.Role,
+			&i.SuspendedUntil,
+			&i.BannedAt,
+			&i.SuspensionReason,
+			&i.DefaultVisibility,
+			&i.DisplayName,
+			&i.Bio,
+			&i.AvatarID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE id = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
@@ -65,12 +165,21 @@ func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.SuspensionReason,
+		&i.DefaultVisibility,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarID,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -83,25 +192,181 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.SuspensionReason,
+		&i.DefaultVisibility,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarID,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id FROM users WHERE LOWER(handle) = LOWER($1::text)
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.SuspensionReason,
+		&i.DefaultVisibility,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarID,
 	)
 	return i, err
 }
 
-const updateUser = `-- name: UpdateUser :one
+const getUsersByIDs = `-- name: GetUsersByIDs :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id FROM users WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Handle,
+			&i.Role,
+			&i.SuspendedUntil,
+			&i.BannedAt,
+			&i.SuspensionReason,
+			&i.DefaultVisibility,
+			&i.DisplayName,
+			&i.Bio,
+			&i.AvatarID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const liftSuspension = `-- name: LiftSuspension :one
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
+SET suspended_until = NULL, banned_at = NULL, suspension_reason = '', updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id
 `
 
-type UpdateUserParams struct {
-	ID             uuid.UUID
-	Email          string
-	HashedPassword string
+func (q *Queries) LiftSuspension(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, liftSuspension, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.SuspensionReason,
+		&i.DefaultVisibility,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarID,
+	)
+	return i, err
+}
+
+const patchUser = `-- name: PatchUser :one
+UPDATE users
+SET email = COALESCE($1::text, email), hashed_password = COALESCE($2::text, hashed_password),
+    handle = COALESCE($3::text, handle), default_visibility = COALESCE($4::text, default_visibility),
+    display_name = COALESCE($5::text, display_name), bio = COALESCE($6::text, bio),
+    avatar_id = CASE WHEN $7::boolean THEN $8::uuid ELSE avatar_id END,
+    updated_at = NOW()
+WHERE id = $9::uuid AND ($10::timestamp IS NULL OR updated_at = $10::timestamp)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id
+`
+
+type PatchUserParams struct {
+	Email             sql.NullString
+	HashedPassword    sql.NullString
+	Handle            sql.NullString
+	DefaultVisibility sql.NullString
+	DisplayName       sql.NullString
+	Bio               sql.NullString
+	SetAvatar         bool
+	AvatarID          uuid.NullUUID
+	ID                uuid.UUID
+	IfUpdatedAt       sql.NullTime
+}
+
+func (q *Queries) PatchUser(ctx context.Context, arg PatchUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, patchUser, arg.Email, arg.HashedPassword, arg.Handle, arg.DefaultVisibility, arg.DisplayName, arg.Bio, arg.SetAvatar, arg.AvatarID, arg.ID, arg.IfUpdatedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.SuspensionReason,
+		&i.DefaultVisibility,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarID,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users
+SET suspended_until = $2, suspension_reason = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id
+`
+
+type SuspendUserParams struct {
+	ID               uuid.UUID
+	SuspendedUntil   sql.NullTime
+	SuspensionReason string
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.ID, arg.Email, arg.HashedPassword)
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, arg.ID, arg.SuspendedUntil, arg.SuspensionReason)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -110,6 +375,15 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.SuspensionReason,
+		&i.DefaultVisibility,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarID,
 	)
 	return i, err
 }
@@ -118,7 +392,7 @@ const updateUserToChirpyRed = `-- name: UpdateUserToChirpyRed :one
 UPDATE users
 SET is_chirpy_red = true, updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, role, suspended_until, banned_at, suspension_reason, default_visibility, display_name, bio, avatar_id
 `
 
 func (q *Queries) UpdateUserToChirpyRed(ctx context.Context, id uuid.UUID) (User, error) {
@@ -131,6 +405,15 @@ func (q *Queries) UpdateUserToChirpyRed(ctx context.Context, id uuid.UUID) (User
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+		&i.SuspensionReason,
+		&i.DefaultVisibility,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarID,
 	)
 	return i, err
 }
@@ -0,0 +1,134 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Type string
+
+const (
+	Hashtag Type = "hashtag"
+	Mention Type = "mention"
+)
+
+const MaxHandleLength = 30
+
+// Entity is a hashtag or mention found in a chirp body. Start and End are
+// offsets in Unicode code points, with Start pointing at the leading # or @
+// and End just past the last character.
+type Entity struct {
+	Type  Type   `json:"type"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Parse extracts hashtags and mentions from body in the order they appear.
+// A sigil only starts an entity at the beginning of the body or after a
+// character that can't be part of one, so e-mail addresses and URL fragments
+// aren't matched. Hashtags must contain at least one letter.
+func Parse(body string) []Entity {
+	runes := []rune(body)
+	entities := []Entity{}
+
+	for i := 0; i < len(runes); i++ {
+		var entityType Type
+		switch runes[i] {
+		case '#':
+			entityType = Hashtag
+		case '@':
+			entityType = Mention
+		default:
+			continue
+		}
+		if i > 0 && (isWordRune(runes[i-1]) || runes[i-1] == '#' || runes[i-1] == '@' || runes[i-1] == '&') {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		text := string(runes[i+1 : end])
+
+		switch entityType {
+		case Hashtag:
+			if !strings.ContainsFunc(text, unicode.IsLetter) {
+				continue
+			}
+		case Mention:
+			// user@host style handles refer to other servers
+			if end < len(runes) && runes[end] == '@' {
+				continue
+			}
+			if !IsValidHandle(text) {
+				continue
+			}
+		}
+
+		entities = append(entities, Entity{
+			Type:  entityType,
+			Text:  text,
+			Start: i,
+			End:   end,
+		})
+		i = end - 1
+	}
+
+	return entities
+}
+
+// Hashtags returns the distinct normalized hashtags in entities.
+func Hashtags(entities []Entity) []string {
+	return distinct(entities, Hashtag)
+}
+
+// Mentions returns the distinct normalized handles mentioned in entities.
+func Mentions(entities []Entity) []string {
+	return distinct(entities, Mention)
+}
+
+// Normalize returns the canonical form of a hashtag or handle used for
+// storage and lookups. A leading sigil is removed.
+func Normalize(text string) string {
+	text = strings.TrimPrefix(text, "#")
+	text = strings.TrimPrefix(text, "@")
+	return strings.ToLower(text)
+}
+
+// IsValidHandle reports whether handle can be mentioned in a chirp.
+func IsValidHandle(handle string) bool {
+	length := utf8.RuneCountInString(handle)
+	if length == 0 || length > MaxHandleLength {
+		return false
+	}
+	for _, r := range handle {
+		if !isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r) || r == '_'
+}
+
+func distinct(entities []Entity, entityType Type) []string {
+	seen := map[string]struct{}{}
+	values := []string{}
+	for _, entity := range entities {
+		if entity.Type != entityType {
+			continue
+		}
+		value := Normalize(entity.Text)
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		values = append(values, value)
+	}
+	return values
+}
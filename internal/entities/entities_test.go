@@ -0,0 +1,101 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Entity
+	}{
+		{
+			name: "No entities",
+			body: "just a chirp",
+			want: []Entity{},
+		},
+		{
+			name: "Hashtag and mention",
+			body: "hi @bob #golang",
+			want: []Entity{
+				{Type: Mention, Text: "bob", Start: 3, End: 7},
+				{Type: Hashtag, Text: "golang", Start: 8, End: 15},
+			},
+		},
+		{
+			name: "Punctuation ends entity",
+			body: "#go, @ann!",
+			want: []Entity{
+				{Type: Hashtag, Text: "go", Start: 0, End: 3},
+				{Type: Mention, Text: "ann", Start: 5, End: 9},
+			},
+		},
+		{
+			name: "Unicode offsets in code points",
+			body: "café #crème @josé",
+			want: []Entity{
+				{Type: Hashtag, Text: "crème", Start: 5, End: 11},
+				{Type: Mention, Text: "josé", Start: 12, End: 17},
+			},
+		},
+		{
+			name: "Non-Latin hashtag",
+			body: "#日本語",
+			want: []Entity{
+				{Type: Hashtag, Text: "日本語", Start: 0, End: 4},
+			},
+		},
+		{
+			name: "Email address is not a mention",
+			body: "mail me at bob@example.com",
+			want: []Entity{},
+		},
+		{
+			name: "Remote handle is not a mention",
+			body: "@bob@example.com",
+			want: []Entity{},
+		},
+		{
+			name: "Numeric hashtag ignored",
+			body: "#1 fan",
+			want: []Entity{},
+		},
+		{
+			name: "HTML entity ignored",
+			body: "it&#39;s",
+			want: []Entity{},
+		},
+		{
+			name: "Bare sigils",
+			body: "# @ ##",
+			want: []Entity{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashtagsAndMentions(t *testing.T) {
+	parsed := Parse("#Go #go @Bob @bob #Rust")
+
+	gotTags := Hashtags(parsed)
+	wantTags := []string{"go", "rust"}
+	if !reflect.DeepEqual(gotTags, wantTags) {
+		t.Errorf("Hashtags() = %v, want %v", gotTags, wantTags)
+	}
+
+	gotMentions := Mentions(parsed)
+	wantMentions := []string{"bob"}
+	if !reflect.DeepEqual(gotMentions, wantMentions) {
+		t.Errorf("Mentions() = %v, want %v", gotMentions, wantMentions)
+	}
+}
@@ -0,0 +1,36 @@
+// Package entitlements describes what each plan lets a user do. Handlers
+// should check limits here instead of testing for Chirpy Red directly.
+package entitlements
+
+type Plan string
+
+const (
+	Free      Plan = "free"
+	ChirpyRed Plan = "chirpy_red"
+)
+
+type Entitlements struct {
+	Plan Plan
+	// MaxConversationSize counts every participant, including the creator
+	MaxConversationSize int
+}
+
+var plans = map[Plan]Entitlements{
+	Free: {
+		Plan:                Free,
+		MaxConversationSize: 5,
+	},
+	ChirpyRed: {
+		Plan:                ChirpyRed,
+		MaxConversationSize: 25,
+	},
+}
+
+// For returns the entitlements of a user, given whether they have Chirpy
+// Red.
+func For(isChirpyRed bool) Entitlements {
+	if isChirpyRed {
+		return plans[ChirpyRed]
+	}
+	return plans[Free]
+}
@@ -0,0 +1,41 @@
+package entitlements
+
+import "testing"
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		name        string
+		isChirpyRed bool
+		wantPlan    Plan
+	}{
+		{
+			name:     "Free user",
+			wantPlan: Free,
+		},
+		{
+			name:        "Chirpy Red user",
+			isChirpyRed: true,
+			wantPlan:    ChirpyRed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := For(tt.isChirpyRed)
+			if got.Plan != tt.wantPlan {
+				t.Errorf("For() plan = %v, want %v", got.Plan, tt.wantPlan)
+			}
+			if got.MaxConversationSize < 2 {
+				t.Errorf("For() MaxConversationSize = %d, want at least 2", got.MaxConversationSize)
+			}
+		})
+	}
+}
+
+func TestChirpyRedUnlocksLargerConversations(t *testing.T) {
+	free := For(false)
+	red := For(true)
+	if red.MaxConversationSize <= free.MaxConversationSize {
+		t.Errorf("Chirpy Red MaxConversationSize = %d, want more than %d", red.MaxConversationSize, free.MaxConversationSize)
+	}
+}
@@ -0,0 +1,168 @@
+package events
+
+import (
+	"chirpy/internal/clock"
+	"chirpy/internal/worker"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// BatchSize is how many events are claimed at once
+	BatchSize = 100
+	// Lease is how long a claimed event is left alone before it's assumed
+	// lost and claimed again
+	Lease = 5 * time.Minute
+	// MaxAttempts is how many times an event is tried before it's given up on
+	MaxAttempts = 10
+	// Retention is how long delivered events are kept
+	Retention = 7 * 24 * time.Hour
+
+	baseBackoff = 5 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// Record is an event as it's stored in the outbox.
+type Record struct {
+	ID        uuid.UUID
+	Type      string
+	Payload   []byte
+	CreatedAt time.Time
+	// Attempts includes the current one
+	Attempts int
+}
+
+// Store is the outbox.
+type Store interface {
+	// Claim returns up to limit events due at now and hides them from other
+	// claims until leaseUntil
+	Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Record, error)
+	MarkDelivered(ctx context.Context, id uuid.UUID, at time.Time) error
+	// Retry schedules another attempt
+	Retry(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error
+	// Fail gives up on an event
+	Fail(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error
+	// PurgeDeliveredBefore removes events delivered before cutoff
+	PurgeDeliveredBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// Handler reacts to an event. It must be safe to call more than once for
+// the same event.
+type Handler func(ctx context.Context, record Record) error
+
+type subscriber struct {
+	name   string
+	handle Handler
+}
+
+// Dispatcher delivers events from the outbox to in-process subscribers.
+// Delivery is at least once: if any subscriber fails, every subscriber to
+// the event gets it again after a backoff.
+type Dispatcher struct {
+	store       Store
+	clock       clock.Clock
+	interval    time.Duration
+	subscribers map[string][]subscriber
+}
+
+func NewDispatcher(store Store, interval time.Duration) *Dispatcher {
+	return &Dispatcher{
+		store:       store,
+		clock:       clock.Real{},
+		interval:    interval,
+		subscribers: map[string][]subscriber{},
+	}
+}
+
+// WithClock replaces the dispatcher's clock, for tests.
+func (d *Dispatcher) WithClock(clock clock.Clock) *Dispatcher {
+	d.clock = clock
+	return d
+}
+
+// Subscribe registers a handler for an event type. Subscribers must be
+// registered before Run is called.
+func (d *Dispatcher) Subscribe(eventType, name string, handle Handler) {
+	d.subscribers[eventType] = append(d.subscribers[eventType], subscriber{
+		name:   name,
+		handle: handle,
+	})
+}
+
+// Subscribe registers a handler that receives decoded events of type E.
+func Subscribe[E Event](d *Dispatcher, name string, handle func(ctx context.Context, event E) error) {
+	var zero E
+	d.Subscribe(zero.EventType(), name, func(ctx context.Context, record Record) error {
+		var event E
+		err := json.Unmarshal(record.Payload, &event)
+		if err != nil {
+			return fmt.Errorf("decoding %s event: %w", record.Type, err)
+		}
+		return handle(ctx, event)
+	})
+}
+
+// Run dispatches due events every interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	worker.Queue{
+		Name:      "events",
+		BatchSize: BatchSize,
+		Process:   d.Dispatch,
+		Purge: func(ctx context.Context) (int64, error) {
+			return d.store.PurgeDeliveredBefore(ctx, d.clock.Now().Add(-Retention))
+		},
+	}.Run(ctx, d.interval)
+}
+
+// Dispatch delivers a batch of due events and returns how many it claimed.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	now := d.clock.Now()
+	records, err := d.store.Claim(ctx, now, now.Add(Lease), BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, record := range records {
+		err = d.deliver(ctx, record)
+		if err != nil {
+			return len(records), err
+		}
+	}
+	return len(records), nil
+}
+
+// deliver hands an event to its subscribers and records the outcome. It
+// only returns errors from the store.
+func (d *Dispatcher) deliver(ctx context.Context, record Record) error {
+	errs := []error{}
+	for _, sub := range d.subscribers[record.Type] {
+		err := sub.handle(ctx, record)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+		}
+	}
+
+	now := d.clock.Now()
+	deliveryErr := errors.Join(errs...)
+	if deliveryErr == nil {
+		return d.store.MarkDelivered(ctx, record.ID, now)
+	}
+
+	if record.Attempts >= MaxAttempts {
+		log.Printf("Giving up on %s event %s after %d attempts: %v", record.Type, record.ID, record.Attempts, deliveryErr)
+		return d.store.Fail(ctx, record.ID, now, deliveryErr.Error())
+	}
+	return d.store.Retry(ctx, record.ID, now.Add(Backoff(record.Attempts)), deliveryErr.Error())
+}
+
+// Backoff is how long to wait after a failed attempt, doubling each time up
+// to an hour.
+func Backoff(attempts int) time.Duration {
+	return worker.Backoff(attempts, baseBackoff, maxBackoff)
+}
@@ -0,0 +1,271 @@
+package events
+
+import (
+	"chirpy/internal/clock"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fakeRecord struct {
+	Record
+	nextAttemptAt time.Time
+	deliveredAt   time.Time
+	failedAt      time.Time
+	lastError     string
+}
+
+type fakeStore struct {
+	records []*fakeRecord
+}
+
+func (s *fakeStore) add(t *testing.T, event Event, now time.Time) *fakeRecord {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	record := &fakeRecord{
+		Record: Record{
+			ID:        uuid.New(),
+			Type:      event.EventType(),
+			Payload:   payload,
+			CreatedAt: now,
+		},
+		nextAttemptAt: now,
+	}
+	s.records = append(s.records, record)
+	return record
+}
+
+func (s *fakeStore) find(id uuid.UUID) *fakeRecord {
+	for _, record := range s.records {
+		if record.ID == id {
+			return record
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Record, error) {
+	claimed := []Record{}
+	for _, record := range s.records {
+		if len(claimed) == limit {
+			break
+		}
+		if record.nextAttemptAt.IsZero() || record.nextAttemptAt.After(now) {
+			continue
+		}
+		record.Attempts++
+		record.nextAttemptAt = leaseUntil
+		claimed = append(claimed, record.Record)
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) MarkDelivered(ctx context.Context, id uuid.UUID, at time.Time) error {
+	record := s.find(id)
+	record.deliveredAt = at
+	record.nextAttemptAt = time.Time{}
+	return nil
+}
+
+func (s *fakeStore) Retry(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error {
+	record := s.find(id)
+	record.nextAttemptAt = at
+	record.lastError = lastError
+	return nil
+}
+
+func (s *fakeStore) Fail(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error {
+	record := s.find(id)
+	record.failedAt = at
+	record.nextAttemptAt = time.Time{}
+	record.lastError = lastError
+	return nil
+}
+
+func (s *fakeStore) PurgeDeliveredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	kept := []*fakeRecord{}
+	for _, record := range s.records {
+		if record.deliveredAt.IsZero() || !record.deliveredAt.Before(cutoff) {
+			kept = append(kept, record)
+		}
+	}
+	purged := int64(len(s.records) - len(kept))
+	s.records = kept
+	return purged, nil
+}
+
+func TestDispatch(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := clock.NewFake(now)
+	store := &fakeStore{}
+	dispatcher := NewDispatcher(store, time.Second).WithClock(clock)
+
+	chirpID := uuid.New()
+	received := []ChirpCreated{}
+	Subscribe(dispatcher, "recorder", func(ctx context.Context, event ChirpCreated) error {
+		received = append(received, event)
+		return nil
+	})
+
+	created := store.add(t, ChirpCreated{ChirpID: chirpID, AuthorID: uuid.New()}, now)
+	// Events nobody subscribes to are still marked delivered
+	upgraded := store.add(t, UserUpgraded{UserID: uuid.New()}, now)
+
+	dispatched, err := dispatcher.Dispatch(context.Background())
+	if err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	if dispatched != 2 {
+		t.Errorf("Dispatch() = %d, want 2", dispatched)
+	}
+	if len(received) != 1 || received[0].ChirpID != chirpID {
+		t.Errorf("received = %v, want one event for chirp %s", received, chirpID)
+	}
+	for _, record := range []*fakeRecord{created, upgraded} {
+		if !record.deliveredAt.Equal(now) {
+			t.Errorf("%s deliveredAt = %v, want %v", record.Type, record.deliveredAt, now)
+		}
+	}
+
+	// Nothing is due any more
+	dispatched, err = dispatcher.Dispatch(context.Background())
+	if err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	if dispatched != 0 {
+		t.Errorf("Dispatch() = %d, want 0", dispatched)
+	}
+}
+
+func TestDispatchRetries(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := clock.NewFake(now)
+	store := &fakeStore{}
+	dispatcher := NewDispatcher(store, time.Second).WithClock(clock)
+
+	errUnavailable := errors.New("unavailable")
+	failures := 2
+	calls := map[string]int{}
+	Subscribe(dispatcher, "flaky", func(ctx context.Context, event UserCreated) error {
+		calls["flaky"]++
+		if calls["flaky"] <= failures {
+			return errUnavailable
+		}
+		return nil
+	})
+	Subscribe(dispatcher, "steady", func(ctx context.Context, event UserCreated) error {
+		calls["steady"]++
+		return nil
+	})
+
+	record := store.add(t, UserCreated{UserID: uuid.New()}, now)
+
+	for attempt := 1; attempt <= failures; attempt++ {
+		_, err := dispatcher.Dispatch(context.Background())
+		if err != nil {
+			t.Fatalf("Dispatch() error = %v", err)
+		}
+		wantNext := clock.Now().Add(Backoff(attempt))
+		if !record.nextAttemptAt.Equal(wantNext) {
+			t.Fatalf("attempt %d: nextAttemptAt = %v, want %v", attempt, record.nextAttemptAt, wantNext)
+		}
+		if !strings.Contains(record.lastError, "flaky: unavailable") {
+			t.Errorf("attempt %d: lastError = %q, want it to name the subscriber", attempt, record.lastError)
+		}
+
+		// Not due until the backoff has passed
+		dispatched, _ := dispatcher.Dispatch(context.Background())
+		if dispatched != 0 {
+			t.Fatalf("attempt %d: dispatched %d events before the backoff passed", attempt, dispatched)
+		}
+		clock.Set(record.nextAttemptAt)
+	}
+
+	_, err := dispatcher.Dispatch(context.Background())
+	if err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	if record.deliveredAt.IsZero() {
+		t.Error("event wasn't delivered after the subscriber recovered")
+	}
+	// Every subscriber gets the event again when any of them fails
+	if calls["steady"] != failures+1 {
+		t.Errorf("steady subscriber called %d times, want %d", calls["steady"], failures+1)
+	}
+}
+
+func TestDispatchGivesUp(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := clock.NewFake(now)
+	store := &fakeStore{}
+	dispatcher := NewDispatcher(store, time.Second).WithClock(clock)
+	dispatcher.Subscribe(TypeUserCreated, "broken", func(ctx context.Context, record Record) error {
+		return errors.New("broken")
+	})
+
+	record := store.add(t, UserCreated{UserID: uuid.New()}, now)
+	for range MaxAttempts {
+		clock.Set(record.nextAttemptAt)
+		_, err := dispatcher.Dispatch(context.Background())
+		if err != nil {
+			t.Fatalf("Dispatch() error = %v", err)
+		}
+	}
+
+	if record.Attempts != MaxAttempts {
+		t.Errorf("Attempts = %d, want %d", record.Attempts, MaxAttempts)
+	}
+	if record.failedAt.IsZero() {
+		t.Error("event wasn't failed after the last attempt")
+	}
+	if !record.nextAttemptAt.IsZero() {
+		t.Errorf("nextAttemptAt = %v, want no further attempts", record.nextAttemptAt)
+	}
+}
+
+func TestDispatchUndecodableEvent(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := &fakeStore{}
+	dispatcher := NewDispatcher(store, time.Second).WithClock(clock.NewFake(now))
+	Subscribe(dispatcher, "recorder", func(ctx context.Context, event ChirpCreated) error {
+		return nil
+	})
+
+	record := store.add(t, ChirpCreated{}, now)
+	record.Payload = []byte("not json")
+
+	_, err := dispatcher.Dispatch(context.Background())
+	if err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	if !strings.Contains(record.lastError, "decoding chirp.created event") {
+		t.Errorf("lastError = %q, want a decoding error", record.lastError)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{10, 2560 * time.Second},
+		{20, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
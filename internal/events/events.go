@@ -0,0 +1,109 @@
+package events
+
+import (
+	"github.com/google/uuid"
+)
+
+// Event types
+const (
+	TypeChirpCreated   = "chirp.created"
+	TypeChirpDeleted   = "chirp.deleted"
+	TypeChirpHidden    = "chirp.hidden"
+	TypeChirpRechirped = "chirp.rechirped"
+	TypeChirpRestored  = "chirp.restored"
+	TypeChirpUpdated   = "chirp.updated"
+	TypeUserCreated    = "user.created"
+	TypeUserUpgraded   = "user.upgraded"
+	TypeUserFollowed   = "user.followed"
+)
+
+// Event is a change that subscribers react to. Events are stored as JSON,
+// so they should only carry IDs and let subscribers load what they need.
+type Event interface {
+	EventType() string
+}
+
+type ChirpCreated struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+func (ChirpCreated) EventType() string {
+	return TypeChirpCreated
+}
+
+type ChirpDeleted struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+func (ChirpDeleted) EventType() string {
+	return TypeChirpDeleted
+}
+
+// ChirpUpdated is sent when a chirp's body is edited.
+type ChirpUpdated struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+func (ChirpUpdated) EventType() string {
+	return TypeChirpUpdated
+}
+
+// ChirpHidden is sent when a moderator hides a chirp.
+type ChirpHidden struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+func (ChirpHidden) EventType() string {
+	return TypeChirpHidden
+}
+
+// ChirpRestored is sent when a deleted chirp is brought back.
+type ChirpRestored struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+func (ChirpRestored) EventType() string {
+	return TypeChirpRestored
+}
+
+// ChirpRechirped is sent when UserID rechirps AuthorID's chirp.
+type ChirpRechirped struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+	UserID   uuid.UUID `json:"user_id"`
+}
+
+func (ChirpRechirped) EventType() string {
+	return TypeChirpRechirped
+}
+
+type UserCreated struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (UserCreated) EventType() string {
+	return TypeUserCreated
+}
+
+// UserUpgraded is sent when a user becomes a Chirpy Red member.
+type UserUpgraded struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (UserUpgraded) EventType() string {
+	return TypeUserUpgraded
+}
+
+type UserFollowed struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+}
+
+func (UserFollowed) EventType() string {
+	return TypeUserFollowed
+}
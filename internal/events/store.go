@@ -0,0 +1,85 @@
+package events
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Write adds an event to the outbox. Callers should pass queries bound to
+// the transaction making the change, so the event is only delivered if the
+// change is committed.
+func Write(ctx context.Context, q *database.Queries, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return q.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		Type:    event.EventType(),
+		Payload: payload,
+	})
+}
+
+// DBStore is the Postgres-backed Store.
+type DBStore struct {
+	dbQueries *database.Queries
+}
+
+func NewDBStore(dbQueries *database.Queries) *DBStore {
+	return &DBStore{
+		dbQueries: dbQueries,
+	}
+}
+
+func (s *DBStore) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Record, error) {
+	rows, err := s.dbQueries.ClaimOutboxEvents(ctx, database.ClaimOutboxEventsParams{
+		LeaseUntil: leaseUntil,
+		Now:        now,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, Record{
+			ID:        row.ID,
+			Type:      row.Type,
+			Payload:   row.Payload,
+			CreatedAt: row.CreatedAt,
+			Attempts:  int(row.Attempts),
+		})
+	}
+	return records, nil
+}
+
+func (s *DBStore) MarkDelivered(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return s.dbQueries.MarkOutboxEventDelivered(ctx, database.MarkOutboxEventDeliveredParams{
+		DeliveredAt: at,
+		ID:          id,
+	})
+}
+
+func (s *DBStore) Retry(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error {
+	return s.dbQueries.RetryOutboxEvent(ctx, database.RetryOutboxEventParams{
+		NextAttemptAt: at,
+		LastError:     lastError,
+		ID:            id,
+	})
+}
+
+func (s *DBStore) Fail(ctx context.Context, id uuid.UUID, at time.Time, lastError string) error {
+	return s.dbQueries.FailOutboxEvent(ctx, database.FailOutboxEventParams{
+		FailedAt:  at,
+		LastError: lastError,
+		ID:        id,
+	})
+}
+
+func (s *DBStore) PurgeDeliveredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return s.dbQueries.DeleteDeliveredOutboxEvents(ctx, cutoff)
+}
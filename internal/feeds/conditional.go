@@ -0,0 +1,48 @@
+package feeds
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag returns a strong entity tag for a rendered feed.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModified reports whether a conditional GET can be answered with 304
+// Not Modified. As in RFC 9110, If-Modified-Since is ignored when the
+// request has If-None-Match.
+func NotModified(header http.Header, etag string, lastModified time.Time) bool {
+	if values := header.Values("If-None-Match"); len(values) > 0 {
+		return etagMatches(strings.Join(values, ","), etag)
+	}
+
+	since := header.Get("If-Modified-Since")
+	if since == "" || lastModified.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(since)
+	if err != nil {
+		return false
+	}
+	// HTTP dates only have whole seconds
+	return !lastModified.Truncate(time.Second).After(t)
+}
+
+// etagMatches uses the weak comparison If-None-Match calls for, so W/
+// prefixes added by proxies are ignored.
+func etagMatches(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed(content string) Feed {
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return Feed{
+		ID:          "https://chirpy.example/users/1/feed.atom",
+		Title:       "Chirps by @alice",
+		Description: "The latest chirps by @alice",
+		Link:        "https://chirpy.example/api/chirps?author_id=1",
+		Self:        "https://chirpy.example/users/1/feed.atom",
+		Updated:     published.Add(time.Hour),
+		Items: []Item{
+			{
+				ID:        "urn:uuid:00000000-0000-0000-0000-000000000001",
+				Title:     Title(content),
+				Link:      "https://chirpy.example/api/chirps/00000000-0000-0000-0000-000000000001",
+				Author:    "@alice",
+				Content:   content,
+				Published: published,
+				Updated:   published.Add(time.Hour),
+			},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      Format
+		content     string
+		wantContent string
+		wantRoot    string
+	}{
+		{
+			name:        "RSS",
+			format:      RSS,
+			content:     "Hello, world",
+			wantContent: "Hello, world",
+			wantRoot:    "rss",
+		},
+		{
+			name:        "Atom",
+			format:      Atom,
+			content:     "Hello, world",
+			wantContent: "Hello, world",
+			wantRoot:    "feed",
+		},
+		{
+			name:        "RSS with markup",
+			format:      RSS,
+			content:     `<script>alert("hi")</script> & ]]>`,
+			wantContent: `<script>alert("hi")</script> & ]]>`,
+			wantRoot:    "rss",
+		},
+		{
+			name:        "Atom with markup",
+			format:      Atom,
+			content:     `<b>bold</b> &amp; done`,
+			wantContent: `<b>bold</b> &amp; done`,
+			wantRoot:    "feed",
+		},
+		{
+			name:        "Invalid XML characters",
+			format:      Atom,
+			content:     "bell\x07 and nul\x00",
+			wantContent: "bell� and nul�",
+			wantRoot:    "feed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := Render(testFeed(tt.content), tt.format)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if strings.Contains(string(body), "<script>") || strings.Contains(string(body), "<b>") {
+				t.Errorf("Render() didn't escape markup:\n%s", body)
+			}
+
+			// The content survives a round trip through a real XML parser
+			var doc struct {
+				XMLName xml.Name
+				Items   []struct {
+					Description string `xml:"description"`
+				} `xml:"channel>item"`
+				Entries []struct {
+					Content string `xml:"content"`
+				} `xml:"entry"`
+			}
+			err = xml.Unmarshal(body, &doc)
+			if err != nil {
+				t.Fatalf("Render() produced invalid XML: %v\n%s", err, body)
+			}
+			if doc.XMLName.Local != tt.wantRoot {
+				t.Errorf("root = %s, want %s", doc.XMLName.Local, tt.wantRoot)
+			}
+
+			var got string
+			if len(doc.Items) == 1 {
+				got = doc.Items[0].Description
+			} else if len(doc.Entries) == 1 {
+				got = doc.Entries[0].Content
+			} else {
+				t.Fatalf("Render() produced %d items and %d entries, want 1", len(doc.Items), len(doc.Entries))
+			}
+			if got != tt.wantContent {
+				t.Errorf("content = %q, want %q", got, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestRenderEmpty(t *testing.T) {
+	feed := testFeed("")
+	feed.Items = nil
+	feed.Updated = time.Time{}
+
+	for _, format := range []Format{RSS, Atom} {
+		body, err := Render(feed, format)
+		if err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		if format == RSS && strings.Contains(string(body), "lastBuildDate") {
+			t.Errorf("Render() of an empty RSS feed has a lastBuildDate:\n%s", body)
+		}
+		if format == Atom && !strings.Contains(string(body), "<updated>") {
+			t.Errorf("Render() of an empty Atom feed has no updated date:\n%s", body)
+		}
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "Short",
+			text: "Hello, world",
+			want: "Hello, world",
+		},
+		{
+			name: "Multiple lines",
+			text: "First line\n\nsecond  line",
+			want: "First line second line",
+		},
+		{
+			name: "Long",
+			text: strings.Repeat("a", 100),
+			want: strings.Repeat("a", 79) + "…",
+		},
+		{
+			name: "Multibyte",
+			text: strings.Repeat("é", 100),
+			want: strings.Repeat("é", 79) + "…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Title(tt.text); got != tt.want {
+				t.Errorf("Title() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := ETag([]byte("feed"))
+	lastModified := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{
+			name:   "Unconditional",
+			header: http.Header{},
+			want:   false,
+		},
+		{
+			name:   "Matching ETag",
+			header: http.Header{"If-None-Match": {etag}},
+			want:   true,
+		},
+		{
+			name:   "Matching ETag in a list",
+			header: http.Header{"If-None-Match": {`"other", W/` + etag}},
+			want:   true,
+		},
+		{
+			name:   "Wildcard",
+			header: http.Header{"If-None-Match": {"*"}},
+			want:   true,
+		},
+		{
+			name:   "Stale ETag",
+			header: http.Header{"If-None-Match": {`"other"`}},
+			want:   false,
+		},
+		{
+			name:   "Not modified since",
+			header: http.Header{"If-Modified-Since": {lastModified.Format(http.TimeFormat)}},
+			want:   true,
+		},
+		{
+			name:   "Modified since",
+			header: http.Header{"If-Modified-Since": {lastModified.Add(-time.Minute).Format(http.TimeFormat)}},
+			want:   false,
+		},
+		{
+			name: "ETag takes precedence",
+			header: http.Header{
+				"If-None-Match":     {`"other"`},
+				"If-Modified-Since": {lastModified.Format(http.TimeFormat)},
+			},
+			want: false,
+		},
+		{
+			name:   "Invalid date",
+			header: http.Header{"If-Modified-Since": {"yesterday"}},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NotModified(tt.header, etag, lastModified); got != tt.want {
+				t.Errorf("NotModified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
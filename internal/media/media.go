@@ -0,0 +1,260 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	// Registered for image.Decode
+	_ "image/gif"
+)
+
+const (
+	TypeJPEG = "image/jpeg"
+	TypePNG  = "image/png"
+	TypeGIF  = "image/gif"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrTooLarge        = errors.New("image dimensions too large")
+)
+
+var signatures = []struct {
+	contentType string
+	magic       []byte
+}{
+	{TypeJPEG, []byte{0xFF, 0xD8, 0xFF}},
+	{TypePNG, []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}},
+	{TypeGIF, []byte("GIF87a")},
+	{TypeGIF, []byte("GIF89a")},
+}
+
+// DetectType identifies an image from its leading magic bytes rather than
+// trusting the file name or the client's Content-Type.
+func DetectType(data []byte) (string, error) {
+	for _, signature := range signatures {
+		if bytes.HasPrefix(data, signature.magic) {
+			return signature.contentType, nil
+		}
+	}
+	return "", ErrUnsupportedType
+}
+
+// StripMetadata removes EXIF, XMP, IPTC and text metadata from an image
+// without re-encoding its pixels. GIFs carry no such metadata and are
+// returned unchanged.
+func StripMetadata(contentType string, data []byte) ([]byte, error) {
+	switch contentType {
+	case TypeJPEG:
+		return stripJPEG(data)
+	case TypePNG:
+		return stripPNG(data)
+	case TypeGIF:
+		return data, nil
+	}
+	return nil, ErrUnsupportedType
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	const (
+		markerSOS   = 0xDA
+		markerAPP1  = 0xE1 // EXIF and XMP
+		markerAPP13 = 0xED // IPTC
+		markerCOM   = 0xFE
+	)
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	pos := 2
+
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return nil, errors.New("malformed JPEG")
+		}
+		// Markers may be preceded by any number of fill bytes
+		for pos+1 < len(data) && data[pos+1] == 0xFF {
+			pos++
+		}
+		if pos+1 >= len(data) {
+			return nil, errors.New("malformed JPEG")
+		}
+
+		marker := data[pos+1]
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[pos : pos+2])
+			pos += 2
+			continue
+		}
+		if marker == markerSOS {
+			// Entropy-coded data runs to the end of the image
+			out.Write(data[pos:])
+			break
+		}
+
+		if pos+4 > len(data) {
+			return nil, errors.New("malformed JPEG")
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:pos+4]))
+		if end > len(data) {
+			return nil, errors.New("malformed JPEG")
+		}
+		if marker != markerAPP1 && marker != markerAPP13 && marker != markerCOM {
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	return out.Bytes(), nil
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	strippedChunks := map[string]struct{}{
+		"eXIf": {},
+		"tEXt": {},
+		"zTXt": {},
+		"iTXt": {},
+		"tIME": {},
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:8])
+	pos := 8
+
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, errors.New("malformed PNG")
+		}
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		// length, type, data and CRC
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errors.New("malformed PNG")
+		}
+		if _, ok := strippedChunks[chunkType]; !ok {
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	return out.Bytes(), nil
+}
+
+// ThumbnailType is the content type thumbnails of contentType are encoded as.
+func ThumbnailType(contentType string) string {
+	if contentType == TypeJPEG {
+		return TypeJPEG
+	}
+	return TypePNG
+}
+
+// Thumbnail scales img down to fit within maxSize x maxSize, preserving its
+// aspect ratio, and encodes it as ThumbnailType(contentType). Images that
+// already fit are re-encoded at their original size.
+func Thumbnail(img image.Image, contentType string, maxSize int, w io.Writer) error {
+	scaled := scaleDown(img, maxSize)
+	if ThumbnailType(contentType) == TypeJPEG {
+		return jpeg.Encode(w, scaled, &jpeg.Options{Quality: 80})
+	}
+	return png.Encode(w, scaled)
+}
+
+// scaleDown resizes img with a box filter: each destination pixel is the
+// average of the source pixels it covers.
+func scaleDown(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= maxSize && srcH <= maxSize {
+		return img
+	}
+
+	dstW, dstH := maxSize, maxSize
+	if srcW > srcH {
+		dstH = max(1, srcH*maxSize/srcW)
+	} else {
+		dstW = max(1, srcW*maxSize/srcH)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := range dstH {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := range dstW {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// Upload is an image that has been validated and prepared for storage.
+type Upload struct {
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+	Thumbnail   []byte
+}
+
+// Process validates an uploaded image, strips its metadata and generates a
+// thumbnail. The image's dimensions are checked before it is decoded so a
+// small file can't expand into a huge bitmap.
+func Process(data []byte, maxPixels, thumbnailSize int) (Upload, error) {
+	contentType, err := DetectType(data)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Upload{}, err
+	}
+	if config.Width*config.Height > maxPixels {
+		return Upload{}, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Upload{}, err
+	}
+
+	stripped, err := StripMetadata(contentType, data)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	thumbnail := bytes.Buffer{}
+	err = Thumbnail(img, contentType, thumbnailSize, &thumbnail)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	return Upload{
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		Data:        stripped,
+		Thumbnail:   thumbnail.Bytes(),
+	}, nil
+}
@@ -0,0 +1,261 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withJPEGSegment inserts a marker segment straight after the SOI marker.
+func withJPEGSegment(data []byte, marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// withPNGChunk inserts a chunk straight after the IHDR chunk.
+func withPNGChunk(data []byte, chunkType string, payload []byte) []byte {
+	chunk := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	copy(chunk[4:], chunkType)
+	chunk = append(chunk, payload...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	ihdrEnd := 8 + 12 + int(binary.BigEndian.Uint32(data[8:12]))
+	out := append([]byte{}, data[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+func TestDetectType(t *testing.T) {
+	img := testImage(4, 4)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "JPEG",
+			data: encodeJPEG(t, img),
+			want: TypeJPEG,
+		},
+		{
+			name: "PNG",
+			data: encodePNG(t, img),
+			want: TypePNG,
+		},
+		{
+			name: "GIF",
+			data: []byte("GIF89a\x01\x00\x01\x00"),
+			want: TypeGIF,
+		},
+		{
+			name:    "HTML disguised as image",
+			data:    []byte("<html><script>alert(1)</script>"),
+			wantErr: true,
+		},
+		{
+			name:    "Empty",
+			data:    []byte{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectType(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DetectType() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("DetectType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripMetadata(t *testing.T) {
+	img := testImage(8, 8)
+	exif := []byte("Exif\x00\x00GPS 51.5N 0.1W")
+
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+	}{
+		{
+			name:        "JPEG EXIF",
+			contentType: TypeJPEG,
+			data:        withJPEGSegment(encodeJPEG(t, img), 0xE1, exif),
+		},
+		{
+			name:        "PNG eXIf",
+			contentType: TypePNG,
+			data:        withPNGChunk(encodePNG(t, img), "eXIf", exif),
+		},
+		{
+			name:        "PNG tEXt",
+			contentType: TypePNG,
+			data:        withPNGChunk(encodePNG(t, img), "tEXt", []byte("Comment\x00GPS 51.5N 0.1W")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StripMetadata(tt.contentType, tt.data)
+			if err != nil {
+				t.Fatalf("StripMetadata() error = %v", err)
+			}
+			if bytes.Contains(got, []byte("GPS")) {
+				t.Errorf("StripMetadata() left metadata in the image")
+			}
+			if _, _, err := image.Decode(bytes.NewReader(got)); err != nil {
+				t.Errorf("stripped image doesn't decode: %v", err)
+			}
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		name            string
+		width, height   int
+		contentType     string
+		wantW, wantH    int
+		wantContentType string
+	}{
+		{
+			name:            "Landscape",
+			width:           400,
+			height:          200,
+			contentType:     TypeJPEG,
+			wantW:           100,
+			wantH:           50,
+			wantContentType: TypeJPEG,
+		},
+		{
+			name:            "Portrait",
+			width:           150,
+			height:          300,
+			contentType:     TypePNG,
+			wantW:           50,
+			wantH:           100,
+			wantContentType: TypePNG,
+		},
+		{
+			name:            "Already small",
+			width:           40,
+			height:          30,
+			contentType:     TypeGIF,
+			wantW:           40,
+			wantH:           30,
+			wantContentType: TypePNG,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			err := Thumbnail(testImage(tt.width, tt.height), tt.contentType, 100, &buf)
+			if err != nil {
+				t.Fatalf("Thumbnail() error = %v", err)
+			}
+
+			gotType, _ := DetectType(buf.Bytes())
+			if gotType != tt.wantContentType {
+				t.Errorf("Thumbnail() encoded %v, want %v", gotType, tt.wantContentType)
+			}
+			config, _, err := image.DecodeConfig(&buf)
+			if err != nil {
+				t.Fatalf("thumbnail doesn't decode: %v", err)
+			}
+			if config.Width != tt.wantW || config.Height != tt.wantH {
+				t.Errorf("Thumbnail() = %dx%d, want %dx%d", config.Width, config.Height, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{
+			name: "Valid JPEG",
+			data: withJPEGSegment(encodeJPEG(t, testImage(300, 200)), 0xE1, []byte("Exif\x00\x00GPS")),
+		},
+		{
+			name:    "Too many pixels",
+			data:    encodePNG(t, testImage(300, 300)),
+			wantErr: ErrTooLarge,
+		},
+		{
+			name:    "Not an image",
+			data:    []byte("%PDF-1.7"),
+			wantErr: ErrUnsupportedType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Process(tt.data, 60_000, 100)
+			if err != tt.wantErr {
+				t.Fatalf("Process() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Width != 300 || got.Height != 200 {
+				t.Errorf("Process() dimensions = %dx%d, want 300x200", got.Width, got.Height)
+			}
+			if bytes.Contains(got.Data, []byte("GPS")) {
+				t.Errorf("Process() left metadata in the image")
+			}
+			if len(got.Thumbnail) == 0 {
+				t.Errorf("Process() generated no thumbnail")
+			}
+		})
+	}
+}
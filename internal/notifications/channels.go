@@ -0,0 +1,241 @@
+package notifications
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Channel names
+const (
+	ChannelInApp = "in_app"
+	ChannelEmail = "email"
+)
+
+// InAppStore saves notifications for GET /api/notifications.
+type InAppStore interface {
+	// AddNotification adds the event's actor to the recipient's unread
+	// notification for its group, creating one if there isn't one
+	AddNotification(ctx context.Context, event Event) error
+}
+
+// InApp stores notifications to be read in the app. It's on by default.
+type InApp struct {
+	store InAppStore
+}
+
+func NewInApp(store InAppStore) *InApp {
+	return &InApp{
+		store: store,
+	}
+}
+
+func (c *InApp) Name() string {
+	return ChannelInApp
+}
+
+func (c *InApp) EnabledByDefault() bool {
+	return true
+}
+
+func (c *InApp) Deliver(ctx context.Context, event Event) error {
+	return c.store.AddNotification(ctx, event)
+}
+
+// Message is an email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var ErrInvalidMessage = errors.New("invalid message")
+
+// SMTPTimeout bounds each email, from connecting to the server to the end of
+// the conversation.
+const SMTPTimeout = 30 * time.Second
+
+// SMTPMailer sends email through an SMTP server, upgrading to TLS when the
+// server offers it.
+type SMTPMailer struct {
+	addr string
+	host string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer sends from the from address through the server at addr,
+// e.g. smtp.example.com:587. Without a username it doesn't authenticate.
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	host, _, _ := net.SplitHostPort(addr)
+	mailer := &SMTPMailer{
+		addr: addr,
+		host: host,
+		from: from,
+	}
+	if len(username) > 0 {
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return mailer
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	body, err := formatMessage(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, SMTPTimeout)
+	defer cancel()
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	// Cancelling ctx closes the connection, ending any read or write
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: m.host})
+		if err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		err = client.Auth(m.auth)
+		if err != nil {
+			return err
+		}
+	}
+	err = client.Mail(m.from)
+	if err != nil {
+		return err
+	}
+	err = client.Rcpt(msg.To)
+	if err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// formatMessage renders a plain text email. Header values can't contain
+// line breaks, which would let them add headers of their own.
+func formatMessage(from string, msg Message) ([]byte, error) {
+	for _, value := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("%w: line break in header", ErrInvalidMessage)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
+
+// EmailStore looks up the users named in an event.
+type EmailStore interface {
+	Email(ctx context.Context, userID uuid.UUID) (string, error)
+	// Handle returns "" for users without a handle
+	Handle(ctx context.Context, userID uuid.UUID) (string, error)
+}
+
+// Email sends an email for each event. Events aren't grouped, so it's off by
+// default.
+type Email struct {
+	mailer Mailer
+	store  EmailStore
+}
+
+func NewEmail(mailer Mailer, store EmailStore) *Email {
+	return &Email{
+		mailer: mailer,
+		store:  store,
+	}
+}
+
+func (c *Email) Name() string {
+	return ChannelEmail
+}
+
+func (c *Email) EnabledByDefault() bool {
+	return false
+}
+
+func (c *Email) Deliver(ctx context.Context, event Event) error {
+	to, err := c.store.Email(ctx, event.RecipientID)
+	if err != nil {
+		return err
+	}
+	handle, err := c.store.Handle(ctx, event.ActorID)
+	if err != nil {
+		return err
+	}
+
+	summary := Summary(event.Type, handle)
+	return c.mailer.Send(ctx, Message{
+		To:      to,
+		Subject: summary,
+		Body:    summary + "\n\nYou can turn off these emails in your Chirpy notification preferences.\n",
+	})
+}
+
+// Summary describes an event by a single actor, such as "@alice followed
+// you".
+func Summary(eventType, actorHandle string) string {
+	actor := "Someone"
+	if len(actorHandle) > 0 {
+		actor = "@" + actorHandle
+	}
+
+	switch eventType {
+	case TypeFollow:
+		return actor + " followed you"
+	case TypeMention:
+		return actor + " mentioned you"
+	case TypeQuote:
+		return actor + " quoted your chirp"
+	case TypeRechirp:
+		return actor + " rechirped your chirp"
+	}
+	return fmt.Sprintf("%s sent you a %s notification", actor, eventType)
+}
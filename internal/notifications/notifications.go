@@ -0,0 +1,158 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Event types
+const (
+	TypeFollow  = "follow"
+	TypeMention = "mention"
+	TypeQuote   = "quote"
+	TypeRechirp = "rechirp"
+)
+
+// Types lists every event type, in the order preferences are shown.
+var Types = []string{TypeFollow, TypeMention, TypeQuote, TypeRechirp}
+
+func IsValidType(eventType string) bool {
+	return slices.Contains(Types, eventType)
+}
+
+// Event is something a user did that involves another user.
+type Event struct {
+	Type        string
+	RecipientID uuid.UUID
+	ActorID     uuid.UUID
+	// ChirpID is the chirp the event is about, or uuid.Nil for follows. For
+	// mentions it's the mentioning chirp, otherwise the recipient's chirp.
+	ChirpID uuid.UUID
+}
+
+// GroupKey identifies the events that are shown together, such as everyone
+// who rechirped the same chirp.
+func (e Event) GroupKey() string {
+	if e.ChirpID == uuid.Nil {
+		return e.Type
+	}
+	return e.Type + ":" + e.ChirpID.String()
+}
+
+// Channel delivers notifications to users.
+type Channel interface {
+	// Name identifies the channel in preferences
+	Name() string
+	// EnabledByDefault is used for users who haven't chosen
+	EnabledByDefault() bool
+	Deliver(ctx context.Context, event Event) error
+}
+
+// Preference is a user's choice of whether to receive a type of event on
+// a channel.
+type Preference struct {
+	Type    string
+	Channel string
+	Enabled bool
+}
+
+// Store holds what the notifier needs to know about users.
+type Store interface {
+	// Ignores reports whether the user has blocked or muted the actor
+	Ignores(ctx context.Context, userID, actorID uuid.UUID) (bool, error)
+	// HasNotified reports whether the recipient was already told about the
+	// actor in the event's group
+	HasNotified(ctx context.Context, event Event) (bool, error)
+	// Preferences returns the choices the user has saved
+	Preferences(ctx context.Context, userID uuid.UUID) ([]Preference, error)
+}
+
+// Notifier sends events to the channels each recipient has enabled.
+type Notifier struct {
+	store    Store
+	channels []Channel
+}
+
+func NewNotifier(store Store, channels ...Channel) *Notifier {
+	return &Notifier{
+		store:    store,
+		channels: channels,
+	}
+}
+
+// Notify delivers an event. Users aren't told about their own actions, about
+// users they've blocked or muted, or about the same actor twice in a group,
+// so following, unfollowing and following again doesn't repeat the
+// notification.
+func (n *Notifier) Notify(ctx context.Context, event Event) error {
+	if event.RecipientID == event.ActorID {
+		return nil
+	}
+
+	ignored, err := n.store.Ignores(ctx, event.RecipientID, event.ActorID)
+	if err != nil {
+		return err
+	} else if ignored {
+		return nil
+	}
+
+	notified, err := n.store.HasNotified(ctx, event)
+	if err != nil {
+		return err
+	} else if notified {
+		return nil
+	}
+
+	preferences, err := n.store.Preferences(ctx, event.RecipientID)
+	if err != nil {
+		return err
+	}
+
+	// One failed channel doesn't stop delivery on the others
+	errs := []error{}
+	for _, channel := range n.channels {
+		if !Enabled(preferences, event.Type, channel) {
+			continue
+		}
+		err = channel.Deliver(ctx, event)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HasChannel reports whether the notifier delivers on the named channel.
+func (n *Notifier) HasChannel(name string) bool {
+	return slices.ContainsFunc(n.channels, func(channel Channel) bool {
+		return channel.Name() == name
+	})
+}
+
+// Settings returns whether each type is enabled on each channel, given the
+// preferences a user has saved.
+func (n *Notifier) Settings(preferences []Preference) map[string]map[string]bool {
+	settings := make(map[string]map[string]bool, len(Types))
+	for _, eventType := range Types {
+		settings[eventType] = make(map[string]bool, len(n.channels))
+		for _, channel := range n.channels {
+			settings[eventType][channel.Name()] = Enabled(preferences, eventType, channel)
+		}
+	}
+	return settings
+}
+
+// Enabled reports whether a type of event should be delivered on a channel,
+// falling back to the channel's default.
+func Enabled(preferences []Preference, eventType string, channel Channel) bool {
+	for _, preference := range preferences {
+		if preference.Type == eventType && preference.Channel == channel.Name() {
+			return preference.Enabled
+		}
+	}
+	return channel.EnabledByDefault()
+}
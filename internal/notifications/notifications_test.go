@@ -0,0 +1,355 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fakeStore struct {
+	ignored     bool
+	notified    bool
+	preferences []Preference
+	err         error
+}
+
+func (s *fakeStore) Ignores(ctx context.Context, userID, actorID uuid.UUID) (bool, error) {
+	return s.ignored, s.err
+}
+
+func (s *fakeStore) HasNotified(ctx context.Context, event Event) (bool, error) {
+	return s.notified, s.err
+}
+
+func (s *fakeStore) Preferences(ctx context.Context, userID uuid.UUID) ([]Preference, error) {
+	return s.preferences, s.err
+}
+
+type fakeChannel struct {
+	name      string
+	byDefault bool
+	err       error
+	delivered []Event
+}
+
+func (c *fakeChannel) Name() string {
+	return c.name
+}
+
+func (c *fakeChannel) EnabledByDefault() bool {
+	return c.byDefault
+}
+
+func (c *fakeChannel) Deliver(ctx context.Context, event Event) error {
+	c.delivered = append(c.delivered, event)
+	return c.err
+}
+
+type fakeMailer struct {
+	sent []Message
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+type fakeEmailStore struct {
+	emails  map[uuid.UUID]string
+	handles map[uuid.UUID]string
+}
+
+func (s *fakeEmailStore) Email(ctx context.Context, userID uuid.UUID) (string, error) {
+	return s.emails[userID], nil
+}
+
+func (s *fakeEmailStore) Handle(ctx context.Context, userID uuid.UUID) (string, error) {
+	return s.handles[userID], nil
+}
+
+func TestNotify(t *testing.T) {
+	recipientID := uuid.New()
+	actorID := uuid.New()
+	follow := Event{Type: TypeFollow, RecipientID: recipientID, ActorID: actorID}
+	errStore := errors.New("store failed")
+	errDeliver := errors.New("delivery failed")
+
+	tests := []struct {
+		name       string
+		event      Event
+		store      *fakeStore
+		deliverErr error
+		wantInApp  int
+		wantEmail  int
+		wantErr    error
+	}{
+		{
+			name:      "Channel defaults",
+			event:     follow,
+			store:     &fakeStore{},
+			wantInApp: 1,
+		},
+		{
+			name:  "Own action",
+			event: Event{Type: TypeRechirp, RecipientID: actorID, ActorID: actorID, ChirpID: uuid.New()},
+			store: &fakeStore{},
+		},
+		{
+			name:  "Ignored actor",
+			event: follow,
+			store: &fakeStore{ignored: true},
+		},
+		{
+			name:  "Already notified",
+			event: follow,
+			store: &fakeStore{notified: true},
+		},
+		{
+			name:  "Preferences override defaults",
+			event: follow,
+			store: &fakeStore{preferences: []Preference{
+				{Type: TypeFollow, Channel: ChannelInApp, Enabled: false},
+				{Type: TypeFollow, Channel: ChannelEmail, Enabled: true},
+				{Type: TypeMention, Channel: ChannelInApp, Enabled: true},
+			}},
+			wantEmail: 1,
+		},
+		{
+			name:  "Preferences for other types",
+			event: follow,
+			store: &fakeStore{preferences: []Preference{
+				{Type: TypeMention, Channel: ChannelInApp, Enabled: false},
+			}},
+			wantInApp: 1,
+		},
+		{
+			name:    "Store error",
+			event:   follow,
+			store:   &fakeStore{err: errStore},
+			wantErr: errStore,
+		},
+		{
+			name:  "Delivery error",
+			event: follow,
+			store: &fakeStore{preferences: []Preference{
+				{Type: TypeFollow, Channel: ChannelEmail, Enabled: true},
+			}},
+			deliverErr: errDeliver,
+			wantInApp:  1,
+			wantEmail:  1,
+			wantErr:    errDeliver,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inApp := &fakeChannel{name: ChannelInApp, byDefault: true, err: tt.deliverErr}
+			email := &fakeChannel{name: ChannelEmail}
+			notifier := NewNotifier(tt.store, inApp, email)
+
+			err := notifier.Notify(context.Background(), tt.event)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Notify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(inApp.delivered) != tt.wantInApp {
+				t.Errorf("in-app deliveries = %d, want %d", len(inApp.delivered), tt.wantInApp)
+			}
+			if len(email.delivered) != tt.wantEmail {
+				t.Errorf("email deliveries = %d, want %d", len(email.delivered), tt.wantEmail)
+			}
+		})
+	}
+}
+
+func TestSettings(t *testing.T) {
+	notifier := NewNotifier(&fakeStore{},
+		&fakeChannel{name: ChannelInApp, byDefault: true},
+		&fakeChannel{name: ChannelEmail},
+	)
+
+	settings := notifier.Settings([]Preference{
+		{Type: TypeQuote, Channel: ChannelEmail, Enabled: true},
+		{Type: TypeRechirp, Channel: ChannelInApp, Enabled: false},
+	})
+
+	tests := []struct {
+		eventType string
+		channel   string
+		want      bool
+	}{
+		{TypeFollow, ChannelInApp, true},
+		{TypeFollow, ChannelEmail, false},
+		{TypeQuote, ChannelEmail, true},
+		{TypeRechirp, ChannelInApp, false},
+	}
+
+	if len(settings) != len(Types) {
+		t.Errorf("len(Settings()) = %d, want %d", len(settings), len(Types))
+	}
+	for _, tt := range tests {
+		if got := settings[tt.eventType][tt.channel]; got != tt.want {
+			t.Errorf("Settings()[%s][%s] = %v, want %v", tt.eventType, tt.channel, got, tt.want)
+		}
+	}
+}
+
+func TestGroupKey(t *testing.T) {
+	chirpID := uuid.New()
+
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{
+			name:  "Follows are grouped together",
+			event: Event{Type: TypeFollow, ActorID: uuid.New()},
+			want:  TypeFollow,
+		},
+		{
+			name:  "Rechirps are grouped by chirp",
+			event: Event{Type: TypeRechirp, ActorID: uuid.New(), ChirpID: chirpID},
+			want:  TypeRechirp + ":" + chirpID.String(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.event.GroupKey(); got != tt.want {
+				t.Errorf("GroupKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailDeliver(t *testing.T) {
+	recipientID := uuid.New()
+	actorID := uuid.New()
+	anonymousID := uuid.New()
+	store := &fakeEmailStore{
+		emails:  map[uuid.UUID]string{recipientID: "walt@example.com"},
+		handles: map[uuid.UUID]string{actorID: "jesse"},
+	}
+
+	tests := []struct {
+		name        string
+		event       Event
+		wantSubject string
+	}{
+		{
+			name:        "Actor with a handle",
+			event:       Event{Type: TypeFollow, RecipientID: recipientID, ActorID: actorID},
+			wantSubject: "@jesse followed you",
+		},
+		{
+			name:        "Actor without a handle",
+			event:       Event{Type: TypeQuote, RecipientID: recipientID, ActorID: anonymousID, ChirpID: uuid.New()},
+			wantSubject: "Someone quoted your chirp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mailer := &fakeMailer{}
+			err := NewEmail(mailer, store).Deliver(context.Background(), tt.event)
+			if err != nil {
+				t.Fatalf("Deliver() error = %v", err)
+			}
+			if len(mailer.sent) != 1 {
+				t.Fatalf("sent %d emails, want 1", len(mailer.sent))
+			}
+
+			msg := mailer.sent[0]
+			if msg.To != "walt@example.com" {
+				t.Errorf("To = %q, want %q", msg.To, "walt@example.com")
+			}
+			if msg.Subject != tt.wantSubject {
+				t.Errorf("Subject = %q, want %q", msg.Subject, tt.wantSubject)
+			}
+			if !strings.HasPrefix(msg.Body, tt.wantSubject) {
+				t.Errorf("Body = %q, want it to start with %q", msg.Body, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestFormatMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     Message
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Plain text",
+			msg:  Message{To: "walt@example.com", Subject: "@jesse followed you", Body: "@jesse followed you\n"},
+			want: "From: chirpy@example.com\r\nTo: walt@example.com\r\nSubject: @jesse followed you\r\n" +
+				"MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n@jesse followed you\r\n",
+		},
+		{
+			name:    "Header injection",
+			msg:     Message{To: "walt@example.com\r\nBcc: gus@example.com", Subject: "Hi"},
+			wantErr: ErrInvalidMessage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatMessage("chirpy@example.com", tt.msg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("formatMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("formatMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSMTPMailerTimesOut(t *testing.T) {
+	// A server that accepts connections but never greets the client
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = NewSMTPMailer(listener.Addr().String(), "chirpy@example.com", "", "").Send(ctx, Message{
+		To:      "walt@example.com",
+		Subject: "@jesse followed you",
+	})
+	if err == nil {
+		t.Fatal("Send() error = nil, want an error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Send() took %v, want it to stop when ctx is done", elapsed)
+	}
+}
+
+func TestIsValidType(t *testing.T) {
+	for _, eventType := range Types {
+		if !IsValidType(eventType) {
+			t.Errorf("IsValidType(%q) = false, want true", eventType)
+		}
+	}
+	if IsValidType("like") {
+		t.Error("IsValidType(\"like\") = true, want false")
+	}
+}
@@ -0,0 +1,99 @@
+package notifications
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+// DBStore is the Postgres-backed Store, InAppStore and EmailStore.
+type DBStore struct {
+	db        *sql.DB
+	dbQueries *database.Queries
+}
+
+func NewDBStore(db *sql.DB, dbQueries *database.Queries) *DBStore {
+	return &DBStore{
+		db:        db,
+		dbQueries: dbQueries,
+	}
+}
+
+func (s *DBStore) Ignores(ctx context.Context, userID, actorID uuid.UUID) (bool, error) {
+	return s.dbQueries.IsIgnoring(ctx, database.IsIgnoringParams{
+		UserID:  userID,
+		ActorID: actorID,
+	})
+}
+
+func (s *DBStore) HasNotified(ctx context.Context, event Event) (bool, error) {
+	return s.dbQueries.HasNotified(ctx, database.HasNotifiedParams{
+		UserID:   event.RecipientID,
+		GroupKey: event.GroupKey(),
+		ActorID:  event.ActorID,
+	})
+}
+
+func (s *DBStore) Preferences(ctx context.Context, userID uuid.UUID) ([]Preference, error) {
+	rows, err := s.dbQueries.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := make([]Preference, 0, len(rows))
+	for _, row := range rows {
+		preferences = append(preferences, Preference{
+			Type:    row.Type,
+			Channel: row.Channel,
+			Enabled: row.Enabled,
+		})
+	}
+	return preferences, nil
+}
+
+func (s *DBStore) AddNotification(ctx context.Context, event Event) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.dbQueries.WithTx(tx)
+
+	notification, err := qtx.UpsertUnreadNotification(ctx, database.UpsertUnreadNotificationParams{
+		UserID:   event.RecipientID,
+		Type:     event.Type,
+		ChirpID:  uuid.NullUUID{UUID: event.ChirpID, Valid: event.ChirpID != uuid.Nil},
+		GroupKey: event.GroupKey(),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = qtx.CreateNotificationActor(ctx, database.CreateNotificationActorParams{
+		NotificationID: notification.ID,
+		ActorID:        event.ActorID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *DBStore) Email(ctx context.Context, userID uuid.UUID) (string, error) {
+	dbUser, err := s.dbQueries.GetUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return dbUser.Email, nil
+}
+
+func (s *DBStore) Handle(ctx context.Context, userID uuid.UUID) (string, error) {
+	dbUser, err := s.dbQueries.GetUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return dbUser.Handle.String, nil
+}
@@ -0,0 +1,102 @@
+package polls
+
+import (
+	"chirpy/internal/clock"
+	"chirpy/internal/worker"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MinOptions      = 2
+	MaxOptions      = 4
+	MaxOptionLength = 25
+
+	MinDuration = 5 * time.Minute
+	MaxDuration = 7 * 24 * time.Hour
+)
+
+var (
+	ErrOptionCount  = errors.New("polls need between 2 and 4 options")
+	ErrOptionLength = errors.New("poll options must be between 1 and 25 characters")
+	ErrDuplicate    = errors.New("poll options must be unique")
+	ErrDuration     = errors.New("polls must close between 5 minutes and 7 days from now")
+)
+
+// Validate checks a new poll's options and closing time, returning the
+// options with surrounding whitespace removed.
+func Validate(options []string, closesAt, now time.Time) ([]string, error) {
+	if len(options) < MinOptions || len(options) > MaxOptions {
+		return nil, ErrOptionCount
+	}
+
+	cleaned := make([]string, 0, len(options))
+	seen := map[string]bool{}
+	for _, option := range options {
+		option = strings.TrimSpace(option)
+		length := utf8.RuneCountInString(option)
+		if length == 0 || length > MaxOptionLength {
+			return nil, ErrOptionLength
+		}
+		key := strings.ToLower(option)
+		if seen[key] {
+			return nil, ErrDuplicate
+		}
+		seen[key] = true
+		cleaned = append(cleaned, option)
+	}
+
+	duration := closesAt.Sub(now)
+	if duration < MinDuration || duration > MaxDuration {
+		return nil, ErrDuration
+	}
+
+	return cleaned, nil
+}
+
+// Store closes polls.
+type Store interface {
+	// CloseDue closes open polls whose closing time is at or before now and
+	// returns how many it closed
+	CloseDue(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Closer periodically closes polls that have reached their closing time.
+type Closer struct {
+	store    Store
+	clock    clock.Clock
+	interval time.Duration
+}
+
+func NewCloser(store Store, interval time.Duration) *Closer {
+	return &Closer{
+		store:    store,
+		clock:    clock.Real{},
+		interval: interval,
+	}
+}
+
+// WithClock replaces the closer's clock, for tests.
+func (c *Closer) WithClock(clock clock.Clock) *Closer {
+	c.clock = clock
+	return c
+}
+
+// Run closes due polls immediately and then every interval until ctx is
+// cancelled.
+func (c *Closer) Run(ctx context.Context) {
+	worker.Every(ctx, c.interval, func(ctx context.Context) {
+		if _, err := c.CloseDue(ctx); err != nil {
+			log.Printf("Error closing polls: %v", err)
+		}
+	})
+}
+
+// CloseDue closes every poll that's due.
+func (c *Closer) CloseDue(ctx context.Context) (int64, error) {
+	return c.store.CloseDue(ctx, c.clock.Now())
+}
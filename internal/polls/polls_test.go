@@ -0,0 +1,164 @@
+package polls
+
+import (
+	"chirpy/internal/clock"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	closesAt []time.Time
+	err      error
+}
+
+func (s *fakeStore) CloseDue(ctx context.Context, now time.Time) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	open := []time.Time{}
+	for _, closesAt := range s.closesAt {
+		if closesAt.After(now) {
+			open = append(open, closesAt)
+		}
+	}
+	closed := int64(len(s.closesAt) - len(open))
+	s.closesAt = open
+	return closed, nil
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		options  []string
+		closesAt time.Time
+		want     []string
+		wantErr  error
+	}{
+		{
+			name:     "Valid poll",
+			options:  []string{" Yes ", "No"},
+			closesAt: now.Add(time.Hour),
+			want:     []string{"Yes", "No"},
+		},
+		{
+			name:     "Four options",
+			options:  []string{"a", "b", "c", "d"},
+			closesAt: now.Add(MaxDuration),
+			want:     []string{"a", "b", "c", "d"},
+		},
+		{
+			name:     "Too few options",
+			options:  []string{"Yes"},
+			closesAt: now.Add(time.Hour),
+			wantErr:  ErrOptionCount,
+		},
+		{
+			name:     "Too many options",
+			options:  []string{"a", "b", "c", "d", "e"},
+			closesAt: now.Add(time.Hour),
+			wantErr:  ErrOptionCount,
+		},
+		{
+			name:     "Blank option",
+			options:  []string{"Yes", "  "},
+			closesAt: now.Add(time.Hour),
+			wantErr:  ErrOptionLength,
+		},
+		{
+			name:     "Long option",
+			options:  []string{"Yes", strings.Repeat("n", MaxOptionLength+1)},
+			closesAt: now.Add(time.Hour),
+			wantErr:  ErrOptionLength,
+		},
+		{
+			name:     "Duplicate options",
+			options:  []string{"Yes", "yes "},
+			closesAt: now.Add(time.Hour),
+			wantErr:  ErrDuplicate,
+		},
+		{
+			name:     "Closes too soon",
+			options:  []string{"Yes", "No"},
+			closesAt: now.Add(time.Minute),
+			wantErr:  ErrDuration,
+		},
+		{
+			name:     "Closes too late",
+			options:  []string{"Yes", "No"},
+			closesAt: now.Add(MaxDuration + time.Second),
+			wantErr:  ErrDuration,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Validate(tt.options, tt.closesAt, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseDue(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		closesAt   []time.Time
+		err        error
+		wantClosed int64
+		wantOpen   int
+		wantErr    bool
+	}{
+		{
+			name:     "No polls",
+			closesAt: []time.Time{},
+		},
+		{
+			name: "Only due polls are closed",
+			closesAt: []time.Time{
+				now.Add(-time.Hour),
+				now,
+				now.Add(time.Second),
+			},
+			wantClosed: 2,
+			wantOpen:   1,
+		},
+		{
+			name:    "Store error",
+			err:     errors.New("connection refused"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{closesAt: tt.closesAt, err: tt.err}
+			closer := NewCloser(store, time.Minute).WithClock(clock.NewFake(now))
+
+			closed, err := closer.CloseDue(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CloseDue() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if closed != tt.wantClosed {
+				t.Errorf("CloseDue() = %d, want %d", closed, tt.wantClosed)
+			}
+			if len(store.closesAt) != tt.wantOpen {
+				t.Errorf("CloseDue() left %d polls open, want %d", len(store.closesAt), tt.wantOpen)
+			}
+		})
+	}
+}
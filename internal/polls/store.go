@@ -0,0 +1,22 @@
+package polls
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"time"
+)
+
+// DBStore is the Postgres-backed Store.
+type DBStore struct {
+	dbQueries *database.Queries
+}
+
+func NewDBStore(dbQueries *database.Queries) *DBStore {
+	return &DBStore{
+		dbQueries: dbQueries,
+	}
+}
+
+func (s *DBStore) CloseDue(ctx context.Context, now time.Time) (int64, error) {
+	return s.dbQueries.ClosePollsDue(ctx, now)
+}
@@ -0,0 +1,58 @@
+package retention
+
+import (
+	"chirpy/internal/clock"
+	"chirpy/internal/worker"
+	"context"
+	"log"
+	"time"
+)
+
+// Store permanently removes soft-deleted records.
+type Store interface {
+	// PurgeDeletedBefore removes records deleted before cutoff and returns
+	// how many were removed
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// Purger periodically removes chirps that were deleted longer ago than the
+// retention period. Until then they can be restored by their author or
+// reviewed by moderators.
+type Purger struct {
+	store     Store
+	clock     clock.Clock
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurger(store Store, retention, interval time.Duration) *Purger {
+	return &Purger{
+		store:     store,
+		clock:     clock.Real{},
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// WithClock replaces the purger's clock, for tests.
+func (p *Purger) WithClock(clock clock.Clock) *Purger {
+	p.clock = clock
+	return p
+}
+
+// Run purges immediately and then every interval until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	worker.Every(ctx, p.interval, func(ctx context.Context) {
+		purged, err := p.Purge(ctx)
+		if err != nil {
+			log.Printf("Error purging deleted chirps: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted chirps", purged)
+		}
+	})
+}
+
+// Purge removes everything deleted before the retention period began.
+func (p *Purger) Purge(ctx context.Context) (int64, error) {
+	return p.store.PurgeDeletedBefore(ctx, p.clock.Now().Add(-p.retention))
+}
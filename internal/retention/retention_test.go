@@ -0,0 +1,84 @@
+package retention
+
+import (
+	"chirpy/internal/clock"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	deletedAt []time.Time
+	err       error
+}
+
+func (s *fakeStore) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	kept := []time.Time{}
+	for _, deletedAt := range s.deletedAt {
+		if !deletedAt.Before(cutoff) {
+			kept = append(kept, deletedAt)
+		}
+	}
+	purged := int64(len(s.deletedAt) - len(kept))
+	s.deletedAt = kept
+	return purged, nil
+}
+
+func TestPurge(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name       string
+		deletedAt  []time.Time
+		err        error
+		wantPurged int64
+		wantKept   int
+		wantErr    bool
+	}{
+		{
+			name:      "Nothing deleted",
+			deletedAt: []time.Time{},
+		},
+		{
+			name: "Only expired chirps are purged",
+			deletedAt: []time.Time{
+				now.Add(-31 * day),
+				now.Add(-30*day - time.Second),
+				now.Add(-29 * day),
+				now.Add(-time.Hour),
+			},
+			wantPurged: 2,
+			wantKept:   2,
+		},
+		{
+			name:    "Store error",
+			err:     errors.New("connection refused"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{deletedAt: tt.deletedAt, err: tt.err}
+			purger := NewPurger(store, 30*day, time.Hour).WithClock(clock.NewFake(now))
+
+			purged, err := purger.Purge(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Purge() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if purged != tt.wantPurged {
+				t.Errorf("Purge() = %d, want %d", purged, tt.wantPurged)
+			}
+			if len(store.deletedAt) != tt.wantKept {
+				t.Errorf("Purge() kept %d chirps, want %d", len(store.deletedAt), tt.wantKept)
+			}
+		})
+	}
+}
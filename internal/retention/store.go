@@ -0,0 +1,22 @@
+package retention
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"time"
+)
+
+// DBStore is the Postgres-backed Store.
+type DBStore struct {
+	dbQueries *database.Queries
+}
+
+func NewDBStore(dbQueries *database.Queries) *DBStore {
+	return &DBStore{
+		dbQueries: dbQueries,
+	}
+}
+
+func (s *DBStore) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return s.dbQueries.PurgeDeletedChirps(ctx, cutoff)
+}
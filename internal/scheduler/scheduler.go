@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"chirpy/internal/clock"
+	"chirpy/internal/worker"
+	"context"
+	"log"
+	"time"
+)
+
+// Publisher publishes scheduled items one at a time. Implementations must
+// be safe to run from several processes at once.
+type Publisher interface {
+	// PublishNext publishes one item due at or before now, and reports
+	// false when nothing is due. Items that fail to publish should be put
+	// aside rather than returned again, so they don't hold up the rest.
+	PublishNext(ctx context.Context, now time.Time) (bool, error)
+}
+
+// Scheduler periodically publishes everything that has fallen due.
+type Scheduler struct {
+	publisher Publisher
+	clock     clock.Clock
+	interval  time.Duration
+}
+
+func New(publisher Publisher, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		publisher: publisher,
+		clock:     clock.Real{},
+		interval:  interval,
+	}
+}
+
+// WithClock replaces the scheduler's clock, for tests.
+func (s *Scheduler) WithClock(clock clock.Clock) *Scheduler {
+	s.clock = clock
+	return s
+}
+
+// Run publishes due items immediately and then every interval until ctx is
+// cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	worker.Every(ctx, s.interval, func(ctx context.Context) {
+		if _, err := s.PublishDue(ctx); err != nil {
+			log.Printf("Error publishing scheduled chirps: %v", err)
+		}
+	})
+}
+
+// PublishDue publishes items until none are due and returns how many it
+// published. Items scheduled while it runs wait for the next run.
+func (s *Scheduler) PublishDue(ctx context.Context) (int, error) {
+	now := s.clock.Now()
+
+	published := 0
+	for ctx.Err() == nil {
+		ok, err := s.publisher.PublishNext(ctx, now)
+		if err != nil {
+			return published, err
+		}
+		if !ok {
+			break
+		}
+		published++
+	}
+	return published, nil
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"chirpy/internal/clock"
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+type fakePublisher struct {
+	due       []time.Time
+	published []time.Time
+	failAfter int
+}
+
+func (p *fakePublisher) PublishNext(ctx context.Context, now time.Time) (bool, error) {
+	if p.failAfter > 0 && len(p.published) == p.failAfter {
+		return false, errors.New("connection refused")
+	}
+
+	sort.Slice(p.due, func(i, j int) bool { return p.due[i].Before(p.due[j]) })
+	if len(p.due) == 0 || p.due[0].After(now) {
+		return false, nil
+	}
+	p.published = append(p.published, p.due[0])
+	p.due = p.due[1:]
+	return true, nil
+}
+
+func TestPublishDue(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		due           []time.Time
+		failAfter     int
+		wantPublished int
+		wantRemaining int
+		wantErr       bool
+	}{
+		{
+			name: "Nothing scheduled",
+			due:  []time.Time{},
+		},
+		{
+			name: "Only due items are published",
+			due: []time.Time{
+				now.Add(time.Minute),
+				now.Add(-time.Hour),
+				now,
+				now.Add(-time.Second),
+			},
+			wantPublished: 3,
+			wantRemaining: 1,
+		},
+		{
+			name: "Stops on error",
+			due: []time.Time{
+				now.Add(-time.Hour),
+				now.Add(-time.Minute),
+				now.Add(-time.Second),
+			},
+			failAfter:     1,
+			wantPublished: 1,
+			wantRemaining: 2,
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := &fakePublisher{due: tt.due, failAfter: tt.failAfter}
+			s := New(publisher, time.Minute).WithClock(clock.NewFake(now))
+
+			published, err := s.PublishDue(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PublishDue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if published != tt.wantPublished {
+				t.Errorf("PublishDue() = %d, want %d", published, tt.wantPublished)
+			}
+			if len(publisher.due) != tt.wantRemaining {
+				t.Errorf("PublishDue() left %d items, want %d", len(publisher.due), tt.wantRemaining)
+			}
+		})
+	}
+}
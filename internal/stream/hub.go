@@ -0,0 +1,167 @@
+// Package stream fans live chirp events out to connected clients.
+package stream
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ChirpCreated = "chirp.created"
+	ChirpUpdated = "chirp.updated"
+	ChirpDeleted = "chirp.deleted"
+)
+
+// Event is one change to a chirp. Data is the JSON sent to clients; the
+// other fields let subscribers filter events without decoding it.
+type Event struct {
+	ID         uint64
+	Type       string
+	ChirpID    uuid.UUID
+	AuthorID   uuid.UUID
+	Visibility string
+	// QuoteOf is uuid.Nil unless the chirp quotes another
+	QuoteOf  uuid.UUID
+	Mentions []uuid.UUID
+	Data     []byte
+}
+
+// Filter decides whether a subscriber receives an event. Filters run while
+// the hub is locked, so they must be quick and must not call the hub.
+type Filter func(Event) bool
+
+// Hub is an in-process publish/subscribe hub. It keeps the most recent
+// events so reconnecting clients can resume where they left off.
+//
+// Publishing never blocks: a subscriber whose queue is full is dropped, and
+// its Events channel is closed.
+type Hub struct {
+	mu          sync.Mutex
+	lastID      uint64
+	replay      []Event
+	replayStart int
+	queueSize   int
+	subscribers map[*Subscription]struct{}
+}
+
+// NewHub creates a hub that keeps replaySize events for resuming clients
+// and queues up to queueSize events for each subscriber.
+func NewHub(replaySize, queueSize int) *Hub {
+	return &Hub{
+		replay:      make([]Event, 0, replaySize),
+		queueSize:   queueSize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish assigns the event an ID and delivers it to every matching
+// subscriber.
+func (h *Hub) Publish(event Event) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event.ID = h.lastID
+
+	if len(h.replay) < cap(h.replay) {
+		h.replay = append(h.replay, event)
+	} else if cap(h.replay) > 0 {
+		h.replay[h.replayStart] = event
+		h.replayStart = (h.replayStart + 1) % len(h.replay)
+	}
+
+	for sub := range h.subscribers {
+		if !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			h.remove(sub)
+		}
+	}
+	return event
+}
+
+// Subscription is one client's view of the hub.
+type Subscription struct {
+	hub    *Hub
+	filter Filter
+	events chan Event
+
+	// Replay holds the buffered events published after the ID the client
+	// resumed from, oldest first
+	Replay []Event
+	// Missed reports that some events after that ID are no longer
+	// buffered, or that the ID came from before the hub started, so the
+	// client should refetch instead of relying on the replay
+	Missed bool
+}
+
+// Subscribe registers a subscriber. lastID is the last event the client
+// saw, or zero for a new client.
+func (h *Hub) Subscribe(lastID uint64, filter Filter) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &Subscription{
+		hub:    h,
+		filter: filter,
+		events: make(chan Event, h.queueSize),
+		Replay: []Event{},
+	}
+
+	if lastID > 0 {
+		buffered := h.buffered()
+		oldest := h.lastID + 1
+		if len(buffered) > 0 {
+			oldest = buffered[0].ID
+		}
+		sub.Missed = lastID > h.lastID || lastID+1 < oldest
+
+		for _, event := range buffered {
+			if event.ID > lastID && filter(event) {
+				sub.Replay = append(sub.Replay, event)
+			}
+		}
+	}
+
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Events delivers live events. It's closed when the subscriber is dropped
+// for falling behind, or after Close.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close unsubscribes. It's safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Subscribers returns how many subscribers are connected.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subscribers[sub]; !ok {
+		return
+	}
+	delete(h.subscribers, sub)
+	close(sub.events)
+}
+
+// buffered returns the replay buffer oldest first.
+func (h *Hub) buffered() []Event {
+	events := make([]Event, 0, len(h.replay))
+	events = append(events, h.replay[h.replayStart:]...)
+	return append(events, h.replay[:h.replayStart]...)
+}
@@ -0,0 +1,190 @@
+package stream
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func all(Event) bool { return true }
+
+func eventIDs(events []Event) []uint64 {
+	ids := []uint64{}
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestPublish(t *testing.T) {
+	alice := uuid.New()
+	bob := uuid.New()
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		authors []uuid.UUID
+		wantIDs []uint64
+	}{
+		{
+			name:    "All events",
+			filter:  all,
+			authors: []uuid.UUID{alice, bob, alice},
+			wantIDs: []uint64{1, 2, 3},
+		},
+		{
+			name:    "Filtered by author",
+			filter:  func(e Event) bool { return e.AuthorID == bob },
+			authors: []uuid.UUID{alice, bob, alice, bob},
+			wantIDs: []uint64{2, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub(10, 10)
+			sub := hub.Subscribe(0, tt.filter)
+			defer sub.Close()
+
+			for _, author := range tt.authors {
+				hub.Publish(Event{Type: ChirpCreated, AuthorID: author})
+			}
+
+			got := []Event{}
+			for len(sub.Events()) > 0 {
+				got = append(got, <-sub.Events())
+			}
+			if !slices.Equal(eventIDs(got), tt.wantIDs) {
+				t.Errorf("received %v, want %v", eventIDs(got), tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	tests := []struct {
+		name       string
+		replaySize int
+		published  int
+		lastID     uint64
+		wantIDs    []uint64
+		wantMissed bool
+	}{
+		{
+			name:       "New client gets no replay",
+			replaySize: 5,
+			published:  3,
+			lastID:     0,
+			wantIDs:    []uint64{},
+		},
+		{
+			name:       "Resume inside the buffer",
+			replaySize: 5,
+			published:  4,
+			lastID:     2,
+			wantIDs:    []uint64{3, 4},
+		},
+		{
+			name:       "Up to date",
+			replaySize: 5,
+			published:  4,
+			lastID:     4,
+			wantIDs:    []uint64{},
+		},
+		{
+			name:       "Resume after the buffer wrapped",
+			replaySize: 3,
+			published:  7,
+			lastID:     4,
+			wantIDs:    []uint64{5, 6, 7},
+		},
+		{
+			name:       "Resume from before the buffer",
+			replaySize: 3,
+			published:  7,
+			lastID:     2,
+			wantIDs:    []uint64{5, 6, 7},
+			wantMissed: true,
+		},
+		{
+			name:       "ID from a previous process",
+			replaySize: 3,
+			published:  2,
+			lastID:     40,
+			wantIDs:    []uint64{},
+			wantMissed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub(tt.replaySize, 10)
+			for range tt.published {
+				hub.Publish(Event{Type: ChirpCreated})
+			}
+
+			sub := hub.Subscribe(tt.lastID, all)
+			defer sub.Close()
+
+			if got := eventIDs(sub.Replay); !slices.Equal(got, tt.wantIDs) {
+				t.Errorf("Subscribe() replay = %v, want %v", got, tt.wantIDs)
+			}
+			if sub.Missed != tt.wantMissed {
+				t.Errorf("Subscribe() missed = %v, want %v", sub.Missed, tt.wantMissed)
+			}
+		})
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	hub := NewHub(10, 2)
+	slow := hub.Subscribe(0, all)
+	fast := hub.Subscribe(0, all)
+	defer fast.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 5 {
+			hub.Publish(Event{Type: ChirpCreated})
+			<-fast.Events()
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish() blocked on a slow subscriber")
+	}
+
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("slow subscriber received %d events before being dropped, want 2", received)
+	}
+	if got := hub.Subscribers(); got != 1 {
+		t.Errorf("Subscribers() = %d, want 1", got)
+	}
+
+	// Closing a dropped subscription is a no-op
+	slow.Close()
+}
+
+func TestClose(t *testing.T) {
+	hub := NewHub(10, 10)
+	sub := hub.Subscribe(0, all)
+	sub.Close()
+	sub.Close()
+
+	hub.Publish(Event{Type: ChirpCreated})
+	if _, ok := <-sub.Events(); ok {
+		t.Error("Events() delivered an event after Close()")
+	}
+	if got := hub.Subscribers(); got != 0 {
+		t.Errorf("Subscribers() = %d, want 0", got)
+	}
+}
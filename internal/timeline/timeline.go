@@ -0,0 +1,41 @@
+package timeline
+
+import (
+	"chirpy/internal/database"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type Page struct {
+	Limit  int32
+	Offset int32
+}
+
+// Timeline builds a user's home timeline: their own chirps and rechirps and
+// those of the users they follow, newest first. Rechirps are placed by when
+// they were made and say who made them.
+type Timeline interface {
+	Home(ctx context.Context, userID uuid.UUID, page Page) ([]database.GetHomeTimelineRow, error)
+}
+
+// FanOutOnRead assembles timelines at request time with a single query
+// over the follow graph. Nothing is precomputed, so it needs no work on
+// write but gets slower as a user follows more accounts.
+type FanOutOnRead struct {
+	dbQueries *database.Queries
+}
+
+func NewFanOutOnRead(dbQueries *database.Queries) *FanOutOnRead {
+	return &FanOutOnRead{
+		dbQueries: dbQueries,
+	}
+}
+
+func (t *FanOutOnRead) Home(ctx context.Context, userID uuid.UUID, page Page) ([]database.GetHomeTimelineRow, error) {
+	return t.dbQueries.GetHomeTimeline(ctx, database.GetHomeTimelineParams{
+		UserID: userID,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	})
+}
@@ -0,0 +1,70 @@
+package trends
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DBStore is the Postgres-backed Store.
+type DBStore struct {
+	db        *sql.DB
+	dbQueries *database.Queries
+}
+
+func NewDBStore(db *sql.DB, dbQueries *database.Queries) *DBStore {
+	return &DBStore{
+		db:        db,
+		dbQueries: dbQueries,
+	}
+}
+
+func (s *DBStore) UsagesSince(ctx context.Context, since time.Time) ([]Usage, error) {
+	rows, err := s.dbQueries.GetTagUsagesSince(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make([]Usage, 0, len(rows))
+	for _, row := range rows {
+		usages = append(usages, Usage{
+			Tag:  row.Tag,
+			Time: row.CreatedAt,
+		})
+	}
+	return usages, nil
+}
+
+// ReplaceTrends swaps in a window's new trends in a single transaction so
+// readers never see a partially written ranking.
+func (s *DBStore) ReplaceTrends(ctx context.Context, window string, computedAt time.Time, trends []Trend) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.dbQueries.WithTx(tx)
+
+	err = qtx.DeleteTrendingTags(ctx, window)
+	if err != nil {
+		return err
+	}
+
+	for _, trend := range trends {
+		err = qtx.CreateTrendingTag(ctx, database.CreateTrendingTagParams{
+			WindowName:    window,
+			Tag:           trend.Tag,
+			Rank:          int32(trend.Rank),
+			Score:         trend.Score,
+			RecentCount:   int32(trend.RecentCount),
+			BaselineCount: int32(trend.BaselineCount),
+			ComputedAt:    computedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,90 @@
+package trends
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+func TestWorkerRefreshSkipsHiddenChirps(t *testing.T) {
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL isn't set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	dbQueries := database.New(db)
+	ctx := context.Background()
+
+	dbUser, err := dbQueries.CreateUser(ctx, database.CreateUserParams{
+		Email:          uuid.NewString() + "@example.com",
+		HashedPassword: "unused",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	defer db.Exec("DELETE FROM users WHERE id = $1", dbUser.ID)
+
+	// Tags are unique to the test, so other chirps don't affect it
+	visibleTag, hiddenTag := "visible"+uuid.NewString()[:8], "hidden"+uuid.NewString()[:8]
+	for _, tag := range []string{visibleTag, hiddenTag} {
+		dbChirp, err := dbQueries.CreateChirp(ctx, database.CreateChirpParams{
+			Body:       "#" + tag,
+			UserID:     dbUser.ID,
+			Visibility: "public",
+		})
+		if err != nil {
+			t.Fatalf("CreateChirp() error = %v", err)
+		}
+		err = dbQueries.CreateChirpTag(ctx, database.CreateChirpTagParams{
+			ChirpID: dbChirp.ID,
+			Tag:     tag,
+		})
+		if err != nil {
+			t.Fatalf("CreateChirpTag() error = %v", err)
+		}
+		if tag == hiddenTag {
+			err = dbQueries.HideChirp(ctx, dbChirp.ID)
+			if err != nil {
+				t.Fatalf("HideChirp() error = %v", err)
+			}
+		}
+	}
+
+	window := Window{Name: "test-" + uuid.NewString(), Duration: time.Hour, Baseline: 24 * time.Hour, HalfLife: 30 * time.Minute}
+	defer dbQueries.DeleteTrendingTags(ctx, window.Name)
+	worker := NewWorker(NewDBStore(db, dbQueries), Config{
+		Windows:  []Window{window},
+		MinCount: 1,
+		Limit:    1000,
+	})
+	err = worker.Refresh(ctx)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	rows, err := dbQueries.GetTrendingTags(ctx, window.Name)
+	if err != nil {
+		t.Fatalf("GetTrendingTags() error = %v", err)
+	}
+	tags := []string{}
+	for _, row := range rows {
+		tags = append(tags, row.Tag)
+	}
+	if !slices.Contains(tags, visibleTag) {
+		t.Errorf("trends = %v, want %s", tags, visibleTag)
+	}
+	if slices.Contains(tags, hiddenTag) {
+		t.Errorf("trends = %v, want %s left out since its chirp is hidden", tags, hiddenTag)
+	}
+}
@@ -0,0 +1,161 @@
+package trends
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Window is a period over which trending tags are ranked. Activity inside
+// the window is compared against the Baseline period immediately before it,
+// and each usage is weighted down by how long ago it happened.
+type Window struct {
+	Name     string
+	Duration time.Duration
+	Baseline time.Duration
+	HalfLife time.Duration
+}
+
+type Config struct {
+	Windows  []Window
+	Interval time.Duration
+	MinCount int
+	Limit    int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Windows: []Window{
+			{Name: "hour", Duration: time.Hour, Baseline: 24 * time.Hour, HalfLife: 30 * time.Minute},
+			{Name: "day", Duration: 24 * time.Hour, Baseline: 7 * 24 * time.Hour, HalfLife: 6 * time.Hour},
+		},
+		Interval: time.Minute,
+		MinCount: 2,
+		Limit:    10,
+	}
+}
+
+// ParseWindows parses a comma-separated list of windows in the form
+// name:duration:baseline:half-life, e.g. "hour:1h:24h:30m,day:24h:168h:6h".
+func ParseWindows(s string) ([]Window, error) {
+	windows := []Window{}
+	for _, spec := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(spec), ":")
+		if len(parts) != 4 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("invalid trends window %q", spec)
+		}
+
+		durations := make([]time.Duration, 3)
+		for i, part := range parts[1:] {
+			d, err := time.ParseDuration(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trends window %q: %w", spec, err)
+			}
+			durations[i] = d
+		}
+		if durations[0] <= 0 || durations[1] <= 0 || durations[2] < 0 {
+			return nil, fmt.Errorf("invalid trends window %q", spec)
+		}
+
+		windows = append(windows, Window{
+			Name:     parts[0],
+			Duration: durations[0],
+			Baseline: durations[1],
+			HalfLife: durations[2],
+		})
+	}
+	return windows, nil
+}
+
+// Usage is a single use of a hashtag in a chirp.
+type Usage struct {
+	Tag  string
+	Time time.Time
+}
+
+type Trend struct {
+	Tag           string
+	Rank          int
+	Score         float64
+	RecentCount   int
+	BaselineCount int
+}
+
+// Compute ranks the tags used in window ending at now.
+//
+// A tag's score is its decayed usage count in the window multiplied by its
+// velocity: the ratio of its count in the window to the count expected from
+// its baseline rate. Both sides are smoothed by one so that new tags don't
+// get an infinite velocity. Tags used fewer than minCount times in the
+// window are ignored.
+func Compute(now time.Time, window Window, usages []Usage, minCount, limit int) []Trend {
+	windowStart := now.Add(-window.Duration)
+	baselineStart := windowStart.Add(-window.Baseline)
+
+	type tally struct {
+		decayed  float64
+		recent   int
+		baseline int
+	}
+	tallies := map[string]*tally{}
+
+	for _, usage := range usages {
+		if usage.Time.After(now) || !usage.Time.After(baselineStart) {
+			continue
+		}
+		t, ok := tallies[usage.Tag]
+		if !ok {
+			t = &tally{}
+			tallies[usage.Tag] = t
+		}
+
+		if usage.Time.After(windowStart) {
+			t.recent++
+			t.decayed += decay(now.Sub(usage.Time), window.HalfLife)
+		} else {
+			t.baseline++
+		}
+	}
+
+	trends := []Trend{}
+	for tag, t := range tallies {
+		if t.recent < minCount {
+			continue
+		}
+		expected := float64(t.baseline) * float64(window.Duration) / float64(window.Baseline)
+		velocity := (float64(t.recent) + 1) / (expected + 1)
+		trends = append(trends, Trend{
+			Tag:           tag,
+			Score:         t.decayed * velocity,
+			RecentCount:   t.recent,
+			BaselineCount: t.baseline,
+		})
+	}
+
+	slices.SortFunc(trends, func(a, b Trend) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+
+	if limit > 0 && len(trends) > limit {
+		trends = trends[:limit]
+	}
+	for i := range trends {
+		trends[i].Rank = i + 1
+	}
+	return trends
+}
+
+func decay(age, halfLife time.Duration) float64 {
+	if halfLife <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
@@ -0,0 +1,204 @@
+package trends
+
+import (
+	"chirpy/internal/clock"
+	"context"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	usages []Usage
+	trends map[string][]Trend
+}
+
+func (s *fakeStore) UsagesSince(ctx context.Context, since time.Time) ([]Usage, error) {
+	usages := []Usage{}
+	for _, usage := range s.usages {
+		if usage.Time.After(since) {
+			usages = append(usages, usage)
+		}
+	}
+	return usages, nil
+}
+
+func (s *fakeStore) ReplaceTrends(ctx context.Context, window string, computedAt time.Time, trends []Trend) error {
+	s.trends[window] = trends
+	return nil
+}
+
+func usagesAt(tag string, now time.Time, ago ...time.Duration) []Usage {
+	usages := []Usage{}
+	for _, d := range ago {
+		usages = append(usages, Usage{Tag: tag, Time: now.Add(-d)})
+	}
+	return usages
+}
+
+func TestCompute(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := Window{Name: "hour", Duration: time.Hour, Baseline: 24 * time.Hour}
+
+	tests := []struct {
+		name     string
+		usages   []Usage
+		wantTags []string
+	}{
+		{
+			name:     "No usages",
+			usages:   nil,
+			wantTags: []string{},
+		},
+		{
+			name: "Below minimum count",
+			usages: append(
+				usagesAt("go", now, 10*time.Minute),
+				usagesAt("rust", now, 10*time.Minute, 20*time.Minute)...,
+			),
+			wantTags: []string{"rust"},
+		},
+		{
+			name: "Spike beats steady volume",
+			usages: append(
+				// 3 uses an hour, all day long
+				usagesAt("steady", now, 10*time.Minute, 30*time.Minute, 50*time.Minute,
+					2*time.Hour, 3*time.Hour, 4*time.Hour, 5*time.Hour, 6*time.Hour, 7*time.Hour,
+					8*time.Hour, 9*time.Hour, 10*time.Hour, 11*time.Hour, 12*time.Hour, 13*time.Hour),
+				// same volume this hour, nothing before
+				usagesAt("spike", now, 10*time.Minute, 30*time.Minute, 50*time.Minute)...,
+			),
+			wantTags: []string{"spike", "steady"},
+		},
+		{
+			name: "Usages outside window only count as baseline",
+			usages: append(
+				usagesAt("old", now, 2*time.Hour, 3*time.Hour, 4*time.Hour),
+				usagesAt("new", now, time.Minute, 2*time.Minute)...,
+			),
+			wantTags: []string{"new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compute(now, window, tt.usages, 2, 10)
+			if len(got) != len(tt.wantTags) {
+				t.Fatalf("Compute() returned %d trends, want %d: %v", len(got), len(tt.wantTags), got)
+			}
+			for i, trend := range got {
+				if trend.Tag != tt.wantTags[i] || trend.Rank != i+1 {
+					t.Errorf("Compute()[%d] = %s (rank %d), want %s (rank %d)", i, trend.Tag, trend.Rank, tt.wantTags[i], i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestComputeDecay(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := Window{Name: "hour", Duration: time.Hour, Baseline: 24 * time.Hour, HalfLife: 10 * time.Minute}
+
+	usages := append(
+		usagesAt("earlier", now, 40*time.Minute, 50*time.Minute),
+		usagesAt("later", now, time.Minute, 2*time.Minute)...,
+	)
+
+	got := Compute(now, window, usages, 2, 10)
+	if len(got) != 2 || got[0].Tag != "later" {
+		t.Fatalf("Compute() = %v, want later ranked first", got)
+	}
+	if got[0].RecentCount != got[1].RecentCount {
+		t.Errorf("decay changed recent counts: %d vs %d", got[0].RecentCount, got[1].RecentCount)
+	}
+}
+
+func TestComputeLimit(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := Window{Name: "hour", Duration: time.Hour, Baseline: time.Hour}
+
+	usages := []Usage{}
+	for _, tag := range []string{"a", "b", "c", "d"} {
+		usages = append(usages, usagesAt(tag, now, time.Minute, 2*time.Minute)...)
+	}
+
+	got := Compute(now, window, usages, 1, 3)
+	if len(got) != 3 {
+		t.Fatalf("Compute() returned %d trends, want 3", len(got))
+	}
+	if got[0].Tag != "a" || got[2].Tag != "c" {
+		t.Errorf("Compute() ties not broken alphabetically: %v", got)
+	}
+}
+
+func TestWorkerRefresh(t *testing.T) {
+	clock := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	store := &fakeStore{trends: map[string][]Trend{}}
+	store.usages = usagesAt("go", clock.Now(), 30*time.Minute, 90*time.Minute, 3*time.Hour)
+
+	config := DefaultConfig()
+	worker := NewWorker(store, config).WithClock(clock)
+
+	err := worker.Refresh(context.Background())
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got := store.trends["hour"]; len(got) != 0 {
+		t.Errorf("hour trends = %v, want none", got)
+	}
+	if got := store.trends["day"]; len(got) != 1 || got[0].RecentCount != 3 {
+		t.Errorf("day trends = %v, want go with 3 uses", got)
+	}
+
+	// Most of the uses have aged out of the day window by now
+	clock.Advance(23 * time.Hour)
+	err = worker.Refresh(context.Background())
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got := store.trends["day"]; len(got) != 0 {
+		t.Errorf("day trends = %v, want none after the window passed", got)
+	}
+}
+
+func TestParseWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:  "Valid windows",
+			input: "hour:1h:24h:30m, day:24h:168h:0s",
+			want:  2,
+		},
+		{
+			name:    "Missing half-life",
+			input:   "hour:1h:24h",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid duration",
+			input:   "hour:1x:24h:30m",
+			wantErr: true,
+		},
+		{
+			name:    "Zero baseline",
+			input:   "hour:1h:0s:30m",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWindows(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseWindows() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != tt.want {
+				t.Errorf("ParseWindows() returned %d windows, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
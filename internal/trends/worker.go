@@ -0,0 +1,71 @@
+package trends
+
+import (
+	"chirpy/internal/clock"
+	"chirpy/internal/worker"
+	"context"
+	"log"
+	"time"
+)
+
+// Store loads hashtag usage and saves the computed trends.
+type Store interface {
+	UsagesSince(ctx context.Context, since time.Time) ([]Usage, error)
+	ReplaceTrends(ctx context.Context, window string, computedAt time.Time, trends []Trend) error
+}
+
+// Worker periodically recomputes the trending tags for every configured
+// window, so requests only have to read the stored results.
+type Worker struct {
+	store  Store
+	clock  clock.Clock
+	config Config
+}
+
+func NewWorker(store Store, config Config) *Worker {
+	return &Worker{
+		store:  store,
+		clock:  clock.Real{},
+		config: config,
+	}
+}
+
+// WithClock replaces the worker's clock, for tests.
+func (w *Worker) WithClock(clock clock.Clock) *Worker {
+	w.clock = clock
+	return w
+}
+
+// Run refreshes the trends immediately and then every Interval until ctx is
+// cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	worker.Every(ctx, w.config.Interval, func(ctx context.Context) {
+		if err := w.Refresh(ctx); err != nil {
+			log.Printf("Error refreshing trends: %v", err)
+		}
+	})
+}
+
+// Refresh recomputes and stores the trends for every window.
+func (w *Worker) Refresh(ctx context.Context) error {
+	now := w.clock.Now()
+
+	var longest time.Duration
+	for _, window := range w.config.Windows {
+		longest = max(longest, window.Duration+window.Baseline)
+	}
+
+	usages, err := w.store.UsagesSince(ctx, now.Add(-longest))
+	if err != nil {
+		return err
+	}
+
+	for _, window := range w.config.Windows {
+		trends := Compute(now, window, usages, w.config.MinCount, w.config.Limit)
+		err = w.store.ReplaceTrends(ctx, window.Name, now, trends)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package webhooks
+
+import (
+	"bytes"
+	"chirpy/internal/clock"
+	"chirpy/internal/egress"
+	"chirpy/internal/worker"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// BatchSize is how many deliveries are claimed at once
+	BatchSize = 50
+	// Lease is how long a claimed delivery is left alone before it's
+	// assumed lost and claimed again
+	Lease = 5 * time.Minute
+	// MaxAttempts is how many times a delivery is tried before it fails
+	MaxAttempts = 8
+	// DisableAfter is how many failed attempts in a row, across all of a
+	// webhook's deliveries, disable it
+	DisableAfter = 20
+	// Timeout is how long a receiver has to respond
+	Timeout = 10 * time.Second
+	// Retention is how long finished deliveries are kept in the log
+	Retention = 30 * 24 * time.Hour
+
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+	userAgent   = "Chirpy-Webhooks/1.0"
+)
+
+// Delivery is an event waiting to be sent to a webhook.
+type Delivery struct {
+	ID        uuid.UUID
+	WebhookID uuid.UUID
+	URL       string
+	Secret    string
+	EventType string
+	Payload   []byte
+	// Attempts includes the current one
+	Attempts int
+}
+
+// Result is the outcome of one attempt. StatusCode is 0 if no response was
+// received.
+type Result struct {
+	StatusCode int
+	Err        error
+}
+
+func (r Result) OK() bool {
+	return r.Err == nil
+}
+
+// Store tracks deliveries and the webhooks they're for.
+type Store interface {
+	// Claim returns up to limit deliveries due at now, for enabled
+	// webhooks, and hides them from other claims until leaseUntil
+	Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error)
+	// MarkSucceeded records a successful attempt and resets the webhook's
+	// failure count
+	MarkSucceeded(ctx context.Context, delivery Delivery, at time.Time, result Result) error
+	// MarkRetry records a failed attempt, schedules another, and returns
+	// the webhook's failure count
+	MarkRetry(ctx context.Context, delivery Delivery, at, retryAt time.Time, result Result) (int, error)
+	// MarkFailed records the last failed attempt and returns the webhook's
+	// failure count
+	MarkFailed(ctx context.Context, delivery Delivery, at time.Time, result Result) (int, error)
+	// Disable stops deliveries to a webhook until its owner enables it
+	Disable(ctx context.Context, webhookID uuid.UUID, at time.Time) error
+	// PurgeBefore removes finished deliveries created before cutoff
+	PurgeBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// Sender posts pending deliveries to their webhooks, retrying failures with
+// exponential backoff.
+type Sender struct {
+	store    Store
+	client   *http.Client
+	clock    clock.Clock
+	interval time.Duration
+}
+
+func NewSender(store Store, interval time.Duration) *Sender {
+	return &Sender{
+		store:    store,
+		client:   egress.NewClient(Timeout),
+		clock:    clock.Real{},
+		interval: interval,
+	}
+}
+
+// WithClock replaces the sender's clock, for tests.
+func (s *Sender) WithClock(clock clock.Clock) *Sender {
+	s.clock = clock
+	return s
+}
+
+// WithClient replaces the sender's HTTP client. The default one can only
+// reach public addresses.
+func (s *Sender) WithClient(client *http.Client) *Sender {
+	s.client = client
+	return s
+}
+
+// Run sends due deliveries every interval until ctx is cancelled.
+func (s *Sender) Run(ctx context.Context) {
+	worker.Queue{
+		Name:      "webhook deliveries",
+		BatchSize: BatchSize,
+		Process:   s.SendDue,
+		Purge: func(ctx context.Context) (int64, error) {
+			return s.store.PurgeBefore(ctx, s.clock.Now().Add(-Retention))
+		},
+	}.Run(ctx, s.interval)
+}
+
+// SendDue sends a batch of due deliveries and returns how many it claimed.
+func (s *Sender) SendDue(ctx context.Context) (int, error) {
+	now := s.clock.Now()
+	deliveries, err := s.store.Claim(ctx, now, now.Add(Lease), BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	// Deliveries to webhooks disabled partway through the batch are left
+	// for when the webhook is enabled again
+	disabled := map[uuid.UUID]bool{}
+	for _, delivery := range deliveries {
+		if disabled[delivery.WebhookID] {
+			continue
+		}
+		disabled[delivery.WebhookID], err = s.deliver(ctx, delivery)
+		if err != nil {
+			return len(deliveries), err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// deliver sends a delivery, records the outcome, and reports whether the
+// webhook was disabled. It only returns errors from the store.
+func (s *Sender) deliver(ctx context.Context, delivery Delivery) (bool, error) {
+	result := s.Send(ctx, delivery)
+	now := s.clock.Now()
+	if result.OK() {
+		return false, s.store.MarkSucceeded(ctx, delivery, now, result)
+	}
+
+	var failures int
+	var err error
+	if delivery.Attempts >= MaxAttempts {
+		failures, err = s.store.MarkFailed(ctx, delivery, now, result)
+	} else {
+		failures, err = s.store.MarkRetry(ctx, delivery, now, now.Add(Backoff(delivery.Attempts)), result)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if failures >= DisableAfter {
+		log.Printf("Disabling webhook %s after %d failed attempts", delivery.WebhookID, failures)
+		return true, s.store.Disable(ctx, delivery.WebhookID, now)
+	}
+	return false, nil
+}
+
+// Send makes one signed attempt at a delivery without recording it. Any
+// 2xx response is a success.
+func (s *Sender) Send(ctx context.Context, delivery Delivery) Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return Result{Err: ErrInvalidURL}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, s.clock.Now(), delivery.Payload))
+
+	// Results are shown to the webhook's owner, so they don't get the
+	// underlying error, which would tell them about the network
+	resp, err := s.client.Do(req)
+	if errors.Is(err, egress.ErrForbiddenAddress) {
+		return Result{Err: ErrForbiddenURL}
+	} else if err != nil {
+		log.Printf("Error sending webhook delivery %s: %v", delivery.ID, err)
+		return Result{Err: ErrUnreachable}
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	io.CopyN(io.Discard, resp.Body, 4096)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Result{
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("receiver responded with %d", resp.StatusCode),
+		}
+	}
+	return Result{StatusCode: resp.StatusCode}
+}
+
+// Backoff is how long to wait after a failed attempt, doubling each time up
+// to six hours.
+func Backoff(attempts int) time.Duration {
+	return worker.Backoff(attempts, baseBackoff, maxBackoff)
+}
@@ -0,0 +1,344 @@
+package webhooks
+
+import (
+	"chirpy/internal/clock"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fakeDelivery struct {
+	Delivery
+	status        string
+	nextAttemptAt time.Time
+	result        Result
+}
+
+type fakeStore struct {
+	deliveries []*fakeDelivery
+	failures   map[uuid.UUID]int
+	disabled   map[uuid.UUID]bool
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		failures: map[uuid.UUID]int{},
+		disabled: map[uuid.UUID]bool{},
+	}
+}
+
+func (s *fakeStore) add(webhookID uuid.UUID, url, payload string, now time.Time) *fakeDelivery {
+	delivery := &fakeDelivery{
+		Delivery: Delivery{
+			ID:        uuid.New(),
+			WebhookID: webhookID,
+			URL:       url,
+			Secret:    "whsec_test",
+			EventType: "chirp.created",
+			Payload:   []byte(payload),
+		},
+		status:        "pending",
+		nextAttemptAt: now,
+	}
+	s.deliveries = append(s.deliveries, delivery)
+	return delivery
+}
+
+func (s *fakeStore) find(id uuid.UUID) *fakeDelivery {
+	for _, delivery := range s.deliveries {
+		if delivery.ID == id {
+			return delivery
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error) {
+	claimed := []Delivery{}
+	for _, delivery := range s.deliveries {
+		if len(claimed) == limit {
+			break
+		}
+		if delivery.status != "pending" || s.disabled[delivery.WebhookID] || delivery.nextAttemptAt.After(now) {
+			continue
+		}
+		delivery.Attempts++
+		delivery.nextAttemptAt = leaseUntil
+		claimed = append(claimed, delivery.Delivery)
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) MarkSucceeded(ctx context.Context, delivery Delivery, at time.Time, result Result) error {
+	stored := s.find(delivery.ID)
+	stored.status = "succeeded"
+	stored.result = result
+	s.failures[delivery.WebhookID] = 0
+	return nil
+}
+
+func (s *fakeStore) MarkRetry(ctx context.Context, delivery Delivery, at, retryAt time.Time, result Result) (int, error) {
+	stored := s.find(delivery.ID)
+	stored.nextAttemptAt = retryAt
+	stored.result = result
+	s.failures[delivery.WebhookID]++
+	return s.failures[delivery.WebhookID], nil
+}
+
+func (s *fakeStore) MarkFailed(ctx context.Context, delivery Delivery, at time.Time, result Result) (int, error) {
+	stored := s.find(delivery.ID)
+	stored.status = "failed"
+	stored.result = result
+	s.failures[delivery.WebhookID]++
+	return s.failures[delivery.WebhookID], nil
+}
+
+func (s *fakeStore) Disable(ctx context.Context, webhookID uuid.UUID, at time.Time) error {
+	s.disabled[webhookID] = true
+	return nil
+}
+
+func (s *fakeStore) PurgeBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// receiver is an httptest server that records the requests it gets and
+// responds with status.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	r := &receiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, receivedRequest{header: req.Header, body: body})
+		status := r.status
+		r.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *receiver) received() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+func TestSendDueSignsRequests(t *testing.T) {
+	now := time.Now().UTC()
+	receiver := newReceiver(t, http.StatusNoContent)
+	store := newFakeStore()
+	sender := NewSender(store, time.Second).WithClock(clock.NewFake(now)).WithClient(receiver.Client())
+
+	payload := `{"type":"chirp.created","data":{"body":"Hello"}}`
+	delivery := store.add(uuid.New(), receiver.URL, payload, now)
+
+	sent, err := sender.SendDue(context.Background())
+	if err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+	if sent != 1 {
+		t.Errorf("SendDue() = %d, want 1", sent)
+	}
+	if delivery.status != "succeeded" || delivery.result.StatusCode != http.StatusNoContent {
+		t.Errorf("delivery = %s with %d, want succeeded with %d", delivery.status, delivery.result.StatusCode, http.StatusNoContent)
+	}
+
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(requests))
+	}
+	got := requests[0]
+	if string(got.body) != payload {
+		t.Errorf("body = %s, want %s", got.body, payload)
+	}
+	if got.header.Get(EventHeader) != "chirp.created" {
+		t.Errorf("%s = %q, want %q", EventHeader, got.header.Get(EventHeader), "chirp.created")
+	}
+	if got.header.Get(DeliveryHeader) != delivery.ID.String() {
+		t.Errorf("%s = %q, want %q", DeliveryHeader, got.header.Get(DeliveryHeader), delivery.ID)
+	}
+	err = Verify("whsec_test", got.header.Get(SignatureHeader), got.body, now, time.Minute)
+	if err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestSendDueRetries(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := clock.NewFake(now)
+	receiver := newReceiver(t, http.StatusInternalServerError)
+	store := newFakeStore()
+	sender := NewSender(store, time.Second).WithClock(clock).WithClient(receiver.Client())
+
+	webhookID := uuid.New()
+	delivery := store.add(webhookID, receiver.URL, `{}`, now)
+
+	_, err := sender.SendDue(context.Background())
+	if err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+	if delivery.status != "pending" || delivery.result.StatusCode != http.StatusInternalServerError {
+		t.Errorf("delivery = %s with %d, want pending with %d", delivery.status, delivery.result.StatusCode, http.StatusInternalServerError)
+	}
+	if want := now.Add(Backoff(1)); !delivery.nextAttemptAt.Equal(want) {
+		t.Errorf("nextAttemptAt = %v, want %v", delivery.nextAttemptAt, want)
+	}
+
+	// Not retried before the backoff has passed
+	sent, _ := sender.SendDue(context.Background())
+	if sent != 0 {
+		t.Errorf("SendDue() = %d before the backoff passed, want 0", sent)
+	}
+
+	receiver.setStatus(http.StatusOK)
+	clock.Set(delivery.nextAttemptAt)
+	_, err = sender.SendDue(context.Background())
+	if err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+	if delivery.status != "succeeded" {
+		t.Errorf("delivery = %s after the receiver recovered, want succeeded", delivery.status)
+	}
+	if store.failures[webhookID] != 0 {
+		t.Errorf("failures = %d after a success, want 0", store.failures[webhookID])
+	}
+	if len(receiver.received()) != 2 {
+		t.Errorf("receiver got %d requests, want 2", len(receiver.received()))
+	}
+}
+
+func TestSendDueGivesUp(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := clock.NewFake(now)
+	receiver := newReceiver(t, http.StatusGone)
+	store := newFakeStore()
+	sender := NewSender(store, time.Second).WithClock(clock).WithClient(receiver.Client())
+
+	delivery := store.add(uuid.New(), receiver.URL, `{}`, now)
+	for range MaxAttempts {
+		clock.Set(delivery.nextAttemptAt)
+		_, err := sender.SendDue(context.Background())
+		if err != nil {
+			t.Fatalf("SendDue() error = %v", err)
+		}
+	}
+
+	if delivery.status != "failed" {
+		t.Errorf("delivery = %s after %d attempts, want failed", delivery.status, MaxAttempts)
+	}
+	if len(receiver.received()) != MaxAttempts {
+		t.Errorf("receiver got %d requests, want %d", len(receiver.received()), MaxAttempts)
+	}
+}
+
+func TestSendDueDisablesFailingWebhooks(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	receiver := newReceiver(t, http.StatusServiceUnavailable)
+	store := newFakeStore()
+	sender := NewSender(store, time.Second).WithClock(clock.NewFake(now)).WithClient(receiver.Client())
+
+	webhookID := uuid.New()
+	for range DisableAfter + 5 {
+		store.add(webhookID, receiver.URL, `{}`, now)
+	}
+
+	_, err := sender.SendDue(context.Background())
+	if err != nil {
+		t.Fatalf("SendDue() error = %v", err)
+	}
+	if !store.disabled[webhookID] {
+		t.Errorf("webhook not disabled after %d failures", store.failures[webhookID])
+	}
+
+	if len(receiver.received()) != DisableAfter {
+		t.Errorf("receiver got %d requests, want %d", len(receiver.received()), DisableAfter)
+	}
+
+	sent, _ := sender.SendDue(context.Background())
+	if sent != 0 {
+		t.Errorf("SendDue() = %d for a disabled webhook, want 0", sent)
+	}
+}
+
+func TestSendUnreachable(t *testing.T) {
+	receiver := newReceiver(t, http.StatusOK)
+	url := receiver.URL
+	client := receiver.Client()
+	receiver.Close()
+
+	sender := NewSender(newFakeStore(), time.Second).WithClient(client)
+	result := sender.Send(context.Background(), Delivery{
+		ID:      uuid.New(),
+		URL:     url,
+		Secret:  "whsec_test",
+		Payload: []byte(`{}`),
+	})
+	// The dial error isn't passed on, since it's shown to the owner
+	if result.Err != ErrUnreachable {
+		t.Errorf("Send() to a closed server error = %v, want %v", result.Err, ErrUnreachable)
+	}
+	if result.StatusCode != 0 {
+		t.Errorf("StatusCode = %d, want 0", result.StatusCode)
+	}
+}
+
+func TestSendRefusesLocalAddresses(t *testing.T) {
+	receiver := newReceiver(t, http.StatusOK)
+
+	sender := NewSender(newFakeStore(), time.Second)
+	result := sender.Send(context.Background(), Delivery{
+		ID:      uuid.New(),
+		URL:     receiver.URL,
+		Secret:  "whsec_test",
+		Payload: []byte(`{}`),
+	})
+	if result.Err != ErrForbiddenURL {
+		t.Errorf("Send() to a loopback address error = %v, want %v", result.Err, ErrForbiddenURL)
+	}
+	if len(receiver.received()) != 0 {
+		t.Errorf("receiver got %d requests, want 0", len(receiver.received()))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{7, 32 * time.Minute},
+		{20, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
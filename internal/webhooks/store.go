@@ -0,0 +1,155 @@
+package webhooks
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DBStore is the Postgres-backed Store.
+type DBStore struct {
+	db        *sql.DB
+	dbQueries *database.Queries
+}
+
+func NewDBStore(db *sql.DB, dbQueries *database.Queries) *DBStore {
+	return &DBStore{
+		db:        db,
+		dbQueries: dbQueries,
+	}
+}
+
+func (s *DBStore) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error) {
+	rows, err := s.dbQueries.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil: leaseUntil,
+		Now:        now,
+		Limit:      int32(limit),
+	})
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	webhookIDs := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		webhookIDs = append(webhookIDs, row.WebhookID)
+	}
+	dbWebhooks, err := s.dbQueries.GetWebhooksByIDs(ctx, webhookIDs)
+	if err != nil {
+		return nil, err
+	}
+	webhooks := make(map[uuid.UUID]database.Webhook, len(dbWebhooks))
+	for _, dbWebhook := range dbWebhooks {
+		webhooks[dbWebhook.ID] = dbWebhook
+	}
+
+	deliveries := make([]Delivery, 0, len(rows))
+	for _, row := range rows {
+		// Webhooks deleted since the claim take their deliveries with them
+		webhook, ok := webhooks[row.WebhookID]
+		if !ok {
+			continue
+		}
+		deliveries = append(deliveries, Delivery{
+			ID:        row.ID,
+			WebhookID: row.WebhookID,
+			URL:       webhook.Url,
+			Secret:    webhook.Secret,
+			EventType: row.EventType,
+			Payload:   row.Payload,
+			Attempts:  int(row.Attempts),
+		})
+	}
+	return deliveries, nil
+}
+
+func (s *DBStore) MarkSucceeded(ctx context.Context, delivery Delivery, at time.Time, result Result) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.dbQueries.WithTx(tx)
+
+	_, err = qtx.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+		AttemptedAt:    at,
+		ResponseStatus: ResponseStatus(result),
+		ID:             delivery.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = qtx.ResetWebhookFailures(ctx, delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *DBStore) MarkRetry(ctx context.Context, delivery Delivery, at, retryAt time.Time, result Result) (int, error) {
+	return s.markFailedAttempt(ctx, delivery, func(qtx *database.Queries) error {
+		_, err := qtx.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{
+			NextAttemptAt:  retryAt,
+			AttemptedAt:    at,
+			ResponseStatus: ResponseStatus(result),
+			LastError:      result.Err.Error(),
+			ID:             delivery.ID,
+		})
+		return err
+	})
+}
+
+func (s *DBStore) MarkFailed(ctx context.Context, delivery Delivery, at time.Time, result Result) (int, error) {
+	return s.markFailedAttempt(ctx, delivery, func(qtx *database.Queries) error {
+		_, err := qtx.FailWebhookDelivery(ctx, database.FailWebhookDeliveryParams{
+			AttemptedAt:    at,
+			ResponseStatus: ResponseStatus(result),
+			LastError:      result.Err.Error(),
+			ID:             delivery.ID,
+		})
+		return err
+	})
+}
+
+// markFailedAttempt updates a delivery and counts the failure against its
+// webhook in one transaction.
+func (s *DBStore) markFailedAttempt(ctx context.Context, delivery Delivery, update func(qtx *database.Queries) error) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := s.dbQueries.WithTx(tx)
+
+	err = update(qtx)
+	if err != nil {
+		return 0, err
+	}
+
+	failures, err := qtx.IncrementWebhookFailures(ctx, delivery.WebhookID)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(failures), tx.Commit()
+}
+
+func (s *DBStore) Disable(ctx context.Context, webhookID uuid.UUID, at time.Time) error {
+	return s.dbQueries.DisableWebhook(ctx, database.DisableWebhookParams{
+		DisabledAt: at,
+		ID:         webhookID,
+	})
+}
+
+func (s *DBStore) PurgeBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return s.dbQueries.DeleteWebhookDeliveriesBefore(ctx, cutoff)
+}
+
+// ResponseStatus is the result's status code as stored in the delivery log.
+func ResponseStatus(result Result) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(result.StatusCode), Valid: result.StatusCode != 0}
+}
@@ -0,0 +1,117 @@
+package webhooks
+
+import (
+	"chirpy/internal/egress"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request headers
+const (
+	SignatureHeader = "Chirpy-Signature"
+	EventHeader     = "Chirpy-Event"
+	DeliveryHeader  = "Chirpy-Delivery"
+)
+
+// TypeTest is the event type of test deliveries.
+const TypeTest = "webhook.test"
+
+var (
+	ErrInvalidURL       = errors.New("webhook URL must be an absolute http or https URL")
+	ErrForbiddenURL     = errors.New("webhook URL must resolve to a public address")
+	ErrUnresolvableURL  = errors.New("webhook URL's host couldn't be resolved")
+	ErrUnreachable      = errors.New("webhook URL couldn't be reached")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrExpiredSignature = errors.New("webhook signature timestamp is too old")
+)
+
+// ValidateURL checks a webhook endpoint. Its host must only resolve to
+// public addresses, so webhooks can't be used to reach the server's own
+// network.
+func ValidateURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrInvalidURL
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Hostname()) == 0 {
+		return ErrInvalidURL
+	}
+
+	err = egress.CheckHost(ctx, parsed.Hostname())
+	if errors.Is(err, egress.ErrForbiddenAddress) {
+		return ErrForbiddenURL
+	} else if err != nil {
+		return ErrUnresolvableURL
+	}
+	return nil
+}
+
+// NewSecret generates a signing secret for a webhook.
+func NewSecret() (string, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(key), nil
+}
+
+// Sign returns the Chirpy-Signature header for a payload. It has the form
+// "t=<unix timestamp>,v1=<hex HMAC-SHA256 of timestamp.payload>", so
+// receivers can reject replayed requests.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", unix, signature(secret, unix, payload))
+}
+
+// Verify checks a Chirpy-Signature header the way a receiver should,
+// rejecting signatures older than tolerance.
+func Verify(secret, header string, payload []byte, now time.Time, tolerance time.Duration) error {
+	unix := ""
+	signatures := []string{}
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return ErrInvalidSignature
+		}
+		switch key {
+		case "t":
+			unix = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if now.Sub(time.Unix(seconds, 0)) > tolerance {
+		return ErrExpiredSignature
+	}
+
+	expected := signature(secret, unix, payload)
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func signature(secret, unix string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
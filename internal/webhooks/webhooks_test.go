@@ -0,0 +1,171 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{
+			name: "HTTPS",
+			url:  "https://93.184.216.34/hooks/chirpy",
+		},
+		{
+			name: "HTTP with a port",
+			url:  "http://93.184.216.34:8081/hooks",
+		},
+		{
+			name:    "Relative",
+			url:     "/hooks",
+			wantErr: ErrInvalidURL,
+		},
+		{
+			name:    "Other scheme",
+			url:     "ftp://example.com/hooks",
+			wantErr: ErrInvalidURL,
+		},
+		{
+			name:    "Empty",
+			url:     "",
+			wantErr: ErrInvalidURL,
+		},
+		{
+			name:    "Loopback",
+			url:     "http://127.0.0.1:5432",
+			wantErr: ErrForbiddenURL,
+		},
+		{
+			name:    "Localhost",
+			url:     "http://localhost:8081/hooks",
+			wantErr: ErrForbiddenURL,
+		},
+		{
+			name:    "Cloud metadata",
+			url:     "http://169.254.169.254/latest/meta-data/",
+			wantErr: ErrForbiddenURL,
+		},
+		{
+			name:    "Private network",
+			url:     "https://10.0.0.5/hooks",
+			wantErr: ErrForbiddenURL,
+		},
+		{
+			name:    "IPv6 loopback",
+			url:     "http://[::1]/hooks",
+			wantErr: ErrForbiddenURL,
+		},
+		{
+			name:    "Unresolvable",
+			url:     "https://hooks.invalid/chirpy",
+			wantErr: ErrUnresolvableURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateURL(context.Background(), tt.url)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	first, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret() error = %v", err)
+	}
+	second, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret() error = %v", err)
+	}
+
+	if !strings.HasPrefix(first, "whsec_") {
+		t.Errorf("NewSecret() = %q, want a whsec_ prefix", first)
+	}
+	if first == second {
+		t.Error("NewSecret() returned the same secret twice")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := "whsec_test"
+	payload := []byte(`{"type":"chirp.created"}`)
+	signedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	header := Sign(secret, signedAt, payload)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		payload []byte
+		now     time.Time
+		wantErr error
+	}{
+		{
+			name:    "Valid signature",
+			secret:  secret,
+			header:  header,
+			payload: payload,
+			now:     signedAt.Add(time.Minute),
+		},
+		{
+			name:    "Wrong secret",
+			secret:  "whsec_other",
+			header:  header,
+			payload: payload,
+			now:     signedAt,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Modified payload",
+			secret:  secret,
+			header:  header,
+			payload: []byte(`{"type":"chirp.deleted"}`),
+			now:     signedAt,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Too old",
+			secret:  secret,
+			header:  header,
+			payload: payload,
+			now:     signedAt.Add(10 * time.Minute),
+			wantErr: ErrExpiredSignature,
+		},
+		{
+			name:    "Malformed header",
+			secret:  secret,
+			header:  "v1",
+			payload: payload,
+			now:     signedAt,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Missing timestamp",
+			secret:  secret,
+			header:  header[strings.Index(header, ",")+1:],
+			payload: payload,
+			now:     signedAt,
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.payload, tt.now, 5*time.Minute)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
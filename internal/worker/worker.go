@@ -0,0 +1,78 @@
+// Package worker has what the background jobs share: running on an
+// interval, catching up on backlogs in queues, and backoff between retries.
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Queue is a table of items that are claimed and processed in batches.
+type Queue struct {
+	// Name is what the items are called in log messages
+	Name      string
+	BatchSize int
+	// Process handles a batch of due items and returns how many it
+	// handled
+	Process func(ctx context.Context) (int, error)
+	// Purge removes finished items that have been kept long enough and
+	// returns how many it removed
+	Purge func(ctx context.Context) (int64, error)
+}
+
+// Every calls run immediately and then every interval until ctx is
+// cancelled. run should log its own errors.
+func Every(ctx context.Context, interval time.Duration, run func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run processes due items every interval until ctx is cancelled. While
+// Process keeps handling full batches there's a backlog, so it's called
+// again without waiting. Finished items are purged after each round.
+func (q Queue) Run(ctx context.Context, interval time.Duration) {
+	Every(ctx, interval, q.runOnce)
+}
+
+func (q Queue) runOnce(ctx context.Context) {
+	for {
+		processed, err := q.Process(ctx)
+		if err != nil {
+			log.Printf("Error processing %s: %v", q.Name, err)
+			break
+		} else if processed < q.BatchSize {
+			break
+		}
+	}
+
+	purged, err := q.Purge(ctx)
+	if err != nil {
+		log.Printf("Error purging %s: %v", q.Name, err)
+	} else if purged > 0 {
+		log.Printf("Purged %d %s", purged, q.Name)
+	}
+}
+
+// Backoff is how long to wait after a failed attempt: base after the
+// first, doubling each time up to limit.
+func Backoff(attempts int, base, limit time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= limit {
+			return limit
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts, time.Minute, 10*time.Minute); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		batches     []int
+		err         error
+		wantProcess int
+	}{
+		{
+			name:        "Empty",
+			batches:     []int{0},
+			wantProcess: 1,
+		},
+		{
+			name:        "Backlog",
+			batches:     []int{10, 10, 3},
+			wantProcess: 3,
+		},
+		{
+			name:        "Error",
+			batches:     []int{10, 10},
+			err:         errors.New("database is down"),
+			wantProcess: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			processed := 0
+			purged := 0
+			Queue{
+				Name:      "things",
+				BatchSize: 10,
+				Process: func(ctx context.Context) (int, error) {
+					processed++
+					if tt.err != nil {
+						return 0, tt.err
+					}
+					return tt.batches[processed-1], nil
+				},
+				Purge: func(ctx context.Context) (int64, error) {
+					purged++
+					// Stop after the first round
+					cancel()
+					return 0, nil
+				},
+			}.Run(ctx, time.Hour)
+
+			if processed != tt.wantProcess {
+				t.Errorf("Process called %d times, want %d", processed, tt.wantProcess)
+			}
+			if purged != 1 {
+				t.Errorf("Purge called %d times, want 1", purged)
+			}
+		})
+	}
+}
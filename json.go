@@ -21,7 +21,13 @@ func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload any) {
-	w.Header().Set("Content-Type", "application/json")
+	respondWithJSONType(w, code, "application/json", payload)
+}
+
+// respondWithJSONType writes JSON with a more specific media type, such as
+// application/activity+json.
+func respondWithJSONType(w http.ResponseWriter, code int, contentType string, payload any) {
+	w.Header().Set("Content-Type", contentType)
 	dat, err := json.Marshal(payload)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
@@ -31,3 +37,21 @@ func respondWithJSON(w http.ResponseWriter, code int, payload any) {
 	w.WriteHeader(code)
 	w.Write(dat)
 }
+
+// optional is a field of a JSON Merge Patch (RFC 7396) body. Set is false if
+// the field was left out, and Null is true if it was null, which removes
+// the value.
+type optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (o *optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
@@ -1,23 +1,63 @@
 package main
 
 import (
+	"chirpy/internal/activitypub"
+	"chirpy/internal/contentfilter"
+	"chirpy/internal/contentpolicy"
 	"chirpy/internal/database"
+	"chirpy/internal/events"
+	"chirpy/internal/media"
+	"chirpy/internal/notifications"
+	"chirpy/internal/polls"
+	"chirpy/internal/retention"
+	"chirpy/internal/scheduler"
+	"chirpy/internal/stream"
+	"chirpy/internal/timeline"
+	"chirpy/internal/trends"
+	"chirpy/internal/webhooks"
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
+const (
+	shutdownTimeout       = 10 * time.Second
+	eventDispatchInterval = time.Second
+)
+
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	db             *sql.DB
 	dbQueries      *database.Queries
-	platform       string
-	tokenSecret    string
-	polkaKey       string
+	timeline       timeline.Timeline
+	blobStore      media.BlobStore
+	contentFilter  *contentfilter.Filter
+	filterWords    []contentfilter.Word
+	contentPolicy  *contentpolicy.Pipeline
+	stream         *stream.Hub
+	notifier       *notifications.Notifier
+	webhookSender  *webhooks.Sender
+	federation     *activitypub.Federation
+	// serverCtx is cancelled on shutdown, ending long-lived connections
+	serverCtx   context.Context
+	sockets     sync.WaitGroup
+	platform    string
+	tokenSecret string
+	polkaKey    string
+	baseURL     string
 }
 
 func main() {
@@ -39,6 +79,68 @@ func main() {
 	if polkaKey == "" {
 		log.Fatal("POLKA_KEY environment variable is not set")
 	}
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	trendsConfig := trends.DefaultConfig()
+	if trendsWindows := os.Getenv("TRENDS_WINDOWS"); trendsWindows != "" {
+		windows, err := trends.ParseWindows(trendsWindows)
+		if err != nil {
+			log.Fatalf("Invalid TRENDS_WINDOWS: %v\n", err)
+		}
+		trendsConfig.Windows = windows
+	}
+	if trendsInterval := os.Getenv("TRENDS_INTERVAL"); trendsInterval != "" {
+		interval, err := time.ParseDuration(trendsInterval)
+		if err != nil || interval <= 0 {
+			log.Fatalf("Invalid TRENDS_INTERVAL: %v\n", err)
+		}
+		trendsConfig.Interval = interval
+	}
+	filterWords := []contentfilter.Word{}
+	if contentFilterFile := os.Getenv("CONTENT_FILTER_FILE"); contentFilterFile != "" {
+		file, err := os.Open(contentFilterFile)
+		if err != nil {
+			log.Fatalf("Error opening CONTENT_FILTER_FILE: %v\n", err)
+		}
+		filterWords, err = contentfilter.ParseWordList(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Error reading CONTENT_FILTER_FILE: %v\n", err)
+		}
+	}
+
+	chirpRetention := defaultChirpRetention
+	if retentionString := os.Getenv("CHIRP_RETENTION"); retentionString != "" {
+		period, err := time.ParseDuration(retentionString)
+		if err != nil || period < chirpRestoreWindow {
+			log.Fatalf("Invalid CHIRP_RETENTION, must be at least %v: %v\n", chirpRestoreWindow, err)
+		}
+		chirpRetention = period
+	}
+	// BASE_URL is where the server can be reached publicly, for links in
+	// feeds and the IDs of federated actors
+	baseURL := strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	} else if u, err := url.Parse(baseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		log.Fatalf("Invalid BASE_URL: %q\n", baseURL)
+	}
+	// Notification emails are only sent when there's a server to send them
+	// through
+	smtpAddr := os.Getenv("SMTP_ADDR")
+	mailFrom := os.Getenv("MAIL_FROM")
+	if smtpAddr != "" && mailFrom == "" {
+		log.Fatal("MAIL_FROM must be set when SMTP_ADDR is")
+	}
+	linkBlocklist := []string{}
+	if blockedDomains := os.Getenv("LINK_BLOCKLIST"); blockedDomains != "" {
+		linkBlocklist = strings.Split(blockedDomains, ",")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// DB setup
 	db, err := sql.Open("postgres", dbURL)
@@ -46,21 +148,84 @@ func main() {
 		log.Fatalf("Error opening database: %v\n", err)
 	}
 
+	// Media storage setup
+	blobStore, err := media.NewLocalStore(mediaDir)
+	if err != nil {
+		log.Fatalf("Error opening media directory: %v\n", err)
+	}
+
 	// HTTP Server setup
 	const filepathRoot = "."
 	const port = "8080"
 
 	apiCfg := apiConfig{
 		fileserverHits: atomic.Int32{},
+		db:             db,
 		dbQueries:      database.New(db),
 		platform:       platform,
 		tokenSecret:    tokenSecret,
 		polkaKey:       polkaKey,
+		baseURL:        baseURL,
+		blobStore:      blobStore,
+		contentFilter:  contentfilter.New(filterWords),
+		filterWords:    filterWords,
+		stream:         stream.NewHub(streamReplaySize, streamQueueSize),
+		serverCtx:      ctx,
 	}
+	apiCfg.timeline = timeline.NewFanOutOnRead(apiCfg.dbQueries)
+	apiCfg.federation = activitypub.New(baseURL, activitypub.NewDBStore(apiCfg.dbQueries))
+	notificationStore := notifications.NewDBStore(db, apiCfg.dbQueries)
+	notificationChannels := []notifications.Channel{notifications.NewInApp(notificationStore)}
+	if smtpAddr != "" {
+		mailer := notifications.NewSMTPMailer(smtpAddr, mailFrom, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+		notificationChannels = append(notificationChannels, notifications.NewEmail(mailer, notificationStore))
+	}
+	apiCfg.notifier = notifications.NewNotifier(notificationStore, notificationChannels...)
+
+	// Content filter setup
+	err = apiCfg.reloadContentFilter(ctx)
+	if err != nil {
+		log.Fatalf("Error loading content filter: %v\n", err)
+	}
+	apiCfg.contentPolicy = contentpolicy.NewPipeline(
+		contentpolicy.NewProfanity(apiCfg.contentFilter),
+		contentpolicy.NewLinkBlocklist(linkBlocklist),
+		contentpolicy.NewSpam(contentpolicy.DefaultSpamLimits()),
+		contentpolicy.NewDuplicates(contentpolicy.NewDBDuplicateStore(apiCfg.dbQueries), time.Hour),
+	)
+
+	// Background workers
+	trendsWorker := trends.NewWorker(trends.NewDBStore(db, apiCfg.dbQueries), trendsConfig)
+	go trendsWorker.Run(ctx)
+	purger := retention.NewPurger(retention.NewDBStore(apiCfg.dbQueries), chirpRetention, chirpPurgeInterval)
+	go purger.Run(ctx)
+	chirpScheduler := scheduler.New(&draftPublisher{cfg: &apiCfg}, chirpSchedulerInterval)
+	go chirpScheduler.Run(ctx)
+	pollCloser := polls.NewCloser(polls.NewDBStore(apiCfg.dbQueries), pollCloseInterval)
+	go pollCloser.Run(ctx)
+	eventDispatcher := events.NewDispatcher(events.NewDBStore(apiCfg.dbQueries), eventDispatchInterval)
+	events.Subscribe(eventDispatcher, "notifications", apiCfg.notifyChirpCreated)
+	events.Subscribe(eventDispatcher, "notifications", apiCfg.notifyChirpRechirped)
+	events.Subscribe(eventDispatcher, "notifications", apiCfg.notifyUserFollowed)
+	for _, eventType := range webhookEventTypes {
+		eventDispatcher.Subscribe(eventType, "webhooks", apiCfg.queueWebhookDeliveries)
+	}
+	events.Subscribe(eventDispatcher, "federation", apiCfg.federateChirpCreated)
+	events.Subscribe(eventDispatcher, "federation", apiCfg.federateChirpUpdated)
+	events.Subscribe(eventDispatcher, "federation", apiCfg.federateChirpDeleted)
+	events.Subscribe(eventDispatcher, "federation", apiCfg.federateChirpHidden)
+	events.Subscribe(eventDispatcher, "federation", apiCfg.federateChirpRestored)
+	go eventDispatcher.Run(ctx)
+	apiCfg.webhookSender = webhooks.NewSender(webhooks.NewDBStore(db, apiCfg.dbQueries), webhookSendInterval)
+	go apiCfg.webhookSender.Run(ctx)
+	federationDeliverer := activitypub.NewDeliverer(activitypub.NewDBStore(apiCfg.dbQueries), federationDeliveryInterval)
+	go federationDeliverer.Run(ctx)
 
 	// Endpoints
 	mux := http.NewServeMux()
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app/", http.FileServer(http.Dir(filepathRoot)))))
+	mux.HandleFunc("GET /media/{mediaID}", apiCfg.handlerGetMedia)
+	mux.HandleFunc("GET /media/{mediaID}/thumbnail", apiCfg.handlerGetMediaThumbnail)
 	mux.HandleFunc("GET /api/healthz", handlerReadiness)
 
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
@@ -69,24 +234,128 @@ func main() {
 
 	mux.HandleFunc("POST /api/users", apiCfg.handlerAddUser)
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
+	mux.HandleFunc("PATCH /api/users", apiCfg.handlerPatchUser)
+	mux.HandleFunc("GET /api/users/me", apiCfg.handlerGetMe)
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.handlerFollow)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.handlerUnfollow)
+	mux.HandleFunc("GET /api/users/{userID}", apiCfg.handlerGetUser)
+	mux.HandleFunc("GET /api/users/by-handle/{handle}", apiCfg.handlerGetUserByHandle)
+	mux.HandleFunc("GET /api/users/{userID}/{list}", apiCfg.handlerGetUserList)
+	mux.HandleFunc("POST /api/users/{userID}/block", apiCfg.handlerBlock)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", apiCfg.handlerUnblock)
+	mux.HandleFunc("POST /api/users/{userID}/mute", apiCfg.handlerMute)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", apiCfg.handlerUnmute)
+	mux.HandleFunc("GET /api/timeline", apiCfg.handlerGetTimeline)
+	mux.HandleFunc("GET /api/stream", apiCfg.handlerStream)
+	mux.HandleFunc("GET /api/ws", apiCfg.handlerWebSocket)
+	mux.HandleFunc("GET /api/notifications", apiCfg.handlerGetNotifications)
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.handlerMarkNotificationsRead)
+	mux.HandleFunc("GET /api/notifications/preferences", apiCfg.handlerGetNotificationPreferences)
+	mux.HandleFunc("PUT /api/notifications/preferences", apiCfg.handlerUpdateNotificationPreferences)
+	mux.HandleFunc("POST /api/webhooks", apiCfg.handlerAddWebhook)
+	mux.HandleFunc("GET /api/webhooks", apiCfg.handlerGetWebhooks)
+	mux.HandleFunc("PUT /api/webhooks/{webhookID}", apiCfg.handlerUpdateWebhook)
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", apiCfg.handlerDeleteWebhook)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", apiCfg.handlerGetWebhookDeliveries)
+	mux.HandleFunc("POST /api/webhooks/{webhookID}/test", apiCfg.handlerTestWebhook)
+	mux.HandleFunc("POST /api/conversations", apiCfg.handlerAddConversation)
+	mux.HandleFunc("GET /api/conversations", apiCfg.handlerGetConversations)
+	mux.HandleFunc("GET /api/conversations/unread", apiCfg.handlerGetUnreadCount)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", apiCfg.handlerGetMessages)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", apiCfg.handlerSendMessage)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", apiCfg.handlerMarkConversationRead)
 
 	mux.HandleFunc("POST /api/chirps", apiCfg.handlerAddChirp)
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirp)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.handlerUpdateChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.handlerRestoreChirp)
+	mux.HandleFunc("POST /api/media", apiCfg.handlerUploadMedia)
+	mux.HandleFunc("POST /api/drafts", apiCfg.handlerAddDraft)
+	mux.HandleFunc("GET /api/drafts", apiCfg.handlerGetDrafts)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apiCfg.handlerUpdateDraft)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apiCfg.handlerDeleteDraft)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", apiCfg.handlerAddRechirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", apiCfg.handlerDeleteRechirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", apiCfg.handlerReportChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/vote", apiCfg.handlerVotePoll)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.handlerAddBookmark)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}/bookmark", apiCfg.handlerUpdateBookmark)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.handlerDeleteBookmark)
+	mux.HandleFunc("GET /api/users/me/bookmarks", apiCfg.handlerGetBookmarks)
+	mux.HandleFunc("GET /api/users/me/collections", apiCfg.handlerGetCollections)
+	mux.HandleFunc("POST /api/users/me/collections", apiCfg.handlerAddCollection)
+	mux.HandleFunc("PUT /api/users/me/collections/{collectionID}", apiCfg.handlerUpdateCollection)
+	mux.HandleFunc("DELETE /api/users/me/collections/{collectionID}", apiCfg.handlerDeleteCollection)
 
 	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	mux.HandleFunc("GET /admin/filter/words", apiCfg.handlerGetFilterWords)
+	mux.HandleFunc("POST /admin/filter/words", apiCfg.handlerAddFilterWord)
+	mux.HandleFunc("PUT /admin/filter/words/{wordID}", apiCfg.handlerUpdateFilterWord)
+	mux.HandleFunc("DELETE /admin/filter/words/{wordID}", apiCfg.handlerDeleteFilterWord)
+	mux.HandleFunc("GET /admin/reports", apiCfg.handlerGetReports)
+	mux.HandleFunc("POST /admin/reports/{reportID}/actions", apiCfg.handlerModerateReport)
+	mux.HandleFunc("GET /admin/moderation/actions", apiCfg.handlerGetModerationActions)
+	mux.HandleFunc("PUT /admin/users/{userID}/suspension", apiCfg.handlerSuspendUser)
+	mux.HandleFunc("DELETE /admin/users/{userID}/suspension", apiCfg.handlerLiftSuspension)
+
+	mux.HandleFunc("GET /api/tags/{tag}/chirps", apiCfg.handlerGetTagChirps)
+	mux.HandleFunc("GET /api/users/me/mentions", apiCfg.handlerGetMentions)
+	mux.HandleFunc("GET /api/trends", apiCfg.handlerGetTrends)
+
+	mux.HandleFunc("GET /feed.rss", apiCfg.handlerGetFeedRSS)
+	mux.HandleFunc("GET /feed.atom", apiCfg.handlerGetFeedAtom)
+	mux.HandleFunc("GET /users/{userID}/feed.rss", apiCfg.handlerGetUserFeedRSS)
+	mux.HandleFunc("GET /users/{userID}/feed.atom", apiCfg.handlerGetUserFeedAtom)
+	mux.HandleFunc("GET /tags/{tag}/feed.rss", apiCfg.handlerGetTagFeedRSS)
+	mux.HandleFunc("GET /tags/{tag}/feed.atom", apiCfg.handlerGetTagFeedAtom)
+
+	mux.HandleFunc("GET /.well-known/webfinger", apiCfg.handlerWebFinger)
+	mux.HandleFunc("GET /users/{userID}", apiCfg.handlerGetActor)
+	mux.HandleFunc("POST /users/{userID}/inbox", apiCfg.handlerInbox)
+	mux.HandleFunc("GET /users/{userID}/outbox", apiCfg.handlerGetOutbox)
+	mux.HandleFunc("GET /users/{userID}/followers", apiCfg.handlerGetFollowersCollection)
+	mux.HandleFunc("GET /users/{userID}/chirps/{chirpID}", apiCfg.handlerGetNote)
 
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhooks)
 
 	srv := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: apiCfg.middlewareRejectSuspended(mux),
 	}
 
-	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(srv.ListenAndServe())
+	go func() {
+		log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
+		err := srv.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down")
+
+	// Shutdown doesn't wait for hijacked WebSocket connections, which close
+	// themselves once ctx is done
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("Error shutting down server: %v\n", err)
+	}
+
+	socketsClosed := make(chan struct{})
+	go func() {
+		apiCfg.sockets.Wait()
+		close(socketsClosed)
+	}()
+	select {
+	case <-socketsClosed:
+	case <-shutdownCtx.Done():
+		log.Println("Timed out waiting for WebSocket connections to close")
+	}
 }
 
 func handlerReadiness(resp http.ResponseWriter, req *http.Request) {
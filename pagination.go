@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// parsePage reads the limit and offset query parameters of a paginated list.
+func parsePage(req *http.Request) (limit, offset int32, err error) {
+	limit, err = parseLimit(req)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if offsetString := req.URL.Query().Get("offset"); len(offsetString) > 0 {
+		value, err := strconv.Atoi(offsetString)
+		if err != nil || value < 0 {
+			return 0, 0, errors.New("invalid offset")
+		}
+		offset = int32(value)
+	}
+
+	return limit, offset, nil
+}
+
+// parseLimit reads the limit query parameter of a paginated list. Lists
+// paginated with a cursor use it on its own.
+func parseLimit(req *http.Request) (int32, error) {
+	limitString := req.URL.Query().Get("limit")
+	if len(limitString) == 0 {
+		return defaultPageLimit, nil
+	}
+
+	value, err := strconv.Atoi(limitString)
+	if err != nil || value < 1 || value > maxPageLimit {
+		return 0, errors.New("invalid limit")
+	}
+	return int32(value), nil
+}
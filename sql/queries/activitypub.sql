@@ -0,0 +1,105 @@
+-- name: GetActorKey :one
+SELECT * FROM actor_keys WHERE user_id = $1;
+
+-- name: GetActorKeysByUserIDs :many
+SELECT * FROM actor_keys WHERE user_id = ANY(sqlc.arg(user_ids)::uuid[]);
+
+-- name: CreateActorKey :exec
+INSERT INTO actor_keys (user_id, created_at, public_key, private_key)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3
+)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetRemoteActor :one
+SELECT * FROM remote_actors WHERE id = $1;
+
+-- name: UpsertRemoteActor :exec
+INSERT INTO remote_actors (id, inbox, shared_inbox, preferred_username, key_id, public_key, fetched_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (id) DO UPDATE
+SET inbox = EXCLUDED.inbox, shared_inbox = EXCLUDED.shared_inbox, preferred_username = EXCLUDED.preferred_username,
+    key_id = EXCLUDED.key_id, public_key = EXCLUDED.public_key, fetched_at = EXCLUDED.fetched_at;
+
+-- name: DeleteRemoteActor :exec
+DELETE FROM remote_actors WHERE id = $1;
+
+-- name: UpsertRemoteFollower :exec
+INSERT INTO remote_followers (user_id, actor_id, follow_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (user_id, actor_id) DO UPDATE SET follow_id = EXCLUDED.follow_id;
+
+-- name: DeleteRemoteFollower :exec
+DELETE FROM remote_followers WHERE user_id = $1 AND actor_id = $2;
+
+-- name: DeleteRemoteFollowByID :exec
+DELETE FROM remote_followers WHERE actor_id = $1 AND follow_id = $2;
+
+-- name: CountRemoteFollowers :one
+SELECT COUNT(*) FROM remote_followers WHERE user_id = $1;
+
+-- name: GetRemoteFollowerInboxes :many
+SELECT remote_actors.inbox, remote_actors.shared_inbox FROM remote_followers
+JOIN remote_actors ON remote_actors.id = remote_followers.actor_id
+WHERE remote_followers.user_id = $1;
+
+-- name: CreateFederationDelivery :exec
+INSERT INTO federation_deliveries (id, created_at, user_id, key_id, inbox, activity_id, activity, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW()
+)
+ON CONFLICT (activity_id, inbox) DO NOTHING;
+
+-- name: ClaimFederationDeliveries :many
+UPDATE federation_deliveries
+SET attempts = attempts + 1, next_attempt_at = sqlc.arg(lease_until)::timestamp
+WHERE id IN (
+    SELECT id FROM federation_deliveries
+    WHERE next_attempt_at <= sqlc.arg(now)::timestamp
+    ORDER BY next_attempt_at ASC, created_at ASC
+    LIMIT sqlc.arg(limit)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkFederationDeliveryDelivered :exec
+UPDATE federation_deliveries
+SET delivered_at = sqlc.arg(delivered_at)::timestamp, next_attempt_at = NULL, last_error = ''
+WHERE id = sqlc.arg(id);
+
+-- name: RetryFederationDelivery :exec
+UPDATE federation_deliveries
+SET next_attempt_at = sqlc.arg(next_attempt_at)::timestamp, last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id);
+
+-- name: FailFederationDelivery :exec
+UPDATE federation_deliveries
+SET failed_at = sqlc.arg(failed_at)::timestamp, next_attempt_at = NULL, last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id);
+
+-- name: DeleteFinishedFederationDeliveries :execrows
+DELETE FROM federation_deliveries
+WHERE delivered_at < sqlc.arg(before)::timestamp OR failed_at < sqlc.arg(before)::timestamp;
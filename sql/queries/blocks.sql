@@ -0,0 +1,39 @@
+-- name: CreateBlock :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: IsBlocked :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = $2
+);
+
+-- name: IsBlockedAmong :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocker_id = ANY(sqlc.arg(user_ids)::uuid[]) AND blocked_id = ANY(sqlc.arg(user_ids)::uuid[])
+);
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = sqlc.arg(user_a) AND followee_id = sqlc.arg(user_b))
+    OR (follower_id = sqlc.arg(user_b) AND followee_id = sqlc.arg(user_a));
+
+-- name: CreateMute :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteMute :execrows
+DELETE FROM mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetBlockerIDs :many
+SELECT blocker_id FROM blocks WHERE blocked_id = $1;
+
+-- name: GetMutedIDs :many
+SELECT muted_id FROM mutes WHERE muter_id = $1;
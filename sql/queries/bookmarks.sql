@@ -0,0 +1,64 @@
+-- name: CreateBookmark :one
+INSERT INTO bookmarks (id, created_at, user_id, chirp_id, collection_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: UpdateBookmarkCollection :one
+UPDATE bookmarks
+SET collection_id = $3
+WHERE user_id = $1 AND chirp_id = $2
+RETURNING *;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetBookmarks :many
+SELECT * FROM bookmarks
+WHERE user_id = $1 AND (
+    sqlc.narg(collection_id)::uuid IS NULL OR collection_id = sqlc.narg(collection_id)::uuid
+)
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountBookmarks :one
+SELECT COUNT(*) FROM bookmarks
+WHERE user_id = $1 AND (
+    sqlc.narg(collection_id)::uuid IS NULL OR collection_id = sqlc.narg(collection_id)::uuid
+);
+
+-- name: CreateCollection :one
+INSERT INTO collections (id, created_at, updated_at, user_id, name)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: GetCollection :one
+SELECT * FROM collections WHERE id = $1;
+
+-- name: GetCollectionsByUser :many
+SELECT collections.*, COUNT(bookmarks.id) AS bookmark_count FROM collections
+LEFT JOIN bookmarks ON bookmarks.collection_id = collections.id
+WHERE collections.user_id = $1
+GROUP BY collections.id
+ORDER BY collections.name ASC;
+
+-- name: RenameCollection :one
+UPDATE collections
+SET name = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteCollection :exec
+DELETE FROM collections WHERE id = $1;
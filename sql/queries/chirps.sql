@@ -1,25 +1,148 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quote_of, visibility)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
 RETURNING *;
 
 -- name: GetChirps :many
 SELECT * FROM chirps
+WHERE deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id)::uuid
+) AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = sqlc.arg(viewer_id)::uuid AND mutes.muted_id = chirps.user_id
+) AND (
+    chirps.hidden_at IS NULL OR chirps.user_id = sqlc.arg(viewer_id)::uuid OR sqlc.arg(viewer_is_moderator)::boolean
+) AND (
+    chirps.visibility = 'public' OR chirps.user_id = sqlc.arg(viewer_id)::uuid OR (
+        chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(viewer_id)::uuid AND follows.followee_id = chirps.user_id
+        )
+    )
+)
 ORDER BY created_at ASC;
 
 -- name: GetChirpsByUser :many
 SELECT * FROM chirps
-WHERE user_id = $1
+WHERE user_id = $1 AND deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id)::uuid
+) AND (
+    chirps.hidden_at IS NULL OR chirps.user_id = sqlc.arg(viewer_id)::uuid OR sqlc.arg(viewer_is_moderator)::boolean
+) AND (
+    chirps.visibility = 'public' OR chirps.user_id = sqlc.arg(viewer_id)::uuid OR (
+        chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(viewer_id)::uuid AND follows.followee_id = chirps.user_id
+        )
+    )
+)
 ORDER BY created_at ASC;
 
+-- name: GetPublicChirps :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL AND hidden_at IS NULL AND visibility = 'public'
+ORDER BY created_at DESC
+LIMIT $1;
+
+-- name: GetPublicChirpsByUser :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL AND hidden_at IS NULL AND visibility = 'public'
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountPublicChirpsByUser :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND deleted_at IS NULL AND hidden_at IS NULL AND visibility = 'public';
+
 -- name: GetChirp :one
+SELECT * FROM chirps WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetChirpIncludingDeleted :one
 SELECT * FROM chirps WHERE id = $1;
 
+-- name: GetChirpForViewer :one
+SELECT * FROM chirps
+WHERE id = $1 AND deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id)::uuid
+) AND (
+    chirps.hidden_at IS NULL OR chirps.user_id = sqlc.arg(viewer_id)::uuid OR sqlc.arg(viewer_is_moderator)::boolean
+) AND (
+    chirps.visibility = 'public' OR chirps.user_id = sqlc.arg(viewer_id)::uuid OR (
+        chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(viewer_id)::uuid AND follows.followee_id = chirps.user_id
+        )
+    )
+);
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[]) AND deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id)::uuid
+) AND (
+    chirps.hidden_at IS NULL OR chirps.user_id = sqlc.arg(viewer_id)::uuid
+) AND (
+    chirps.visibility = 'public' OR chirps.user_id = sqlc.arg(viewer_id)::uuid OR (
+        chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(viewer_id)::uuid AND follows.followee_id = chirps.user_id
+        )
+    )
+);
+
 -- name: DeleteChirp :exec
-DELETE FROM chirps WHERE id = $1;
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1 AND deleted_at > sqlc.arg(deleted_after)::timestamp
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps WHERE deleted_at < sqlc.arg(deleted_before)::timestamp;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetRecentChirpBodies :many
+SELECT body FROM chirps
+WHERE user_id = $1 AND id <> sqlc.arg(exclude_id)::uuid AND created_at > sqlc.arg(since)::timestamp AND deleted_at IS NULL;
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1 AND hidden_at IS NULL;
+
+-- name: IsChirpVisibleTo :one
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = sqlc.arg(chirp_id)::uuid
+        AND chirps.hidden_at IS NULL
+        AND chirps.deleted_at IS NULL
+        AND (
+            chirps.user_id = sqlc.arg(viewer_id)::uuid OR chirps.visibility = 'public' OR (
+                chirps.visibility = 'followers' AND EXISTS (
+                    SELECT 1 FROM follows
+                    WHERE follows.follower_id = sqlc.arg(viewer_id)::uuid AND follows.followee_id = chirps.user_id
+                )
+            )
+        )
+);
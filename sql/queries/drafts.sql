@@ -0,0 +1,50 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, user_id, body, quote_of, media_ids, publish_at, status, visibility)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetDraft :one
+SELECT * FROM drafts WHERE id = $1;
+
+-- name: GetDraftsByUser :many
+SELECT * FROM drafts
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = $2, quote_of = $3, media_ids = $4, publish_at = $5, status = $6, visibility = $7, error = '', attempts = 0, retry_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteDraft :exec
+DELETE FROM drafts WHERE id = $1;
+
+-- name: ClaimDueDraft :one
+SELECT * FROM drafts
+WHERE status = 'scheduled' AND publish_at <= sqlc.arg(now)::timestamp
+AND (retry_at IS NULL OR retry_at <= sqlc.arg(now)::timestamp)
+ORDER BY publish_at ASC
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: FailDraft :exec
+UPDATE drafts
+SET status = 'failed', error = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetryDraft :exec
+UPDATE drafts
+SET attempts = attempts + 1, retry_at = $3
+WHERE id = $1 AND status = 'scheduled' AND attempts = $2;
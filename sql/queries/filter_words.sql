@@ -0,0 +1,22 @@
+-- name: CreateFilterWord :one
+INSERT INTO filter_words (id, created_at, updated_at, word, action)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: GetFilterWords :many
+SELECT * FROM filter_words ORDER BY word ASC;
+
+-- name: UpdateFilterWord :one
+UPDATE filter_words
+SET action = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteFilterWord :execrows
+DELETE FROM filter_words WHERE id = $1;
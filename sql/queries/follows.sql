@@ -0,0 +1,31 @@
+-- name: CreateFollow :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetFollowers :many
+SELECT users.* FROM users
+JOIN follows ON follows.follower_id = users.id
+WHERE follows.followee_id = $1
+ORDER BY follows.created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: GetFollowing :many
+SELECT users.* FROM users
+JOIN follows ON follows.followee_id = users.id
+WHERE follows.follower_id = $1
+ORDER BY follows.created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountFollowers :one
+SELECT COUNT(*) FROM follows WHERE followee_id = $1;
+
+-- name: CountFollowing :one
+SELECT COUNT(*) FROM follows WHERE follower_id = $1;
+
+-- name: GetFolloweeIDs :many
+SELECT followee_id FROM follows WHERE follower_id = $1;
@@ -0,0 +1,30 @@
+-- name: CreateMediaFile :one
+INSERT INTO media_files (id, created_at, user_id, content_type, size_bytes, width, height, storage_key, thumbnail_key)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: GetMediaFile :one
+SELECT * FROM media_files WHERE id = $1;
+
+-- name: GetMediaFilesByIDs :many
+SELECT * FROM media_files WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: CreateChirpAttachment :exec
+INSERT INTO chirp_attachments (chirp_id, media_id, position)
+VALUES ($1, $2, $3);
+
+-- name: GetChirpAttachments :many
+SELECT chirp_attachments.chirp_id, media_files.* FROM chirp_attachments
+JOIN media_files ON media_files.id = chirp_attachments.media_id
+WHERE chirp_attachments.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_attachments.position ASC;
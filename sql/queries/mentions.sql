@@ -0,0 +1,30 @@
+-- name: CreateChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetChirpsMentioningUser :many
+SELECT chirps.* FROM chirps
+JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE chirp_mentions.user_id = $1 AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1)
+        OR (blocks.blocker_id = $1 AND blocks.blocked_id = chirps.user_id)
+) AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = $1 AND mutes.muted_id = chirps.user_id
+) AND chirps.hidden_at IS NULL AND chirps.deleted_at IS NULL AND (
+    chirps.visibility = 'public' OR (
+        chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $1 AND follows.followee_id = chirps.user_id
+        )
+    )
+)
+ORDER BY chirps.created_at DESC;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions WHERE chirp_id = $1;
+
+-- name: GetMentionedUserIDs :many
+SELECT user_id FROM chirp_mentions WHERE chirp_id = $1;
@@ -0,0 +1,110 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by, is_group)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW());
+
+-- name: CreateDirectConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by, is_group, user_low, user_high)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    false,
+    $2,
+    $3
+)
+ON CONFLICT (user_low, user_high) DO NOTHING
+RETURNING *;
+
+-- name: GetDirectConversation :one
+SELECT * FROM conversations
+WHERE user_low = sqlc.arg(user_low)::uuid AND user_high = sqlc.arg(user_high)::uuid;
+
+-- name: GetConversationForParticipant :one
+SELECT conversations.* FROM conversations
+JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id
+WHERE conversations.id = $1 AND conversation_participants.user_id = $2;
+
+-- name: GetConversationsForParticipant :many
+SELECT conversations.*, COUNT(messages.id) AS unread_count FROM conversations
+JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id
+LEFT JOIN messages ON messages.conversation_id = conversations.id
+    AND messages.sender_id <> conversation_participants.user_id
+    AND (conversation_participants.last_read_at IS NULL OR messages.created_at > conversation_participants.last_read_at)
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE blocks.blocker_id = conversation_participants.user_id AND blocks.blocked_id = messages.sender_id
+    )
+WHERE conversation_participants.user_id = $1
+GROUP BY conversations.id
+ORDER BY conversations.updated_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: GetConversationParticipants :many
+SELECT conversation_participants.conversation_id, users.* FROM users
+JOIN conversation_participants ON conversation_participants.user_id = users.id
+WHERE conversation_participants.conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY conversation_participants.joined_at ASC;
+
+-- name: IsBlockedByParticipant :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    JOIN conversation_participants ON conversation_participants.user_id = blocks.blocker_id
+    WHERE conversation_participants.conversation_id = $1 AND blocks.blocked_id = $2
+);
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = NOW() WHERE id = $1;
+
+-- name: GetMessage :one
+SELECT * FROM messages WHERE id = $1;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id) AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = sqlc.arg(viewer_id)::uuid AND blocks.blocked_id = messages.sender_id
+) AND (
+    sqlc.narg(before_created_at)::timestamp IS NULL
+    OR (messages.created_at, messages.id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid)
+)
+ORDER BY messages.created_at DESC, messages.id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_participants
+SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: CountUnreadMessages :one
+SELECT COUNT(*) FROM messages
+JOIN conversation_participants ON conversation_participants.conversation_id = messages.conversation_id
+WHERE conversation_participants.user_id = $1
+    AND messages.sender_id <> $1
+    AND (conversation_participants.last_read_at IS NULL OR messages.created_at > conversation_participants.last_read_at)
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE blocks.blocker_id = $1 AND blocks.blocked_id = messages.sender_id
+    );
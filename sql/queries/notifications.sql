@@ -0,0 +1,86 @@
+-- name: HasNotified :one
+SELECT EXISTS (
+    SELECT 1 FROM notification_actors
+    JOIN notifications ON notifications.id = notification_actors.notification_id
+    WHERE notifications.user_id = $1
+        AND notifications.group_key = $2
+        AND notification_actors.actor_id = $3
+);
+
+-- name: UpsertUnreadNotification :one
+INSERT INTO notifications (id, created_at, updated_at, user_id, type, chirp_id, group_key)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+DO UPDATE SET updated_at = NOW()
+RETURNING *;
+
+-- name: CreateNotificationActor :exec
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: GetNotifications :many
+SELECT notifications.*, COUNT(notification_actors.actor_id) AS actor_count FROM notifications
+JOIN notification_actors ON notification_actors.notification_id = notifications.id
+WHERE notifications.user_id = $1 AND NOT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = notifications.chirp_id AND chirps.deleted_at IS NOT NULL
+)
+GROUP BY notifications.id
+ORDER BY notifications.updated_at DESC, notifications.id DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND NOT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = notifications.chirp_id AND chirps.deleted_at IS NOT NULL
+);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = notifications.chirp_id AND chirps.deleted_at IS NOT NULL
+);
+
+-- name: GetNotificationActors :many
+SELECT notification_actors.notification_id, sqlc.embed(users) FROM notification_actors
+JOIN users ON users.id = notification_actors.actor_id
+WHERE notification_actors.notification_id = ANY(sqlc.arg(notification_ids)::uuid[])
+ORDER BY notification_actors.created_at DESC;
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL AND id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences WHERE user_id = $1;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, channel, enabled)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, type, channel) DO UPDATE SET enabled = EXCLUDED.enabled;
+
+-- name: IsIgnoring :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocker_id = sqlc.arg(user_id)::uuid AND blocked_id = sqlc.arg(actor_id)::uuid
+    UNION ALL
+    SELECT 1 FROM mutes
+    WHERE muter_id = sqlc.arg(user_id)::uuid AND muted_id = sqlc.arg(actor_id)::uuid
+);
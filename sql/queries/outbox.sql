@@ -0,0 +1,40 @@
+-- name: CreateOutboxEvent :exec
+INSERT INTO outbox_events (id, created_at, type, payload, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    NOW()
+);
+
+-- name: ClaimOutboxEvents :many
+UPDATE outbox_events
+SET attempts = attempts + 1, next_attempt_at = sqlc.arg(lease_until)::timestamp
+WHERE id IN (
+    SELECT id FROM outbox_events
+    WHERE next_attempt_at <= sqlc.arg(now)::timestamp
+    ORDER BY next_attempt_at ASC, created_at ASC
+    LIMIT sqlc.arg(limit)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkOutboxEventDelivered :exec
+UPDATE outbox_events
+SET delivered_at = sqlc.arg(delivered_at)::timestamp, next_attempt_at = NULL, last_error = ''
+WHERE id = sqlc.arg(id);
+
+-- name: RetryOutboxEvent :exec
+UPDATE outbox_events
+SET next_attempt_at = sqlc.arg(next_attempt_at)::timestamp, last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id);
+
+-- name: FailOutboxEvent :exec
+UPDATE outbox_events
+SET failed_at = sqlc.arg(failed_at)::timestamp, next_attempt_at = NULL, last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id);
+
+-- name: DeleteDeliveredOutboxEvents :execrows
+DELETE FROM outbox_events
+WHERE delivered_at < sqlc.arg(before)::timestamp;
@@ -0,0 +1,45 @@
+-- name: CreatePoll :one
+INSERT INTO polls (id, created_at, chirp_id, closes_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: CreatePollOption :one
+INSERT INTO poll_options (id, poll_id, position, text)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetPollByChirp :one
+SELECT * FROM polls WHERE chirp_id = $1;
+
+-- name: GetPollsByChirpIDs :many
+SELECT * FROM polls WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetPollOptionResults :many
+SELECT poll_options.*, COUNT(poll_votes.user_id) AS votes FROM poll_options
+LEFT JOIN poll_votes ON poll_votes.option_id = poll_options.id
+WHERE poll_options.poll_id = ANY(sqlc.arg(poll_ids)::uuid[])
+GROUP BY poll_options.id
+ORDER BY poll_options.poll_id, poll_options.position ASC;
+
+-- name: GetPollVotesByUser :many
+SELECT * FROM poll_votes
+WHERE user_id = $1 AND poll_id = ANY(sqlc.arg(poll_ids)::uuid[]);
+
+-- name: CreatePollVote :exec
+INSERT INTO poll_votes (poll_id, user_id, option_id, created_at)
+VALUES ($1, $2, $3, NOW());
+
+-- name: ClosePollsDue :execrows
+UPDATE polls
+SET closed_at = sqlc.arg(now)::timestamp
+WHERE closed_at IS NULL AND closes_at <= sqlc.arg(now)::timestamp;
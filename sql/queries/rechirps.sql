@@ -0,0 +1,19 @@
+-- name: CreateRechirp :one
+INSERT INTO rechirps (id, created_at, user_id, chirp_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: DeleteRechirp :execrows
+DELETE FROM rechirps
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetRechirpCounts :many
+SELECT chirp_id, COUNT(*) AS rechirp_count, BOOL_OR(user_id = sqlc.arg(viewer_id)::uuid)::boolean AS rechirped
+FROM rechirps
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+GROUP BY chirp_id;
@@ -0,0 +1,35 @@
+-- name: CreateChirpTag :exec
+INSERT INTO chirp_tags (chirp_id, tag)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetChirpsByTag :many
+SELECT chirps.* FROM chirps
+JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+WHERE chirp_tags.tag = $1 AND chirps.deleted_at IS NULL AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.arg(viewer_id)::uuid
+) AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = sqlc.arg(viewer_id)::uuid AND mutes.muted_id = chirps.user_id
+) AND (
+    chirps.hidden_at IS NULL OR chirps.user_id = sqlc.arg(viewer_id)::uuid
+) AND (
+    chirps.visibility = 'public' OR chirps.user_id = sqlc.arg(viewer_id)::uuid OR (
+        chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(viewer_id)::uuid AND follows.followee_id = chirps.user_id
+        )
+    )
+)
+ORDER BY chirps.created_at DESC;
+
+-- name: GetPublicChirpsByTag :many
+SELECT chirps.* FROM chirps
+JOIN chirp_tags ON chirp_tags.chirp_id = chirps.id
+WHERE chirp_tags.tag = $1 AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL AND chirps.visibility = 'public'
+ORDER BY chirps.created_at DESC
+LIMIT $2;
+
+-- name: DeleteChirpTags :exec
+DELETE FROM chirp_tags WHERE chirp_id = $1;
@@ -0,0 +1,24 @@
+-- name: GetHomeTimeline :many
+SELECT sqlc.embed(chirps), shares.user_id AS rechirped_by, shares.created_at AS rechirped_at
+FROM (
+    SELECT chirps.id AS chirp_id, NULL::uuid AS rechirp_id, chirps.created_at AS posted_at
+    FROM chirps
+    WHERE chirps.user_id = $1
+    OR chirps.user_id IN (SELECT followee_id FROM follows WHERE follower_id = $1)
+    UNION ALL
+    SELECT rechirps.chirp_id, rechirps.id, rechirps.created_at
+    FROM rechirps
+    WHERE (
+        rechirps.user_id = $1
+        OR rechirps.user_id IN (SELECT followee_id FROM follows WHERE follower_id = $1)
+    ) AND rechirps.user_id NOT IN (SELECT muted_id FROM mutes WHERE muter_id = $1)
+) entries
+JOIN chirps ON chirps.id = entries.chirp_id
+LEFT JOIN rechirps shares ON shares.id = entries.rechirp_id
+WHERE chirps.user_id NOT IN (SELECT muted_id FROM mutes WHERE muter_id = $1)
+AND chirps.user_id NOT IN (SELECT blocker_id FROM blocks WHERE blocked_id = $1)
+AND (chirps.hidden_at IS NULL OR chirps.user_id = $1) AND chirps.deleted_at IS NULL
+AND (chirps.visibility <> 'private' OR chirps.user_id = $1)
+AND (shares.id IS NULL OR chirps.visibility = 'public' OR chirps.user_id = $1)
+ORDER BY entries.posted_at DESC
+LIMIT $2 OFFSET $3;
@@ -0,0 +1,17 @@
+-- name: GetTagUsagesSince :many
+SELECT chirp_tags.tag, chirps.created_at FROM chirp_tags
+JOIN chirps ON chirps.id = chirp_tags.chirp_id
+WHERE chirps.created_at > $1 AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND chirps.visibility = 'public';
+
+-- name: DeleteTrendingTags :exec
+DELETE FROM trending_tags WHERE window_name = $1;
+
+-- name: CreateTrendingTag :exec
+INSERT INTO trending_tags (window_name, tag, rank, score, recent_count, baseline_count, computed_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: GetTrendingTags :many
+SELECT * FROM trending_tags
+WHERE window_name = $1
+ORDER BY rank ASC;
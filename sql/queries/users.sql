@@ -1,27 +1,45 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
 -- name: GetUser :one
 SELECT * FROM users WHERE id = $1;
 
+-- name: GetUsersByIDs :many
+SELECT * FROM users WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: GetUserByHandle :one
+SELECT * FROM users WHERE LOWER(handle) = LOWER(sqlc.arg(handle)::text);
+
 -- name: GetUserByEmail :one
 SELECT * FROM users WHERE email = $1;
 
+-- name: GetMentionableUsers :many
+SELECT * FROM users
+WHERE LOWER(handle) = ANY(sqlc.arg(handles)::text[]) AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE blocks.blocker_id = users.id AND blocks.blocked_id = sqlc.arg(author_id)::uuid
+);
+
 -- name: DeleteUsers :exec
 DELETE FROM users;
 
--- name: UpdateUser :one
+-- name: PatchUser :one
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
-WHERE id = $1
+SET email = COALESCE(sqlc.narg(email)::text, email), hashed_password = COALESCE(sqlc.narg(hashed_password)::text, hashed_password),
+    handle = COALESCE(sqlc.narg(handle)::text, handle), default_visibility = COALESCE(sqlc.narg(default_visibility)::text, default_visibility),
+    display_name = COALESCE(sqlc.narg(display_name)::text, display_name), bio = COALESCE(sqlc.narg(bio)::text, bio),
+    avatar_id = CASE WHEN sqlc.arg(set_avatar)::boolean THEN sqlc.narg(avatar_id)::uuid ELSE avatar_id END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)::uuid AND (sqlc.narg(if_updated_at)::timestamp IS NULL OR updated_at = sqlc.narg(if_updated_at)::timestamp)
 RETURNING *;
 
 -- name: UpdateUserToChirpyRed :one
@@ -29,3 +47,21 @@ UPDATE users
 SET is_chirpy_red = true, updated_at = NOW()
 WHERE id = $1
 RETURNING *;
+
+-- name: SuspendUser :one
+UPDATE users
+SET suspended_until = $2, suspension_reason = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: BanUser :one
+UPDATE users
+SET banned_at = NOW(), suspension_reason = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: LiftSuspension :one
+UPDATE users
+SET suspended_until = NULL, banned_at = NULL, suspension_reason = '', updated_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,144 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, event_types)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks WHERE id = $1;
+
+-- name: GetWebhooksByUser :many
+SELECT * FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: CountWebhooksByUser :one
+SELECT COUNT(*) FROM webhooks WHERE user_id = $1;
+
+-- name: GetWebhooksByIDs :many
+SELECT * FROM webhooks WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: GetSubscribedWebhooks :many
+SELECT * FROM webhooks
+WHERE user_id = $1 AND sqlc.arg(event_type)::text = ANY(event_types) AND disabled_at IS NULL;
+
+-- name: UpdateWebhook :one
+UPDATE webhooks
+SET url = $2, event_types = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: EnableWebhook :one
+UPDATE webhooks
+SET disabled_at = NULL, consecutive_failures = 0, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DisableWebhook :exec
+UPDATE webhooks
+SET disabled_at = sqlc.arg(disabled_at)::timestamp, updated_at = NOW()
+WHERE id = sqlc.arg(id) AND disabled_at IS NULL;
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhooks WHERE id = $1;
+
+-- name: ResetWebhookFailures :exec
+UPDATE webhooks
+SET consecutive_failures = 0
+WHERE id = $1;
+
+-- name: IncrementWebhookFailures :one
+UPDATE webhooks
+SET consecutive_failures = consecutive_failures + 1
+WHERE id = $1
+RETURNING consecutive_failures;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, event_id, event_type, payload, status, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    'pending',
+    NOW()
+)
+ON CONFLICT (webhook_id, event_id) DO NOTHING;
+
+-- name: CreateWebhookTestDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, event_type, payload, status, attempts)
+VALUES (
+    sqlc.arg(id),
+    NOW(),
+    sqlc.arg(webhook_id),
+    sqlc.arg(event_type),
+    sqlc.arg(payload),
+    'pending',
+    1
+)
+RETURNING *;
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET attempts = attempts + 1, next_attempt_at = sqlc.arg(lease_until)::timestamp
+WHERE id IN (
+    SELECT webhook_deliveries.id FROM webhook_deliveries
+    JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+    WHERE webhook_deliveries.next_attempt_at <= sqlc.arg(now)::timestamp
+        AND webhooks.disabled_at IS NULL
+    ORDER BY webhook_deliveries.next_attempt_at ASC
+    LIMIT sqlc.arg(limit)
+    FOR UPDATE OF webhook_deliveries SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookDeliverySucceeded :one
+UPDATE webhook_deliveries
+SET status = 'succeeded',
+    next_attempt_at = NULL,
+    last_attempt_at = sqlc.arg(attempted_at)::timestamp,
+    response_status = sqlc.narg(response_status),
+    last_error = ''
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: RetryWebhookDelivery :one
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(next_attempt_at)::timestamp,
+    last_attempt_at = sqlc.arg(attempted_at)::timestamp,
+    response_status = sqlc.narg(response_status),
+    last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: FailWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'failed',
+    next_attempt_at = NULL,
+    last_attempt_at = sqlc.arg(attempted_at)::timestamp,
+    response_status = sqlc.narg(response_status),
+    last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountWebhookDeliveries :one
+SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1;
+
+-- name: DeleteWebhookDeliveriesBefore :execrows
+DELETE FROM webhook_deliveries
+WHERE created_at < sqlc.arg(before)::timestamp AND next_attempt_at IS NULL;
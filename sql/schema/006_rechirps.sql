@@ -0,0 +1,23 @@
+-- +goose Up
+-- quote_of intentionally has no foreign key: a quote keeps pointing at its
+-- original after that chirp is deleted, so clients can render it as unavailable.
+ALTER TABLE chirps
+ADD quote_of UUID;
+
+CREATE INDEX chirps_quote_of_idx ON chirps (quote_of);
+
+CREATE TABLE rechirps (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    UNIQUE (user_id, chirp_id)
+);
+
+-- +goose Down
+DROP TABLE rechirps;
+
+DROP INDEX chirps_quote_of_idx;
+
+ALTER TABLE chirps
+DROP COLUMN quote_of;
@@ -0,0 +1,31 @@
+-- +goose Up
+ALTER TABLE users
+ADD handle TEXT;
+
+CREATE UNIQUE INDEX users_handle_idx ON users (LOWER(handle));
+
+CREATE TABLE chirp_tags (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (chirp_id, tag)
+);
+
+CREATE INDEX chirp_tags_tag_idx ON chirp_tags (tag);
+
+CREATE TABLE chirp_mentions (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions (user_id);
+
+-- +goose Down
+DROP TABLE chirp_mentions;
+
+DROP TABLE chirp_tags;
+
+DROP INDEX users_handle_idx;
+
+ALTER TABLE users
+DROP COLUMN handle;
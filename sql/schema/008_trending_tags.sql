@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE trending_tags (
+    window_name TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    rank INTEGER NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    recent_count INTEGER NOT NULL,
+    baseline_count INTEGER NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (window_name, tag)
+);
+
+CREATE INDEX chirps_created_at_idx ON chirps (created_at);
+
+-- +goose Down
+DROP INDEX chirps_created_at_idx;
+
+DROP TABLE trending_tags;
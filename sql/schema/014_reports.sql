@@ -0,0 +1,59 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD hidden_at TIMESTAMP;
+
+ALTER TABLE users
+ADD suspended_until TIMESTAMP;
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    -- NULL for reports raised automatically by the content policy
+    reporter_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'dismissed')),
+    UNIQUE (chirp_id, reporter_id)
+);
+
+CREATE INDEX reports_status_created_at_idx ON reports (status, created_at);
+
+-- Automatic flags become reports in the moderation queue
+INSERT INTO reports (id, created_at, updated_at, chirp_id, reporter_id, reason, details)
+SELECT id, created_at, created_at, chirp_id, NULL,
+    CASE WHEN reason = 'spam' THEN 'spam' ELSE 'other' END,
+    'Flagged by content policy: ' || reason
+FROM chirp_flags;
+
+DROP TABLE chirp_flags;
+
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    report_id UUID REFERENCES reports(id) ON DELETE SET NULL,
+    chirp_id UUID,
+    target_user_id UUID,
+    action TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT ''
+);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+
+CREATE TABLE chirp_flags (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL
+);
+
+DROP TABLE reports;
+
+ALTER TABLE users
+DROP COLUMN suspended_until;
+
+ALTER TABLE chirps
+DROP COLUMN hidden_at;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE users
+ADD banned_at TIMESTAMP;
+
+ALTER TABLE users
+ADD suspension_reason TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN suspension_reason;
+
+ALTER TABLE users
+DROP COLUMN banned_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE drafts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    quote_of UUID,
+    media_ids UUID[] NOT NULL DEFAULT '{}',
+    -- NULL for drafts that aren't scheduled
+    publish_at TIMESTAMP,
+    status TEXT NOT NULL CHECK (status IN ('draft', 'scheduled', 'failed')),
+    -- Why a scheduled chirp couldn't be published
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX drafts_scheduled_publish_at_idx ON drafts (publish_at) WHERE status = 'scheduled';
+
+-- +goose Down
+DROP TABLE drafts;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'followers', 'private'));
+
+ALTER TABLE users
+ADD default_visibility TEXT NOT NULL DEFAULT 'public' CHECK (default_visibility IN ('public', 'followers', 'private'));
+
+-- NULL publishes with the author's default visibility
+ALTER TABLE drafts
+ADD visibility TEXT CHECK (visibility IN ('public', 'followers', 'private'));
+
+-- +goose Down
+ALTER TABLE drafts
+DROP COLUMN visibility;
+
+ALTER TABLE users
+DROP COLUMN default_visibility;
+
+ALTER TABLE chirps
+DROP COLUMN visibility;
@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE collections (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+-- Bookmarks outlive soft deletes and hiding, so they're only removed when
+-- the chirp is purged. Deleting a collection keeps its bookmarks.
+CREATE TABLE bookmarks (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    collection_id UUID REFERENCES collections(id) ON DELETE SET NULL,
+    UNIQUE (user_id, chirp_id)
+);
+
+CREATE INDEX bookmarks_user_id_created_at_idx ON bookmarks (user_id, created_at);
+CREATE INDEX bookmarks_collection_id_idx ON bookmarks (collection_id);
+
+-- +goose Down
+DROP TABLE bookmarks;
+
+DROP TABLE collections;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE outbox_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    -- NULL once the event is delivered or given up on. Claiming an event
+    -- pushes this forward, so events held by a dispatcher that stopped are
+    -- tried again.
+    next_attempt_at TIMESTAMP,
+    delivered_at TIMESTAMP,
+    failed_at TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX outbox_events_next_attempt_at_idx ON outbox_events (next_attempt_at) WHERE next_attempt_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE outbox_events;
@@ -0,0 +1,75 @@
+-- +goose Up
+-- Each user signs the activities they send with their own key, created the
+-- first time it's needed.
+CREATE TABLE actor_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    public_key TEXT NOT NULL,
+    private_key TEXT NOT NULL
+);
+
+-- Actors on other servers, cached so their signatures can be checked
+-- without fetching them every time.
+CREATE TABLE remote_actors (
+    id TEXT PRIMARY KEY,
+    inbox TEXT NOT NULL,
+    -- Empty if the server has no shared inbox
+    shared_inbox TEXT NOT NULL DEFAULT '',
+    preferred_username TEXT NOT NULL DEFAULT '',
+    key_id TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    fetched_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE remote_followers (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id TEXT NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    -- The Follow activity, which Undo refers to
+    follow_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, actor_id)
+);
+
+-- Notes sent to our inboxes. Content is HTML from another server and must
+-- be sanitized before it's displayed.
+CREATE TABLE remote_notes (
+    id TEXT PRIMARY KEY,
+    actor_id TEXT NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    in_reply_to TEXT,
+    published TIMESTAMP NOT NULL,
+    received_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX remote_notes_actor_id_idx ON remote_notes (actor_id);
+
+-- Activities waiting to be sent to remote inboxes
+CREATE TABLE federation_deliveries (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key_id TEXT NOT NULL,
+    inbox TEXT NOT NULL,
+    activity_id TEXT NOT NULL,
+    activity JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    -- NULL once the activity is delivered or given up on
+    next_attempt_at TIMESTAMP,
+    delivered_at TIMESTAMP,
+    failed_at TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    UNIQUE (activity_id, inbox)
+);
+
+CREATE INDEX federation_deliveries_next_attempt_at_idx ON federation_deliveries (next_attempt_at) WHERE next_attempt_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE federation_deliveries;
+
+DROP TABLE remote_notes;
+
+DROP TABLE remote_followers;
+
+DROP TABLE remote_actors;
+
+DROP TABLE actor_keys;
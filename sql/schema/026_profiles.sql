@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users
+ADD display_name TEXT NOT NULL DEFAULT '',
+ADD bio TEXT NOT NULL DEFAULT '',
+ADD avatar_id UUID REFERENCES media_files(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN avatar_id,
+DROP COLUMN bio,
+DROP COLUMN display_name;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Notes from other servers were never shown anywhere
+DROP TABLE remote_notes;
+
+-- +goose Down
+CREATE TABLE remote_notes (
+    id TEXT PRIMARY KEY,
+    actor_id TEXT NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    in_reply_to TEXT,
+    published TIMESTAMP NOT NULL,
+    received_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX remote_notes_actor_id_idx ON remote_notes (actor_id);
@@ -0,0 +1,29 @@
+-- +goose Up
+-- The two users in a one-to-one conversation, lowest ID first, so there
+-- can only be one conversation per pair. NULL for group conversations.
+ALTER TABLE conversations
+ADD user_low UUID REFERENCES users(id) ON DELETE SET NULL,
+ADD user_high UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- Existing duplicates keep their messages; only the oldest conversation
+-- between each pair is reused from now on
+UPDATE conversations
+SET user_low = pairs.user_low, user_high = pairs.user_high
+FROM (
+    SELECT DISTINCT ON (a.user_id, b.user_id) conversations.id, a.user_id AS user_low, b.user_id AS user_high
+    FROM conversations
+    JOIN conversation_participants a ON a.conversation_id = conversations.id
+    JOIN conversation_participants b ON b.conversation_id = conversations.id AND a.user_id < b.user_id
+    WHERE NOT conversations.is_group
+    ORDER BY a.user_id, b.user_id, conversations.created_at
+) pairs
+WHERE conversations.id = pairs.id;
+
+CREATE UNIQUE INDEX conversations_user_low_user_high_idx ON conversations (user_low, user_high);
+
+-- +goose Down
+DROP INDEX conversations_user_low_user_high_idx;
+
+ALTER TABLE conversations
+DROP COLUMN user_high,
+DROP COLUMN user_low;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- Votes must be for one of their own poll's options
+DELETE FROM poll_votes
+WHERE NOT EXISTS (
+    SELECT 1 FROM poll_options
+    WHERE poll_options.id = poll_votes.option_id AND poll_options.poll_id = poll_votes.poll_id
+);
+
+ALTER TABLE poll_options
+ADD CONSTRAINT poll_options_poll_id_id_key UNIQUE (poll_id, id);
+
+ALTER TABLE poll_votes
+DROP CONSTRAINT poll_votes_option_id_fkey,
+ADD CONSTRAINT poll_votes_poll_id_option_id_fkey FOREIGN KEY (poll_id, option_id)
+    REFERENCES poll_options(poll_id, id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE poll_votes
+DROP CONSTRAINT poll_votes_poll_id_option_id_fkey,
+ADD CONSTRAINT poll_votes_option_id_fkey FOREIGN KEY (option_id)
+    REFERENCES poll_options(id) ON DELETE CASCADE;
+
+ALTER TABLE poll_options
+DROP CONSTRAINT poll_options_poll_id_id_key;
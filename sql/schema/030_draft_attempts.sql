@@ -0,0 +1,11 @@
+-- +goose Up
+-- Scheduled chirps that fail to publish for reasons other than validation
+-- are retried a few times, without holding up the drafts due after them
+ALTER TABLE drafts
+ADD attempts INTEGER NOT NULL DEFAULT 0,
+ADD retry_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE drafts
+DROP COLUMN retry_at,
+DROP COLUMN attempts;